@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+	"yandex-diplom/internal/accrualsim"
+	"yandex-diplom/internal/money"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("accrual-sim", flag.ContinueOnError)
+
+	address := fs.String("a", envOr("ACCRUAL_SIM_ADDRESS", "localhost:8085"), "Listen address")
+	registeredFor := fs.Duration("registered-for", time.Second, "How long an order stays REGISTERED")
+	processingFor := fs.Duration("processing-for", 2*time.Second, "How long an order stays PROCESSING")
+	defaultAccrual := fs.String("accrual", "500", "Accrual for orders that match no rule")
+	rules := fs.String("rules", os.Getenv("ACCRUAL_SIM_RULES"), `Accrual rules as JSON, e.g. [{"match":"12","accrual":100.5},{"match":"99","invalid":true}]`)
+	rpm := fs.Int("rpm", envInt("ACCRUAL_SIM_RPM", 0), "Requests per minute before answering 429, 0 means unlimited")
+	autoRegister := fs.Bool("auto-register", false, "Register unknown orders on first request instead of answering 204")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	accrual, err := money.Parse(*defaultAccrual)
+	if err != nil {
+		return fmt.Errorf("invalid accrual %q: %w", *defaultAccrual, err)
+	}
+
+	parsed, err := accrualsim.ParseRules(*rules)
+	if err != nil {
+		return err
+	}
+
+	sim := accrualsim.New(accrualsim.Config{
+		RegisteredFor:     *registeredFor,
+		ProcessingFor:     *processingFor,
+		Rules:             parsed,
+		DefaultAccrual:    accrual,
+		RequestsPerMinute: *rpm,
+		AutoRegister:      *autoRegister,
+	})
+
+	srv := &http.Server{Addr: *address, Handler: sim.Handler(), ReadHeaderTimeout: 5 * time.Second}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "accrual simulator listening on %s\n", *address)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
@@ -5,13 +5,17 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
+	"yandex-diplom/internal/accrual"
+	"yandex-diplom/internal/auth"
 	config "yandex-diplom/internal/config/gophermart"
 	"yandex-diplom/internal/gophermart"
 	"yandex-diplom/internal/job"
 	"yandex-diplom/internal/logger"
 	"yandex-diplom/internal/server"
+	"yandex-diplom/internal/storage/memory"
 	"yandex-diplom/internal/storage/postgresql"
 	"yandex-diplom/internal/worker"
 
@@ -36,19 +40,64 @@ func main() {
 		logger.Fatal("Failed to parse config:", zap.Error(err))
 	}
 
-	storage, err := postgresql.NewPostgresStorage(cfg.DatabaseURI)
-	if err != nil {
-		logger.Fatal("Failed to connect to database:", zap.Error(err))
+	if cfg.JWTPrivateKey != "" {
+		keys, err := auth.LoadKeySet(cfg.JWTPrivateKey, cfg.JWTPublicKeys)
+		if err != nil {
+			logger.Fatal("Failed to load jwt keys:", zap.Error(err))
+		}
+		auth.UseKeys(keys)
+		logger.Info("Signing tokens", zap.String("kid", keys.Signing().ID), zap.String("alg", keys.Signing().Method.Alg()))
+	} else {
+		logger.Warn("No jwt keys configured, signing tokens with the shared SECRET")
+	}
+
+	var storage gophermart.Reposiroty
+	var queue job.Queue
+	switch cfg.Storage {
+	case config.StorageMemory:
+		logger.Warn("Using in-memory storage, data will be lost on restart")
+		mem := memory.NewMemoryStorage()
+		storage, queue = mem, mem
+	default:
+		pg, err := postgresql.NewPostgresStorage(cfg.DatabaseURI)
+		if err != nil {
+			logger.Fatal("Failed to connect to database:", zap.Error(err))
+		}
+		defer pg.Database.Close()
+		storage, queue = pg, pg
 	}
-	defer storage.Database.Close()
 
-	service := gophermart.New(storage, logger, cfg.Environment, cfg.AccuralAddress)
+	accrualClient := accrual.New(cfg.AccuralAddress, accrual.Config{
+		RequestTimeout:   cfg.AccrualTimeout,
+		FailureThreshold: cfg.AccrualBreakerThreshold,
+		Cooldown:         cfg.AccrualBreakerCooldown,
+	})
 
-	jobCh := make(chan job.Job, 100)
+	service := gophermart.New(storage, logger, cfg.Environment, accrualClient)
 
-	workers := worker.InitWorkers(ctx, 4, service, jobCh)
-	workers.StartOrderProcessor(worker.OrderConfig{BatchSize: 30})
-	workers.StartBalanceProcessor(worker.BalanceConfig{})
+	workers := worker.InitWorkers(ctx, worker.PoolConfig{
+		MinWorkers:    cfg.WorkersMin,
+		MaxWorkers:    cfg.WorkersMax,
+		ScaleInterval: cfg.WorkersScaleInterval,
+	}, service, queue)
+	workers.StartOrderProcessor(worker.OrderConfig{
+		BatchSize:               cfg.OrderBatchSize,
+		FetchNewInterval:        cfg.OrderFetchNewInterval,
+		FetchProccesingInterval: cfg.OrderFetchProcessingInterval,
+		RequestsPerMinute:       cfg.AccrualRPM,
+		Retry: job.RetryPolicy{
+			BaseDelay:   cfg.OrderRetryBase,
+			MaxDelay:    cfg.OrderRetryMax,
+			MaxAttempts: cfg.OrderMaxAttempts,
+			MaxAge:      cfg.OrderMaxAge,
+		},
+	})
+	workers.StartBalanceProcessor(worker.BalanceConfig{FetchInterval: cfg.BalanceInterval})
+	workers.StartReconcileProcessor(worker.ReconcileConfig{
+		Interval:  cfg.ReconcileInterval,
+		BatchSize: cfg.ReconcileBatchSize,
+		Repair:    cfg.ReconcileRepair,
+	})
 
 	srv, err := server.New(cfg, service)
 	if err != nil {
@@ -65,7 +114,17 @@ func main() {
 	shutdownCtx, cancel2 := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel2()
 
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := workers.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("workers shutdown error", zap.Error(err))
+		}
+	}()
+
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Warn("http shutdown error", zap.Error(err))
 	}
+	wg.Wait()
 }
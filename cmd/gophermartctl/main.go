@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/storage/postgresql"
+)
+
+const usage = `Usage: gophermartctl [-d DATABASE_URI] <command> [flags]
+
+Commands:
+  grant-role   -login <login> [-role admin]   выдать роль пользователю
+  revoke-role  -login <login>                 вернуть пользователю роль user
+`
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("gophermartctl", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), usage) }
+
+	dsn := fs.String("d", os.Getenv("DATABASE_URI"), "Database URI")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("command is required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cmd, cmdArgs := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "grant-role":
+		return setRole(ctx, *dsn, cmd, cmdArgs, true)
+	case "revoke-role":
+		return setRole(ctx, *dsn, cmd, cmdArgs, false)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func openStorage(dsn string) (*postgresql.PostgresStorage, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid database uri %q", dsn)
+	}
+	return postgresql.NewPostgresStorage(u)
+}
+
+func setRole(ctx context.Context, dsn string, cmd string, args []string, grant bool) error {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	login := fs.String("login", "", "User login")
+	role := fs.String("role", models.RoleAdmin, "Role to grant")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *login == "" {
+		return fmt.Errorf("%s: -login is required", cmd)
+	}
+	if !grant {
+		*role = models.RoleUser
+	}
+	if *role != models.RoleAdmin && *role != models.RoleUser {
+		return fmt.Errorf("%s: unknown role %q", cmd, *role)
+	}
+
+	storage, err := openStorage(dsn)
+	if err != nil {
+		return err
+	}
+	defer storage.Database.Close()
+
+	if err := storage.SetUserRole(ctx, *login, *role); err != nil {
+		return fmt.Errorf("%s: %w", cmd, err)
+	}
+
+	fmt.Printf("%s: %s now has role %s\n", cmd, *login, *role)
+	return nil
+}
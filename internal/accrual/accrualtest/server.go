@@ -0,0 +1,122 @@
+// Package accrualtest поддельная система начислений для тестов.
+package accrualtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Response ответ на запрос заказа. Нулевой Status означает 200 OK.
+type Response struct {
+	Status     int
+	Order      string
+	State      string
+	Accrual    *float64
+	RetryAfter string
+	// Body тело ответа для статусов, отличных от 200.
+	Body string
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	orders    map[string]Response
+	fallback  Response
+	hold      chan struct{}
+	requested atomic.Int64
+}
+
+// NewServer запускает сервер. Неизвестные заказы получают 204.
+func NewServer() *Server {
+	s := &Server{
+		orders:   make(map[string]Response),
+		fallback: Response{Status: http.StatusNoContent},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// BaseURL адрес сервера для accrual.New.
+func (s *Server) BaseURL() *url.URL {
+	u, _ := url.Parse(s.Server.URL)
+	return u
+}
+
+// SetOrder задаёт ответ для заказа number.
+func (s *Server) SetOrder(number string, resp Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resp.Order == "" {
+		resp.Order = number
+	}
+	s.orders[number] = resp
+}
+
+// SetFallback задаёт ответ для всех заказов без своего ответа.
+func (s *Server) SetFallback(resp Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = resp
+}
+
+// Hold задерживает ответы до закрытия возвращённого канала.
+func (s *Server) Hold() chan<- struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hold = make(chan struct{})
+	return s.hold
+}
+
+// Requests число полученных запросов.
+func (s *Server) Requests() int64 {
+	return s.requested.Load()
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.requested.Add(1)
+
+	number, ok := strings.CutPrefix(r.URL.Path, "/api/orders/")
+	if !ok || r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	resp, ok := s.orders[number]
+	if !ok {
+		resp = s.fallback
+		resp.Order = number
+	}
+	hold := s.hold
+	s.mu.Unlock()
+
+	if hold != nil {
+		select {
+		case <-hold:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if resp.RetryAfter != "" {
+		w.Header().Set("Retry-After", resp.RetryAfter)
+	}
+	if resp.Status != 0 && resp.Status != http.StatusOK {
+		w.WriteHeader(resp.Status)
+		_, _ = w.Write([]byte(resp.Body))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Order   string   `json:"order"`
+		Status  string   `json:"status"`
+		Accrual *float64 `json:"accrual,omitempty"`
+	}{resp.Order, resp.State, resp.Accrual})
+}
@@ -0,0 +1,51 @@
+package accrual
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	b := NewBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.Failure()
+	require.True(t, b.Allow())
+	b.Failure()
+	require.Equal(t, StateOpen, b.State())
+	require.False(t, b.Allow())
+
+	// после паузы пропускается один пробный запрос
+	now = now.Add(time.Minute)
+	require.True(t, b.Allow())
+	require.False(t, b.Allow())
+
+	// неудачная проба снова размыкает
+	b.Failure()
+	require.False(t, b.Allow())
+
+	now = now.Add(time.Minute)
+	require.True(t, b.Allow())
+	b.Success()
+	require.Equal(t, StateClosed, b.State())
+	require.True(t, b.Allow())
+}
+
+func TestBreaker_ReleaseKeepsHalfOpen(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	b := NewBreaker(1, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.Failure()
+	now = now.Add(time.Minute)
+	require.True(t, b.Allow())
+
+	// отпущенная проба не замыкает предохранитель, но даёт пройти следующей
+	b.Release()
+	require.Equal(t, StateHalfOpen, b.State())
+	require.True(t, b.Allow())
+	require.False(t, b.Allow())
+}
@@ -0,0 +1,210 @@
+// Package accrual клиент системы расчёта начислений.
+package accrual
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+)
+
+type Client interface {
+	// GetOrder возвращает статус и начисление по заказу.
+	GetOrder(ctx context.Context, number string) (models.Order, error)
+	Stats() models.AccrualStats
+}
+
+type Config struct {
+	// Timeout общий таймаут http.Client.
+	Timeout time.Duration
+	// RequestTimeout таймаут одного запроса.
+	RequestTimeout time.Duration
+	// FailureThreshold число 5xx и таймаутов подряд, после которого размыкается предохранитель.
+	FailureThreshold int
+	// Cooldown время, на которое размыкается предохранитель.
+	Cooldown time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.RequestTimeout <= 0 {
+		c.RequestTimeout = 5 * time.Second
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 2 * c.RequestTimeout
+	}
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = 5
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = 30 * time.Second
+	}
+	return c
+}
+
+type HTTPClient struct {
+	base    *url.URL
+	cfg     Config
+	client  *http.Client
+	breaker *Breaker
+	metrics metrics
+	now     func() time.Time
+}
+
+func New(base *url.URL, cfg Config) *HTTPClient {
+	cfg = cfg.withDefaults()
+	return &HTTPClient{
+		base:    base,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		breaker: NewBreaker(cfg.FailureThreshold, cfg.Cooldown),
+		now:     time.Now,
+	}
+}
+
+type orderResponse struct {
+	Order   string      `json:"order"`
+	Status  string      `json:"status"`
+	Accrual json.Number `json:"accrual,omitempty"`
+}
+
+func (c *HTTPClient) Stats() models.AccrualStats {
+	stats := c.metrics.snapshot()
+	stats.BreakerState = c.breaker.State()
+	return stats
+}
+
+func (c *HTTPClient) GetOrder(ctx context.Context, number string) (models.Order, error) {
+	if !c.breaker.Allow() {
+		c.metrics.rejected.Add(1)
+		return models.Order{}, domain.MakeError(
+			fmt.Errorf("accrual.GetOrder circuit breaker is open"),
+			domain.ErrServiceUnavailable)
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, c.cfg.RequestTimeout)
+	defer cancel()
+
+	u := *c.base
+	u.Path = "/api/orders/" + number
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		c.breaker.Release()
+		return models.Order{}, domain.MakeError(
+			fmt.Errorf("accrual.GetOrder build request: %w", err), domain.ErrInternal)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	started := time.Now()
+	resp, err := c.client.Do(req)
+	c.metrics.observe(started)
+	if err != nil {
+		return models.Order{}, c.transportError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		c.breaker.Success()
+		order, err := decodeOrder(resp)
+		if err != nil {
+			c.metrics.failures.Add(1)
+			return models.Order{}, err
+		}
+		c.metrics.succeeded.Add(1)
+		return order, nil
+
+	case resp.StatusCode == http.StatusNoContent:
+		c.breaker.Success()
+		c.metrics.notRegistered.Add(1)
+		return models.Order{}, domain.MakeError(
+			fmt.Errorf("accrual.GetOrder order doesn't exist at accrual"),
+			domain.ErrNoContent)
+
+	case resp.StatusCode == http.StatusTooManyRequests:
+		// 429 это штатное ограничение, а не отказ сервера
+		c.breaker.Success()
+		c.metrics.tooManyRequests.Add(1)
+		retry, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), c.now())
+		if !ok {
+			retry = defaultRetryAfter
+		}
+		return models.Order{}, domain.MakeError(
+			fmt.Errorf("accrual.GetOrder too many requests to accrual"),
+			&domain.TooManyRequestsError{RetryAfter: retry, Limit: parseLimit(resp.Body)})
+
+	case resp.StatusCode >= http.StatusInternalServerError:
+		c.breaker.Failure()
+		c.metrics.serverErrors.Add(1)
+		return models.Order{}, domain.MakeError(
+			fmt.Errorf("accrual.GetOrder unexpected status %d", resp.StatusCode),
+			domain.ErrInternal)
+
+	default:
+		c.breaker.Success()
+		c.metrics.failures.Add(1)
+		return models.Order{}, domain.MakeError(
+			fmt.Errorf("accrual.GetOrder unexpected status %d", resp.StatusCode),
+			domain.ErrInternal)
+	}
+}
+
+// transportError учитывает в предохранителе только отказы сервера, а не отмену вызывающим.
+func (c *HTTPClient) transportError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		c.breaker.Release()
+		c.metrics.failures.Add(1)
+		return domain.MakeError(
+			fmt.Errorf("accrual.GetOrder request cancelled: %w", err),
+			domain.ErrServiceUnavailable)
+	}
+
+	c.breaker.Failure()
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		c.metrics.timeouts.Add(1)
+		return domain.MakeError(
+			fmt.Errorf("accrual.GetOrder request to accrual server timed out: %w", err),
+			domain.ErrServiceUnavailable)
+	}
+
+	c.metrics.failures.Add(1)
+	return domain.MakeError(
+		fmt.Errorf("accrual.GetOrder request to accrual server failed: %w", err),
+		domain.ErrInternal)
+}
+
+func decodeOrder(resp *http.Response) (models.Order, error) {
+	var ext orderResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ext); err != nil {
+		return models.Order{}, domain.MakeError(
+			fmt.Errorf("accrual.GetOrder can't parse answer from accrual server: %w", err),
+			domain.ErrInternal)
+	}
+
+	var accrual money.Amount
+	if ext.Accrual != "" {
+		var err error
+		accrual, err = money.ParseRound(ext.Accrual.String())
+		if err != nil {
+			return models.Order{}, domain.MakeError(
+				fmt.Errorf("accrual.GetOrder can't parse accrual from accrual server: %w", err),
+				domain.ErrInternal)
+		}
+	}
+
+	return models.Order{
+		Number:  ext.Order,
+		Status:  ext.Status,
+		Accrual: accrual,
+	}, nil
+}
+
+var _ Client = (*HTTPClient)(nil)
@@ -0,0 +1,172 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+	"yandex-diplom/internal/accrual/accrualtest"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/money"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, cfg Config) (*HTTPClient, *accrualtest.Server) {
+	t.Helper()
+
+	srv := accrualtest.NewServer()
+	t.Cleanup(srv.Close)
+	return New(srv.BaseURL(), cfg), srv
+}
+
+func TestGetOrder_OK(t *testing.T) {
+	c, srv := newTestClient(t, Config{})
+	points := 42.5
+	srv.SetOrder("123", accrualtest.Response{State: "PROCESSED", Accrual: &points})
+
+	order, err := c.GetOrder(context.Background(), "123")
+	require.NoError(t, err)
+	require.Equal(t, "123", order.Number)
+	require.Equal(t, "PROCESSED", order.Status)
+	require.Equal(t, money.FromCents(4250), order.Accrual)
+
+	stats := c.Stats()
+	require.Equal(t, int64(1), stats.Requests)
+	require.Equal(t, int64(1), stats.Succeeded)
+	require.Equal(t, StateClosed, stats.BreakerState)
+}
+
+func TestGetOrder_NoContent(t *testing.T) {
+	c, _ := newTestClient(t, Config{})
+
+	_, err := c.GetOrder(context.Background(), "123")
+	require.ErrorIs(t, err, domain.ErrNoContent)
+	require.Equal(t, int64(1), c.Stats().NotRegistered)
+}
+
+func TestGetOrder_TooManyRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		want       time.Duration
+	}{
+		{name: "default", want: time.Minute},
+		{name: "seconds", retryAfter: "5", want: 5 * time.Second},
+		{name: "http date", retryAfter: "Sat, 17 Oct 2026 12:00:30 GMT", want: 30 * time.Second},
+		{name: "garbage", retryAfter: "soon", want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv := newTestClient(t, Config{})
+			c.now = func() time.Time { return time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC) }
+			srv.SetFallback(accrualtest.Response{Status: http.StatusTooManyRequests, RetryAfter: tt.retryAfter})
+
+			_, err := c.GetOrder(context.Background(), "123")
+
+			var tooMany *domain.TooManyRequestsError
+			require.True(t, errors.As(err, &tooMany))
+			require.Equal(t, tt.want, tooMany.RetryAfter)
+			require.Equal(t, StateClosed, c.Stats().BreakerState)
+		})
+	}
+}
+
+func TestGetOrder_TooManyRequestsLimit(t *testing.T) {
+	c, srv := newTestClient(t, Config{})
+	srv.SetFallback(accrualtest.Response{
+		Status:     http.StatusTooManyRequests,
+		RetryAfter: "60",
+		Body:       "No more than 30 requests per minute allowed",
+	})
+
+	_, err := c.GetOrder(context.Background(), "123")
+
+	var tooMany *domain.TooManyRequestsError
+	require.True(t, errors.As(err, &tooMany))
+	require.Equal(t, 30, tooMany.Limit)
+}
+
+func TestParseLimit(t *testing.T) {
+	n, ok := ParseLimit("No more than 10 requests per minute allowed\n")
+	require.True(t, ok)
+	require.Equal(t, 10, n)
+
+	_, ok = ParseLimit("slow down")
+	require.False(t, ok)
+}
+
+func TestGetOrder_BreakerOpensOnServerErrors(t *testing.T) {
+	c, srv := newTestClient(t, Config{FailureThreshold: 3, Cooldown: time.Hour})
+	srv.SetFallback(accrualtest.Response{Status: http.StatusInternalServerError})
+
+	for range 3 {
+		_, err := c.GetOrder(context.Background(), "123")
+		require.ErrorIs(t, err, domain.ErrInternal)
+	}
+
+	_, err := c.GetOrder(context.Background(), "123")
+	require.ErrorIs(t, err, domain.ErrServiceUnavailable)
+	require.Equal(t, int64(3), srv.Requests())
+
+	stats := c.Stats()
+	require.Equal(t, int64(3), stats.ServerErrors)
+	require.Equal(t, int64(1), stats.Rejected)
+	require.Equal(t, StateOpen, stats.BreakerState)
+}
+
+func TestGetOrder_CallerCancelDoesNotCloseBreaker(t *testing.T) {
+	c, srv := newTestClient(t, Config{FailureThreshold: 1, Cooldown: time.Minute})
+	now := time.Now()
+	c.breaker.now = func() time.Time { return now }
+	srv.SetFallback(accrualtest.Response{Status: http.StatusInternalServerError})
+
+	_, err := c.GetOrder(context.Background(), "123")
+	require.ErrorIs(t, err, domain.ErrInternal)
+	require.Equal(t, StateOpen, c.Stats().BreakerState)
+
+	now = now.Add(time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = c.GetOrder(ctx, "123")
+	require.ErrorIs(t, err, domain.ErrServiceUnavailable)
+	require.Equal(t, StateHalfOpen, c.Stats().BreakerState)
+
+	// проба не потеряна: следующий запрос доходит до сервера
+	_, err = c.GetOrder(context.Background(), "123")
+	require.ErrorIs(t, err, domain.ErrInternal)
+	require.Equal(t, int64(2), srv.Requests())
+	require.Equal(t, StateOpen, c.Stats().BreakerState)
+}
+
+func TestGetOrder_Timeout(t *testing.T) {
+	c, srv := newTestClient(t, Config{RequestTimeout: 20 * time.Millisecond, FailureThreshold: 1, Cooldown: time.Hour})
+	release := srv.Hold()
+	defer close(release)
+
+	_, err := c.GetOrder(context.Background(), "123")
+	require.ErrorIs(t, err, domain.ErrServiceUnavailable)
+
+	stats := c.Stats()
+	require.Equal(t, int64(1), stats.Timeouts)
+	require.Equal(t, StateOpen, stats.BreakerState)
+}
+
+func TestGetOrder_BadJSON(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{invalid json`))
+	}))
+	defer ts.Close()
+
+	base, _ := url.Parse(ts.URL)
+	c := New(base, Config{})
+
+	_, err := c.GetOrder(context.Background(), "123")
+	require.ErrorIs(t, err, domain.ErrInternal)
+	require.Equal(t, int64(1), c.Stats().Failures)
+}
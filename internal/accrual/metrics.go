@@ -0,0 +1,41 @@
+package accrual
+
+import (
+	"sync/atomic"
+	"time"
+	"yandex-diplom/internal/models"
+)
+
+type metrics struct {
+	requests        atomic.Int64
+	succeeded       atomic.Int64
+	notRegistered   atomic.Int64
+	tooManyRequests atomic.Int64
+	serverErrors    atomic.Int64
+	timeouts        atomic.Int64
+	failures        atomic.Int64
+	rejected        atomic.Int64
+	latency         atomic.Int64
+}
+
+func (m *metrics) observe(started time.Time) {
+	m.requests.Add(1)
+	m.latency.Add(int64(time.Since(started)))
+}
+
+func (m *metrics) snapshot() models.AccrualStats {
+	stats := models.AccrualStats{
+		Requests:        m.requests.Load(),
+		Succeeded:       m.succeeded.Load(),
+		NotRegistered:   m.notRegistered.Load(),
+		TooManyRequests: m.tooManyRequests.Load(),
+		ServerErrors:    m.serverErrors.Load(),
+		Timeouts:        m.timeouts.Load(),
+		Failures:        m.failures.Load(),
+		Rejected:        m.rejected.Load(),
+	}
+	if stats.Requests > 0 {
+		stats.AvgLatency = time.Duration(m.latency.Load() / stats.Requests)
+	}
+	return stats
+}
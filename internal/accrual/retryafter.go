@@ -0,0 +1,54 @@
+package accrual
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultRetryAfter = time.Minute
+
+// ParseRetryAfter разбирает Retry-After в виде секунд или HTTP-даты.
+func ParseRetryAfter(val string, now time.Time) (time.Duration, bool) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(val); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	at, err := http.ParseTime(val)
+	if err != nil {
+		return 0, false
+	}
+	if d := at.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// ParseLimit достаёт N из текста 429 "No more than N requests per minute allowed".
+func ParseLimit(body string) (int, bool) {
+	var n int
+	if _, err := fmt.Sscanf(strings.TrimSpace(body), "No more than %d requests per minute allowed", &n); err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+func parseLimit(body io.Reader) int {
+	data, err := io.ReadAll(io.LimitReader(body, 512))
+	if err != nil {
+		return 0
+	}
+	n, _ := ParseLimit(string(data))
+	return n
+}
@@ -0,0 +1,36 @@
+package accrual
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// SignatureHeader заголовок с подписью уведомления: "sha256=" и HMAC-SHA256 тела в hex.
+const SignatureHeader = "X-Accrual-Signature"
+
+const signaturePrefix = "sha256="
+
+// Sign подписывает тело уведомления общим секретом.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature проверяет подпись из SignatureHeader за постоянное время.
+func VerifySignature(secret, body []byte, signature string) bool {
+	raw, ok := strings.CutPrefix(signature, signaturePrefix)
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(raw)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
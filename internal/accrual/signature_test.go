@@ -0,0 +1,20 @@
+package accrual
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignature(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"order":"12345678903","status":"PROCESSED","accrual":500}`)
+	sig := Sign(secret, body)
+
+	require.True(t, VerifySignature(secret, body, sig))
+	require.False(t, VerifySignature([]byte("other"), body, sig))
+	require.False(t, VerifySignature(secret, []byte(`{"order":"12345678903","status":"PROCESSED","accrual":5000}`), sig))
+	require.False(t, VerifySignature(secret, body, sig[len("sha256="):]))
+	require.False(t, VerifySignature(secret, body, "sha256=zz"))
+	require.False(t, VerifySignature(secret, body, ""))
+}
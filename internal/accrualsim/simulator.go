@@ -0,0 +1,200 @@
+// Package accrualsim симулятор системы расчёта начислений для локальной разработки и e2e тестов.
+package accrualsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"yandex-diplom/internal/luhn"
+	"yandex-diplom/internal/money"
+)
+
+const (
+	StatusRegistered = "REGISTERED"
+	StatusProcessing = "PROCESSING"
+	StatusInvalid    = "INVALID"
+	StatusProcessed  = "PROCESSED"
+)
+
+// Rule правило начисления для заказов с номером, начинающимся на Match.
+// Пустой Match подходит любому заказу.
+type Rule struct {
+	Match   string       `json:"match"`
+	Accrual money.Amount `json:"accrual"`
+	Invalid bool         `json:"invalid"`
+}
+
+type Config struct {
+	// RegisteredFor сколько заказ находится в REGISTERED.
+	RegisteredFor time.Duration
+	// ProcessingFor сколько заказ находится в PROCESSING.
+	ProcessingFor time.Duration
+	// Rules проверяются по порядку, срабатывает первое подходящее.
+	Rules []Rule
+	// DefaultAccrual начисление, если ни одно правило не подошло.
+	DefaultAccrual money.Amount
+	// RequestsPerMinute бюджет запросов в минуту, 0 без ограничения.
+	RequestsPerMinute int
+	// AutoRegister регистрирует заказ при первом запросе вместо ответа 204.
+	AutoRegister bool
+}
+
+type Simulator struct {
+	cfg Config
+	now func() time.Time
+
+	mu     sync.Mutex
+	orders map[string]time.Time
+	window time.Time
+	served int
+}
+
+func New(cfg Config) *Simulator {
+	return &Simulator{cfg: cfg, now: time.Now, orders: make(map[string]time.Time)}
+}
+
+// Handler маршруты симулятора: GET /api/orders/{number} и POST /api/orders.
+func (s *Simulator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/orders/{number}", s.getOrder)
+	mux.HandleFunc("POST /api/orders", s.registerOrder)
+	return mux
+}
+
+// Register регистрирует заказ. Возвращает false, если заказ уже зарегистрирован.
+func (s *Simulator) Register(number string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[number]; ok {
+		return false
+	}
+	s.orders[number] = s.now()
+	return true
+}
+
+type orderResponse struct {
+	Order   string        `json:"order"`
+	Status  string        `json:"status"`
+	Accrual *money.Amount `json:"accrual,omitempty"`
+}
+
+func (s *Simulator) getOrder(w http.ResponseWriter, r *http.Request) {
+	if !s.allow(w) {
+		return
+	}
+
+	number := r.PathValue("number")
+
+	s.mu.Lock()
+	registeredAt, ok := s.orders[number]
+	if !ok && s.cfg.AutoRegister {
+		registeredAt = s.now()
+		s.orders[number] = registeredAt
+		ok = true
+	}
+	now := s.now()
+	s.mu.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.state(number, now.Sub(registeredAt)))
+}
+
+func (s *Simulator) registerOrder(w http.ResponseWriter, r *http.Request) {
+	if !s.allow(w) {
+		return
+	}
+
+	var req struct {
+		Order string `json:"order"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Order == "" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if !s.Register(req.Order) {
+		http.Error(w, "order already registered", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// state статус заказа спустя elapsed после регистрации.
+func (s *Simulator) state(number string, elapsed time.Duration) orderResponse {
+	switch {
+	case elapsed < s.cfg.RegisteredFor:
+		return orderResponse{Order: number, Status: StatusRegistered}
+	case elapsed < s.cfg.RegisteredFor+s.cfg.ProcessingFor:
+		return orderResponse{Order: number, Status: StatusProcessing}
+	}
+
+	if !luhn.Valid(number) {
+		return orderResponse{Order: number, Status: StatusInvalid}
+	}
+
+	accrual := s.cfg.DefaultAccrual
+	for _, rule := range s.cfg.Rules {
+		if !strings.HasPrefix(number, rule.Match) {
+			continue
+		}
+		if rule.Invalid {
+			return orderResponse{Order: number, Status: StatusInvalid}
+		}
+		accrual = rule.Accrual
+		break
+	}
+
+	return orderResponse{Order: number, Status: StatusProcessed, Accrual: &accrual}
+}
+
+// allow учитывает запрос в бюджете текущей минуты и отвечает 429, если бюджет исчерпан.
+func (s *Simulator) allow(w http.ResponseWriter) bool {
+	if s.cfg.RequestsPerMinute <= 0 {
+		return true
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	if window := now.Truncate(time.Minute); !window.Equal(s.window) {
+		s.window = window
+		s.served = 0
+	}
+	s.served++
+	ok := s.served <= s.cfg.RequestsPerMinute
+	retry := s.window.Add(time.Minute).Sub(now)
+	s.mu.Unlock()
+
+	if ok {
+		return true
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusTooManyRequests)
+	_, _ = fmt.Fprintf(w, "No more than %d requests per minute allowed", s.cfg.RequestsPerMinute)
+	return false
+}
+
+// ParseRules разбирает правила из JSON массива.
+func ParseRules(data string) ([]Rule, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal([]byte(data), &rules); err != nil {
+		return nil, fmt.Errorf("accrualsim.ParseRules: %w", err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,123 @@
+package accrualsim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"yandex-diplom/internal/money"
+
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, h http.Handler, number string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/orders/"+number, nil))
+	return rec
+}
+
+func TestSimulator_Lifecycle(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	sim := New(Config{
+		RegisteredFor:  time.Second,
+		ProcessingFor:  time.Second,
+		DefaultAccrual: money.FromInt(500),
+		Rules: []Rule{
+			{Match: "12", Accrual: money.FromCents(1050)},
+			{Match: "99", Invalid: true},
+		},
+	})
+	sim.now = func() time.Time { return now }
+	h := sim.Handler()
+
+	require.Equal(t, http.StatusNoContent, get(t, h, "12345678903").Code)
+
+	for _, number := range []string{"12345678903", "79927398713", "9999999998", "79927398710"} {
+		require.True(t, sim.Register(number))
+	}
+	require.False(t, sim.Register("12345678903"))
+
+	tests := []struct {
+		after   time.Duration
+		number  string
+		status  string
+		accrual string
+	}{
+		{0, "12345678903", StatusRegistered, ""},
+		{time.Second, "12345678903", StatusProcessing, ""},
+		{2 * time.Second, "12345678903", StatusProcessed, "10.5"},
+		{2 * time.Second, "79927398713", StatusProcessed, "500"},
+		{2 * time.Second, "9999999998", StatusInvalid, ""},
+		// номер не проходит проверку Луна
+		{2 * time.Second, "79927398710", StatusInvalid, ""},
+	}
+
+	start := now
+	for _, tt := range tests {
+		now = start.Add(tt.after)
+		rec := get(t, h, tt.number)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Equal(t, `"`+tt.status+`"`, string(body["status"]), tt.number)
+		require.Equal(t, tt.accrual, string(body["accrual"]), tt.number)
+	}
+}
+
+func TestSimulator_AutoRegister(t *testing.T) {
+	sim := New(Config{AutoRegister: true, RegisteredFor: time.Hour})
+	rec := get(t, sim.Handler(), "79927398713")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), StatusRegistered)
+}
+
+func TestSimulator_RegisterEndpoint(t *testing.T) {
+	h := New(Config{}).Handler()
+
+	post := func() int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(`{"order":"79927398713"}`)))
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusAccepted, post())
+	require.Equal(t, http.StatusConflict, post())
+	require.Equal(t, http.StatusOK, get(t, h, "79927398713").Code)
+}
+
+func TestSimulator_RateLimit(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 15, 0, time.UTC)
+	sim := New(Config{RequestsPerMinute: 2})
+	sim.now = func() time.Time { return now }
+	h := sim.Handler()
+
+	require.Equal(t, http.StatusNoContent, get(t, h, "1").Code)
+	require.Equal(t, http.StatusNoContent, get(t, h, "1").Code)
+
+	rec := get(t, h, "1")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "45", rec.Header().Get("Retry-After"))
+	require.Equal(t, "No more than 2 requests per minute allowed", rec.Body.String())
+
+	now = now.Add(45 * time.Second)
+	require.Equal(t, http.StatusNoContent, get(t, h, "1").Code)
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(`[{"match":"12","accrual":100.5},{"match":"99","invalid":true}]`)
+	require.NoError(t, err)
+	require.Equal(t, []Rule{{Match: "12", Accrual: money.FromCents(10050)}, {Match: "99", Invalid: true}}, rules)
+
+	rules, err = ParseRules("")
+	require.NoError(t, err)
+	require.Empty(t, rules)
+
+	_, err = ParseRules(`{`)
+	require.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"slices"
+
+	"yandex-diplom/internal/models"
+)
+
+const (
+	// APIKeyHeader заголовок, в котором партнёр передаёт ключ.
+	APIKeyHeader = "X-Api-Key"
+	// apiKeyPrefix отличает ключи от прочих секретов, например при поиске утечек.
+	apiKeyPrefix = "gm_"
+	// apiKeyVisible сколько первых символов ключа хранится открыто для списка ключей.
+	apiKeyVisible = 10
+)
+
+const scopesKey = contextKey("scopes")
+
+// NewAPIKey возвращает ключ для партнёра, его видимый префикс и хэш для хранения.
+func NewAPIKey() (string, string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return key, key[:apiKeyVisible], HashAPIKey(key), nil
+}
+
+// HashAPIKey ключ случайный и длинный, поэтому хватает sha256 без соли, как у refresh-токенов.
+func HashAPIKey(key string) string {
+	return HashRefreshToken(key)
+}
+
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey, scopes)
+}
+
+// GetScopesFromContext права API-ключа; false, если запрос пришёл с сессией пользователя.
+func GetScopesFromContext(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(scopesKey).([]string)
+	return scopes, ok
+}
+
+// HasScope сессия пользователя проходит любую проверку, API-ключ — только со своими правами.
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, ok := GetScopesFromContext(ctx)
+	if !ok {
+		return true
+	}
+	return slices.Contains(scopes, scope)
+}
+
+type APIKeyProvider interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (models.User, []string, error)
+}
+
+// APIKeyMiddleware принимает запросы с заголовком X-Api-Key и кладёт в контекст
+// владельца ключа и права. Запросы без заголовка проходят дальше к Middleware.
+// Ставится только на маршруты, открытые для ключей.
+func APIKeyMiddleware(provider APIKeyProvider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(APIKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, scopes, err := provider.AuthenticateAPIKey(r.Context(), key)
+			if err != nil {
+				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := WithUser(r.Context(), &user)
+			ctx = WithRole(ctx, user.Role)
+			ctx = WithScopes(ctx, scopes)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
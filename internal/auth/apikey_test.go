@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"yandex-diplom/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockAPIKeyProvider struct {
+	key    string
+	user   models.User
+	scopes []string
+}
+
+func (m *mockAPIKeyProvider) AuthenticateAPIKey(ctx context.Context, key string) (models.User, []string, error) {
+	if key != m.key {
+		return models.User{}, nil, errors.New("unknown key")
+	}
+	return m.user, m.scopes, nil
+}
+
+func TestNewAPIKey(t *testing.T) {
+	key, prefix, hash, err := NewAPIKey()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, apiKeyPrefix))
+	assert.True(t, strings.HasPrefix(key, prefix))
+	assert.Equal(t, HashAPIKey(key), hash)
+	assert.NotContains(t, hash, key)
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	keys := &mockAPIKeyProvider{
+		key:    "gm_valid",
+		user:   models.User{ID: 5, Login: "partner", Role: models.RoleUser},
+		scopes: []string{models.ScopeOrdersWrite},
+	}
+	// сессий в тесте нет: любой запрос до Middleware без ключа получает 401
+	sessions := &mockUserProvider{err: errors.New("no user")}
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{"valid key", "gm_valid", http.StatusOK},
+		{"unknown key", "gm_other", http.StatusUnauthorized},
+		{"no key falls through to session", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+
+			var (
+				gotUser *models.User
+				write   bool
+				read    bool
+			)
+			h := APIKeyMiddleware(keys)(Middleware(sessions)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUser = GetUserFromContext(r.Context())
+				write = HasScope(r.Context(), models.ScopeOrdersWrite)
+				read = HasScope(r.Context(), models.ScopeOrdersRead)
+			})))
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, uint64(5), gotUser.ID)
+				assert.True(t, write)
+				assert.False(t, read)
+			}
+		})
+	}
+}
+
+func TestHasScope_Session(t *testing.T) {
+	assert.True(t, HasScope(context.Background(), models.ScopeBalanceRead))
+	assert.False(t, HasScope(WithScopes(context.Background(), []string{}), models.ScopeBalanceRead))
+}
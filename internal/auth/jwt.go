@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"time"
 	"yandex-diplom/internal/domain"
@@ -8,15 +10,38 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-const TTL = time.Hour * 24
+// AccessTTL срок жизни access-токена. Дольше живёт только refresh-токен сессии.
+const AccessTTL = 15 * time.Minute
 
-func CreateJWTToken(userID uint64) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+// RefreshTTL срок жизни refresh-токена, отсчитывается заново при каждом обновлении.
+const RefreshTTL = 30 * 24 * time.Hour
+
+func CreateJWTToken(userID uint64, role string, sessionID string) (string, error) {
+	jti, err := randomID()
+	if err != nil {
+		return "", domain.MakeError(err, domain.ErrInternal)
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
 		"userID": userID,
-		"exp":    time.Now().Add(TTL).Unix(),
-	})
+		"role":   role,
+		"sid":    sessionID,
+		"jti":    jti,
+		"iat":    now.Unix(),
+		"exp":    now.Add(AccessTTL).Unix(),
+	}
 
-	tokenString, err := token.SignedString([]byte(GetSecret()))
+	var tokenString string
+	if ks := currentKeys(); ks != nil {
+		key := ks.Signing()
+		token := jwt.NewWithClaims(key.Method, claims)
+		token.Header["kid"] = key.ID
+		tokenString, err = token.SignedString(key.Private)
+	} else {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		tokenString, err = token.SignedString(GetSecret())
+	}
 	if err != nil {
 		return "", domain.MakeError(err, domain.ErrInternal)
 	}
@@ -24,18 +49,40 @@ func CreateJWTToken(userID uint64) (string, error) {
 	return tokenString, nil
 }
 
+// ParseJWT проверяет подпись ключом из kid и алгоритм этого ключа. Без набора
+// ключей принимаются только HMAC-токены.
 func ParseJWT(tokenString string) (jwt.MapClaims, error) {
+	ks := currentKeys()
+
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return GetSecret(), nil
+		if ks == nil {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+			}
+			return GetSecret(), nil
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ks.Lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("%w %q", errUnknownKey, kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %q for key %q", token.Method.Alg(), kid)
+		}
+		return key.Public, nil
 	})
 
-	if err != nil || !token.Valid {
+	if err != nil {
 		return nil, err
 	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return nil, err
+		return nil, errors.New("unexpected claims type")
 	}
 
 	return claims, nil
@@ -11,18 +11,32 @@ func TestCreateAndParseJWT(t *testing.T) {
 	os.Setenv("SECRET", "testsecret")
 	defer os.Unsetenv("SECRET")
 
-	token, err := CreateJWTToken(42)
+	token, err := CreateJWTToken(42, "user", "sid-42")
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 
 	claims, err := ParseJWT(token)
 	assert.NoError(t, err)
 	assert.Equal(t, float64(42), claims["userID"])
+	assert.Equal(t, "sid-42", claims["sid"])
+	assert.NotEmpty(t, claims["jti"])
+}
+
+func TestNewRefreshToken(t *testing.T) {
+	token, hash, err := NewRefreshToken()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.NotEqual(t, token, hash)
+	assert.Equal(t, hash, HashRefreshToken(token))
+
+	other, _, err := NewRefreshToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
 }
 
 func TestParseJWT_InvalidSignature(t *testing.T) {
 	os.Setenv("SECRET", "secret1")
-	token, err := CreateJWTToken(99)
+	token, err := CreateJWTToken(99, "user", "sid-99")
 	assert.NoError(t, err)
 
 	os.Setenv("SECRET", "secret2")
@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key ключ подписи токенов. У ключей, оставленных только для проверки, Private пустой.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet текущий ключ подписи и все ключи, которыми ещё проверяются выданные токены.
+// При ротации новый ключ становится ключом подписи, а старый остаётся в проверочных,
+// пока не истекут подписанные им токены.
+type KeySet struct {
+	signing *Key
+	verify  map[string]*Key
+	order   []string
+}
+
+// NewKeySet собирает набор из ключа подписи и дополнительных ключей проверки.
+func NewKeySet(signing crypto.Signer, verify ...crypto.PublicKey) (*KeySet, error) {
+	key, err := newKey(signing.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.Private = signing
+
+	ks := &KeySet{signing: key, verify: make(map[string]*Key)}
+	ks.add(key)
+
+	for _, pub := range verify {
+		k, err := newKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		ks.add(k)
+	}
+
+	return ks, nil
+}
+
+// LoadKeySet читает PEM-файлы: закрытый ключ подписи и открытые ключи проверки.
+func LoadKeySet(privateFile string, publicFiles []string) (*KeySet, error) {
+	block, err := readPEM(privateFile)
+	if err != nil {
+		return nil, err
+	}
+	signing, err := parsePrivateKey(block)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", privateFile, err)
+	}
+
+	verify := make([]crypto.PublicKey, 0, len(publicFiles))
+	for _, file := range publicFiles {
+		block, err := readPEM(file)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := parsePublicKey(block)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		verify = append(verify, pub)
+	}
+
+	return NewKeySet(signing, verify...)
+}
+
+func (ks *KeySet) add(k *Key) {
+	if _, ok := ks.verify[k.ID]; ok {
+		return
+	}
+	ks.verify[k.ID] = k
+	ks.order = append(ks.order, k.ID)
+}
+
+// Signing текущий ключ подписи.
+func (ks *KeySet) Signing() *Key {
+	return ks.signing
+}
+
+// Lookup ключ проверки по kid из заголовка токена.
+func (ks *KeySet) Lookup(kid string) (*Key, bool) {
+	k, ok := ks.verify[kid]
+	return k, ok
+}
+
+func newKey(pub crypto.PublicKey) (*Key, error) {
+	var method jwt.SigningMethod
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, want RSA or Ed25519", pub)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+
+	return &Key{
+		ID:     base64.RawURLEncoding.EncodeToString(sum[:12]),
+		Method: method,
+		Public: pub,
+	}, nil
+}
+
+func readPEM(file string) (*pem.Block, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", file)
+	}
+	return block, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	var (
+		key any
+		err error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q, want a private key", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+// parsePublicKey принимает и закрытый ключ: для проверки берётся его открытая часть.
+func parsePublicKey(block *pem.Block) (crypto.PublicKey, error) {
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	signer, err := parsePrivateKey(block)
+	if err != nil {
+		return nil, err
+	}
+	return signer.Public(), nil
+}
+
+// JWK открытый ключ в формате RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS открытые ключи проверки для других сервисов.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(ks.order))}
+
+	for _, kid := range ks.order {
+		k := ks.verify[kid]
+		jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+
+		switch pub := k.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+var (
+	keysMu sync.RWMutex
+	keys   *KeySet
+)
+
+// UseKeys включает подпись токенов ключами ks. Без них токены подписываются
+// HS256 секретом SECRET, что допустимо только вне prod.
+func UseKeys(ks *KeySet) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	keys = ks
+}
+
+func currentKeys() *KeySet {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return keys
+}
+
+// PublicJWKS открытые ключи текущего набора; при подписи секретом список пуст.
+func PublicJWKS() JWKS {
+	ks := currentKeys()
+	if ks == nil {
+		return JWKS{Keys: []JWK{}}
+	}
+	return ks.JWKS()
+}
+
+var errUnknownKey = errors.New("unknown signing key")
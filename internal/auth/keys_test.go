@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useKeys(t *testing.T, ks *KeySet) {
+	t.Helper()
+	UseKeys(ks)
+	t.Cleanup(func() { UseKeys(nil) })
+}
+
+func writePEM(t *testing.T, typ string, der []byte) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600))
+	return file
+}
+
+func TestKeySet_SignAndVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		key  crypto.Signer
+		alg  string
+	}{
+		{"RS256", rsaKey, "RS256"},
+		{"EdDSA", edKey, "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := x509.MarshalPKCS8PrivateKey(tt.key)
+			require.NoError(t, err)
+
+			ks, err := LoadKeySet(writePEM(t, "PRIVATE KEY", der), nil)
+			require.NoError(t, err)
+			useKeys(t, ks)
+
+			token, err := CreateJWTToken(7, "user", "sid-7")
+			require.NoError(t, err)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.alg, parsed.Method.Alg())
+			assert.Equal(t, ks.Signing().ID, parsed.Header["kid"])
+
+			claims, err := ParseJWT(token)
+			require.NoError(t, err)
+			assert.Equal(t, float64(7), claims["userID"])
+
+			jwks := PublicJWKS()
+			require.Len(t, jwks.Keys, 1)
+			assert.Equal(t, tt.alg, jwks.Keys[0].Alg)
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	oldSet, err := NewKeySet(oldKey)
+	require.NoError(t, err)
+	useKeys(t, oldSet)
+	issued, err := CreateJWTToken(1, "user", "sid-1")
+	require.NoError(t, err)
+
+	// новый ключ подписывает, старый ещё проверяет
+	rotated, err := NewKeySet(newKey, oldKey.Public())
+	require.NoError(t, err)
+	UseKeys(rotated)
+
+	_, err = ParseJWT(issued)
+	require.NoError(t, err)
+	assert.Len(t, PublicJWKS().Keys, 2)
+
+	// старый ключ выведен из оборота
+	onlyNew, err := NewKeySet(newKey)
+	require.NoError(t, err)
+	UseKeys(onlyNew)
+
+	_, err = ParseJWT(issued)
+	assert.ErrorIs(t, err, errUnknownKey)
+}
+
+func TestParseJWT_RejectsForeignAlgorithms(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ks, err := NewKeySet(edKey)
+	require.NoError(t, err)
+
+	// HS256 токен с kid настоящего ключа: подпись открытым ключом как секретом не проходит
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"userID": 1})
+	hs.Header["kid"] = ks.Signing().ID
+	forged, err := hs.SignedString([]byte(edKey.Public().(ed25519.PublicKey)))
+	require.NoError(t, err)
+
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"userID": 1}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	useKeys(t, ks)
+	_, err = ParseJWT(forged)
+	assert.Error(t, err)
+	_, err = ParseJWT(none)
+	assert.Error(t, err)
+
+	// без набора ключей асимметричные токены не принимаются
+	signed, err := CreateJWTToken(1, "user", "sid-1")
+	require.NoError(t, err)
+	UseKeys(nil)
+	_, err = ParseJWT(signed)
+	assert.Error(t, err)
+	_, err = ParseJWT(none)
+	assert.Error(t, err)
+	assert.Empty(t, PublicJWKS().Keys)
+}
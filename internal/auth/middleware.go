@@ -3,13 +3,18 @@ package auth
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"yandex-diplom/internal/models"
 )
 
 type contextKey string
 
-const userKey = contextKey("user")
+const (
+	userKey    = contextKey("user")
+	roleKey    = contextKey("role")
+	sessionKey = contextKey("session")
+)
 
 func GetUserFromContext(ctx context.Context) *models.User {
 	val := ctx.Value(userKey)
@@ -19,20 +24,69 @@ func GetUserFromContext(ctx context.Context) *models.User {
 	return nil
 }
 
+func WithUser(ctx context.Context, user *models.User) context.Context {
+	return context.WithValue(ctx, userKey, user)
+}
+
+// GetRoleFromContext возвращает роль из claim "role" токена.
+func GetRoleFromContext(ctx context.Context) string {
+	role, _ := ctx.Value(roleKey).(string)
+	return role
+}
+
+func WithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, roleKey, role)
+}
+
+// GetSessionFromContext возвращает идентификатор сессии из claim "sid" токена.
+func GetSessionFromContext(ctx context.Context) string {
+	sid, _ := ctx.Value(sessionKey).(string)
+	return sid
+}
+
+func WithSession(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionKey, sessionID)
+}
+
 type UserProvider interface {
 	GetUserByID(ctx context.Context, id int64) (models.User, error)
+	SessionRevoked(ctx context.Context, sessionID string) bool
+}
+
+// TokenFromRequest достаёт access-токен из заголовка "Authorization: Bearer <jwt>",
+// а без него — из cookie. Заголовок с другой схемой не мешает cookie.
+func TokenFromRequest(r *http.Request) (string, bool) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if found && strings.EqualFold(scheme, BearerScheme) {
+			token = strings.TrimSpace(token)
+			return token, token != ""
+		}
+	}
+
+	cookie, err := r.Cookie(AccessCookie)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	return cookie.Value, true
 }
 
 func Middleware(userProvider UserProvider) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			cookie, err := r.Cookie("Authorization")
-			if err != nil {
+			// запрос уже опознан по API-ключу
+			if _, ok := GetScopesFromContext(r.Context()); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := TokenFromRequest(r)
+			if !ok {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
 
-			claims, err := ParseJWT(cookie.Value)
+			claims, err := ParseJWT(token)
 			if err != nil {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
@@ -51,14 +105,45 @@ func Middleware(userProvider UserProvider) func(http.Handler) http.Handler {
 			}
 			userID := int64(floatID)
 
+			sid, _ := claims["sid"].(string)
+			if sid == "" || userProvider.SessionRevoked(r.Context(), sid) {
+				http.Error(w, "Session revoked", http.StatusUnauthorized)
+				return
+			}
+
 			user, err := userProvider.GetUserByID(r.Context(), userID)
 			if err != nil {
 				http.Error(w, "User not found", http.StatusUnauthorized)
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), userKey, &user)
+			role, _ := claims["role"].(string)
+
+			ctx := WithUser(r.Context(), &user)
+			ctx = WithRole(ctx, role)
+			ctx = WithSession(ctx, sid)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// RequireRole пропускает запрос, только если роль есть и в токене, и у пользователя сейчас.
+// Отозванная роль перестаёт работать сразу, не дожидаясь истечения токена.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUserFromContext(r.Context())
+			if user == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if GetRoleFromContext(r.Context()) != role || user.Role != role {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
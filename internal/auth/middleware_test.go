@@ -11,8 +11,9 @@ import (
 )
 
 type mockUserProvider struct {
-	user *models.User
-	err  error
+	user    *models.User
+	err     error
+	revoked map[string]bool
 }
 
 func (m *mockUserProvider) GetUserByID(ctx context.Context, id int64) (models.User, error) {
@@ -22,6 +23,10 @@ func (m *mockUserProvider) GetUserByID(ctx context.Context, id int64) (models.Us
 	return *m.user, nil
 }
 
+func (m *mockUserProvider) SessionRevoked(ctx context.Context, sessionID string) bool {
+	return m.revoked[sessionID]
+}
+
 func TestMiddleware_NoCookie(t *testing.T) {
 	provider := &mockUserProvider{}
 	middleware := Middleware(provider)
@@ -37,7 +42,7 @@ func TestMiddleware_NoCookie(t *testing.T) {
 }
 
 func TestMiddleware_ValidToken(t *testing.T) {
-	token, err := CreateJWTToken(123)
+	token, err := CreateJWTToken(123, "user", "sid-1")
 	assert.NoError(t, err)
 
 	provider := &mockUserProvider{
@@ -51,12 +56,136 @@ func TestMiddleware_ValidToken(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	var gotUser *models.User
+	var gotSession string
 	middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		gotUser = GetUserFromContext(r.Context())
+		gotSession = GetSessionFromContext(r.Context())
 	})).ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.NotNil(t, gotUser)
 	assert.Equal(t, uint64(123), gotUser.ID)
 	assert.Equal(t, "TestUser", gotUser.Login)
+	assert.Equal(t, "sid-1", gotSession)
+}
+
+func TestTokenFromRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		cookie string
+		want   string
+		ok     bool
+	}{
+		{"bearer", "Bearer abc", "", "abc", true},
+		{"bearer case insensitive", "bearer abc", "", "abc", true},
+		{"bearer wins over cookie", "Bearer abc", "def", "abc", true},
+		{"cookie", "", "def", "def", true},
+		{"other scheme falls back to cookie", "Basic xyz", "def", "def", true},
+		{"empty bearer", "Bearer ", "def", "", false},
+		{"nothing", "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: AccessCookie, Value: tt.cookie})
+			}
+
+			got, ok := TokenFromRequest(req)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMiddleware_BearerToken(t *testing.T) {
+	token, err := CreateJWTToken(123, "user", "sid-1")
+	assert.NoError(t, err)
+
+	provider := &mockUserProvider{user: &models.User{ID: 123, Login: "TestUser"}}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	called := false
+	Middleware(provider)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, called)
+}
+
+func TestMiddleware_RevokedSession(t *testing.T) {
+	tests := []struct {
+		name string
+		sid  string
+	}{
+		{"revoked", "sid-1"},
+		{"no session", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := CreateJWTToken(123, "user", tt.sid)
+			assert.NoError(t, err)
+
+			provider := &mockUserProvider{
+				user:    &models.User{ID: 123, Login: "TestUser"},
+				revoked: map[string]bool{"sid-1": true},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: "Authorization", Value: token})
+			w := httptest.NewRecorder()
+
+			Middleware(provider)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Errorf("handler should not be called")
+			})).ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name       string
+		claimRole  string
+		userRole   string
+		wantStatus int
+	}{
+		{"admin", "admin", "admin", http.StatusOK},
+		{"plain user", "user", "user", http.StatusForbidden},
+		{"role revoked", "admin", "user", http.StatusForbidden},
+		{"role not in token", "user", "admin", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := CreateJWTToken(1, tt.claimRole, "sid-1")
+			assert.NoError(t, err)
+
+			provider := &mockUserProvider{
+				user: &models.User{ID: 1, Login: "TestUser", Role: tt.userRole},
+			}
+
+			h := Middleware(provider)(RequireRole("admin")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: "Authorization", Value: token})
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
 }
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type RevocationStore interface {
+	// ListRevokedSessions сессии, отозванные после since.
+	ListRevokedSessions(ctx context.Context, since time.Time) ([]string, error)
+}
+
+// Revocations кэш отозванных сессий. Список перечитывается из хранилища не чаще
+// раза в refresh, поэтому проверка токена не ходит в базу на каждый запрос.
+// Отзыв в этом же процессе виден сразу, в других экземплярах — после перечитывания.
+type Revocations struct {
+	store   RevocationStore
+	refresh time.Duration
+	now     func() time.Time
+
+	mu       sync.Mutex
+	revoked  map[string]time.Time
+	loadedAt time.Time
+	loading  bool
+}
+
+func NewRevocations(store RevocationStore, refresh time.Duration) *Revocations {
+	return &Revocations{
+		store:   store,
+		refresh: refresh,
+		now:     time.Now,
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// Revoke добавляет сессию в кэш, не дожидаясь перечитывания.
+func (r *Revocations) Revoke(sessionID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revoked[sessionID] = r.now()
+}
+
+// Revoked проверяет сессию по кэшу. Перечитывает список только один вызов,
+// остальные в это время работают по прежнему списку и не ждут базу.
+func (r *Revocations) Revoked(ctx context.Context, sessionID string) bool {
+	r.mu.Lock()
+	now := r.now()
+	stale := !r.loading && now.Sub(r.loadedAt) >= r.refresh
+	if stale {
+		r.loading = true
+	}
+	r.mu.Unlock()
+
+	if stale {
+		r.reload(ctx, now)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.revoked[sessionID]
+	return ok
+}
+
+// reload достаточно сессий, отозванных не раньше AccessTTL назад: более старые
+// access-токены уже истекли сами. Запрос к базе идёт без блокировки.
+func (r *Revocations) reload(ctx context.Context, now time.Time) {
+	since := now.Add(-AccessTTL)
+	ids, err := r.store.ListRevokedSessions(ctx, since)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.loading = false
+	r.loadedAt = now
+	if err != nil {
+		// при недоступной базе работаем по прежнему списку
+		return
+	}
+
+	revoked := make(map[string]time.Time, len(ids))
+	for _, id := range ids {
+		revoked[id] = now
+	}
+	// отозванные здесь же могли ещё не попасть в выборку
+	for id, at := range r.revoked {
+		if _, ok := revoked[id]; !ok && at.After(since) {
+			revoked[id] = at
+		}
+	}
+	r.revoked = revoked
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubRevocationStore struct {
+	ids   []string
+	err   error
+	calls int
+}
+
+func (s *stubRevocationStore) ListRevokedSessions(ctx context.Context, since time.Time) ([]string, error) {
+	s.calls++
+	return s.ids, s.err
+}
+
+func TestRevocations(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := &stubRevocationStore{ids: []string{"a"}}
+
+	r := NewRevocations(store, time.Minute)
+	r.now = func() time.Time { return now }
+
+	assert.True(t, r.Revoked(ctx, "a"))
+	assert.False(t, r.Revoked(ctx, "b"))
+	assert.Equal(t, 1, store.calls, "список перечитывается не чаще refresh")
+
+	r.Revoke("b")
+	assert.True(t, r.Revoked(ctx, "b"))
+
+	// база недоступна: остаётся прежний список
+	store.err = errors.New("db down")
+	now = now.Add(time.Minute)
+	assert.True(t, r.Revoked(ctx, "a"))
+	assert.True(t, r.Revoked(ctx, "b"))
+	assert.Equal(t, 2, store.calls)
+
+	// локальный отзыв старше AccessTTL забывается, access-токен уже истёк
+	store.err = nil
+	store.ids = nil
+	now = now.Add(AccessTTL)
+	assert.False(t, r.Revoked(ctx, "a"))
+	assert.False(t, r.Revoked(ctx, "b"))
+}
+
+type blockingRevocationStore struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingRevocationStore) ListRevokedSessions(ctx context.Context, since time.Time) ([]string, error) {
+	close(s.started)
+	<-s.release
+	return []string{"a"}, nil
+}
+
+func TestRevocations_ReloadDoesNotBlock(t *testing.T) {
+	ctx := context.Background()
+	store := &blockingRevocationStore{started: make(chan struct{}), release: make(chan struct{})}
+	r := NewRevocations(store, time.Minute)
+	r.Revoke("b")
+
+	loaded := make(chan bool)
+	go func() { loaded <- r.Revoked(ctx, "a") }()
+	<-store.started
+
+	// пока идёт запрос к базе, проверки работают по прежнему списку
+	assert.True(t, r.Revoked(ctx, "b"))
+	assert.False(t, r.Revoked(ctx, "a"))
+
+	close(store.release)
+	assert.True(t, <-loaded)
+	assert.True(t, r.Revoked(ctx, "a"))
+	assert.True(t, r.Revoked(ctx, "b"))
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const (
+	// AccessCookie cookie с access-токеном.
+	AccessCookie = "Authorization"
+	// RefreshCookie cookie с refresh-токеном, отправляется только на эндпоинты /api/user/token.
+	RefreshCookie = "Refresh"
+	// RefreshPath путь, на который браузер отправляет RefreshCookie.
+	RefreshPath = "/api/user/token"
+	// BearerScheme схема заголовка Authorization для клиентов без cookie.
+	BearerScheme = "Bearer"
+)
+
+// NewSessionID случайный идентификатор сессии, он же claim "sid" access-токена.
+func NewSessionID() (string, error) {
+	return randomID()
+}
+
+// NewRefreshToken возвращает refresh-токен для клиента и его хэш для хранения.
+// Сам токен на сервере не сохраняется.
+func NewRefreshToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashRefreshToken(token), nil
+}
+
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/caarlos0/env"
 )
@@ -15,6 +16,30 @@ func NewDefaultConfig() initConfig {
 		Accrual:        "cmd/accrual/accrual_linux_amd64",
 		Environment:    "dev",
 		AccuralAddress: "http://localhost:8085",
+		Storage:        StoragePostgres,
+
+		AccrualTimeout:          5 * time.Second,
+		AccrualBreakerThreshold: 5,
+		AccrualBreakerCooldown:  30 * time.Second,
+
+		OrderRetryBase:   10 * time.Second,
+		OrderRetryMax:    30 * time.Minute,
+		OrderMaxAttempts: 50,
+		OrderMaxAge:      7 * 24 * time.Hour,
+
+		WorkersMin:                   2,
+		WorkersMax:                   8,
+		WorkersScaleInterval:         5 * time.Second,
+		OrderBatchSize:               30,
+		OrderFetchNewInterval:        5 * time.Second,
+		OrderFetchProcessingInterval: 3 * time.Second,
+		BalanceInterval:              30 * time.Second,
+		ReconcileInterval:            10 * time.Minute,
+		ReconcileBatchSize:           500,
+
+		LoginMaxAttempts:   10,
+		LoginIPMaxAttempts: 100,
+		LoginLockout:       15 * time.Minute,
 	}
 }
 
@@ -32,6 +57,31 @@ func makeConfig(fs *flag.FlagSet, args []string) (Config, error) {
 	fs.StringVar(&defaultCfg.Accrual, "r", defaultCfg.Accrual, "Path to accural app")
 	fs.StringVar(&defaultCfg.Environment, "e", defaultCfg.Environment, "Environment")
 	fs.StringVar(&defaultCfg.AccuralAddress, "z", defaultCfg.AccuralAddress, "Accurual server address")
+	fs.BoolVar(&defaultCfg.ReconcileRepair, "reconcile-repair", defaultCfg.ReconcileRepair, "Repair balances that differ from the ledger")
+	fs.StringVar(&defaultCfg.Storage, "storage", defaultCfg.Storage, "Storage backend: postgres or memory")
+	fs.DurationVar(&defaultCfg.AccrualTimeout, "accrual-timeout", defaultCfg.AccrualTimeout, "Timeout of a single request to the accrual system")
+	fs.IntVar(&defaultCfg.AccrualBreakerThreshold, "accrual-breaker-threshold", defaultCfg.AccrualBreakerThreshold, "Consecutive accrual failures that open the circuit breaker")
+	fs.DurationVar(&defaultCfg.AccrualBreakerCooldown, "accrual-breaker-cooldown", defaultCfg.AccrualBreakerCooldown, "How long the accrual circuit breaker stays open")
+	fs.IntVar(&defaultCfg.AccrualRPM, "accrual-rpm", defaultCfg.AccrualRPM, "Requests per minute to the accrual system, 0 means unlimited until the first 429")
+	fs.StringVar(&defaultCfg.AccrualWebhookSecret, "accrual-webhook-secret", defaultCfg.AccrualWebhookSecret, "HMAC secret of accrual callbacks, empty disables the callback endpoint")
+	fs.StringVar(&defaultCfg.JWTPrivateKey, "jwt-private-key", defaultCfg.JWTPrivateKey, "PEM file with the RSA or Ed25519 key that signs tokens, required in prod")
+	fs.StringVar(&defaultCfg.JWTPublicKeys, "jwt-public-keys", defaultCfg.JWTPublicKeys, "Comma-separated PEM files of previous keys still accepted for verification")
+	fs.IntVar(&defaultCfg.LoginMaxAttempts, "login-max-attempts", defaultCfg.LoginMaxAttempts, "Failed logins per account before it is locked")
+	fs.IntVar(&defaultCfg.LoginIPMaxAttempts, "login-ip-max-attempts", defaultCfg.LoginIPMaxAttempts, "Failed logins per client address before it is locked")
+	fs.DurationVar(&defaultCfg.LoginLockout, "login-lockout", defaultCfg.LoginLockout, "How long a login or address stays locked")
+	fs.DurationVar(&defaultCfg.OrderRetryBase, "order-retry-base", defaultCfg.OrderRetryBase, "First delay between accrual polls of an order")
+	fs.DurationVar(&defaultCfg.OrderRetryMax, "order-retry-max", defaultCfg.OrderRetryMax, "Maximum delay between accrual polls of an order")
+	fs.IntVar(&defaultCfg.OrderMaxAttempts, "order-max-attempts", defaultCfg.OrderMaxAttempts, "Accrual polls before an order is dead-lettered, 0 means unlimited")
+	fs.DurationVar(&defaultCfg.OrderMaxAge, "order-max-age", defaultCfg.OrderMaxAge, "Order age after which it is dead-lettered, 0 means unlimited")
+	fs.IntVar(&defaultCfg.WorkersMin, "workers-min", defaultCfg.WorkersMin, "Minimum number of job workers")
+	fs.IntVar(&defaultCfg.WorkersMax, "workers-max", defaultCfg.WorkersMax, "Maximum number of job workers")
+	fs.DurationVar(&defaultCfg.WorkersScaleInterval, "workers-scale-interval", defaultCfg.WorkersScaleInterval, "How often the worker pool is resized")
+	fs.IntVar(&defaultCfg.OrderBatchSize, "order-batch-size", defaultCfg.OrderBatchSize, "Orders fetched for polling at once")
+	fs.DurationVar(&defaultCfg.OrderFetchNewInterval, "order-fetch-new-interval", defaultCfg.OrderFetchNewInterval, "How often new orders are fetched")
+	fs.DurationVar(&defaultCfg.OrderFetchProcessingInterval, "order-fetch-processing-interval", defaultCfg.OrderFetchProcessingInterval, "How often orders due for a repeated poll are fetched")
+	fs.DurationVar(&defaultCfg.BalanceInterval, "balance-interval", defaultCfg.BalanceInterval, "How often missing balance entries are restored")
+	fs.DurationVar(&defaultCfg.ReconcileInterval, "reconcile-interval", defaultCfg.ReconcileInterval, "How often balances are reconciled with the ledger")
+	fs.IntVar(&defaultCfg.ReconcileBatchSize, "reconcile-batch-size", defaultCfg.ReconcileBatchSize, "Users checked per reconcile batch")
 
 	if err := fs.Parse(args); err != nil {
 		return Config{}, fmt.Errorf("%s: %w", op, err)
@@ -42,8 +92,6 @@ func makeConfig(fs *flag.FlagSet, args []string) (Config, error) {
 		return Config{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	fmt.Printf("%+v", cfg)
-
 	return cfg, nil
 }
 
@@ -12,6 +12,11 @@ const (
 	maxPort = 65535
 )
 
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
 func metamorphosis(cfg initConfig) (Config, error) {
 	op := "config.metamorphosis"
 
@@ -85,5 +90,85 @@ func metamorphosis(cfg initConfig) (Config, error) {
 		return Config{}, fmt.Errorf("%s: unknown environment %q", op, cfg.Environment)
 	}
 
-	return Config{Address: address, DatabaseURI: database, Accrual: cfg.Accrual, Environment: cfg.Environment, AccuralAddress: accurualAddress}, nil
+	if cfg.Environment == "prod" && cfg.JWTPrivateKey == "" {
+		return Config{}, fmt.Errorf("%s: jwt private key is required in prod", op)
+	}
+	var publicKeys []string
+	for _, file := range strings.Split(cfg.JWTPublicKeys, ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			publicKeys = append(publicKeys, file)
+		}
+	}
+	if len(publicKeys) > 0 && cfg.JWTPrivateKey == "" {
+		return Config{}, fmt.Errorf("%s: jwt public keys are set without a private key", op)
+	}
+
+	if cfg.LoginMaxAttempts < 0 || cfg.LoginIPMaxAttempts < 0 || cfg.LoginLockout < 0 {
+		return Config{}, fmt.Errorf("%s: login limits must not be negative", op)
+	}
+
+	if cfg.Storage == "" {
+		cfg.Storage = StoragePostgres
+	}
+	if cfg.Storage != StoragePostgres && cfg.Storage != StorageMemory {
+		return Config{}, fmt.Errorf("%s: unknown storage %q", op, cfg.Storage)
+	}
+
+	if cfg.AccrualTimeout < 0 || cfg.AccrualBreakerCooldown < 0 || cfg.AccrualBreakerThreshold < 0 || cfg.AccrualRPM < 0 {
+		return Config{}, fmt.Errorf("%s: accrual timeouts, breaker threshold and rpm must not be negative", op)
+	}
+
+	if cfg.OrderRetryBase < 0 || cfg.OrderRetryMax < 0 || cfg.OrderMaxAttempts < 0 || cfg.OrderMaxAge < 0 {
+		return Config{}, fmt.Errorf("%s: order retry settings must not be negative", op)
+	}
+	if cfg.OrderRetryBase > 0 && cfg.OrderRetryMax > 0 && cfg.OrderRetryBase > cfg.OrderRetryMax {
+		return Config{}, fmt.Errorf("%s: order retry base %s is greater than max %s", op, cfg.OrderRetryBase, cfg.OrderRetryMax)
+	}
+
+	if cfg.WorkersMin < 0 || cfg.WorkersMax < 0 || cfg.WorkersScaleInterval < 0 || cfg.OrderBatchSize < 0 ||
+		cfg.OrderFetchNewInterval < 0 || cfg.OrderFetchProcessingInterval < 0 || cfg.BalanceInterval < 0 ||
+		cfg.ReconcileInterval < 0 || cfg.ReconcileBatchSize < 0 {
+		return Config{}, fmt.Errorf("%s: worker settings must not be negative", op)
+	}
+	if cfg.WorkersMin > 0 && cfg.WorkersMax > 0 && cfg.WorkersMin > cfg.WorkersMax {
+		return Config{}, fmt.Errorf("%s: workers min %d is greater than max %d", op, cfg.WorkersMin, cfg.WorkersMax)
+	}
+
+	return Config{
+		Address:         address,
+		DatabaseURI:     database,
+		Accrual:         cfg.Accrual,
+		Environment:     cfg.Environment,
+		AccuralAddress:  accurualAddress,
+		ReconcileRepair: cfg.ReconcileRepair,
+		Storage:         cfg.Storage,
+
+		AccrualTimeout:          cfg.AccrualTimeout,
+		AccrualBreakerThreshold: cfg.AccrualBreakerThreshold,
+		AccrualBreakerCooldown:  cfg.AccrualBreakerCooldown,
+		AccrualRPM:              cfg.AccrualRPM,
+		AccrualWebhookSecret:    cfg.AccrualWebhookSecret,
+
+		JWTPrivateKey: cfg.JWTPrivateKey,
+		JWTPublicKeys: publicKeys,
+
+		LoginMaxAttempts:   cfg.LoginMaxAttempts,
+		LoginIPMaxAttempts: cfg.LoginIPMaxAttempts,
+		LoginLockout:       cfg.LoginLockout,
+
+		OrderRetryBase:   cfg.OrderRetryBase,
+		OrderRetryMax:    cfg.OrderRetryMax,
+		OrderMaxAttempts: cfg.OrderMaxAttempts,
+		OrderMaxAge:      cfg.OrderMaxAge,
+
+		WorkersMin:                   cfg.WorkersMin,
+		WorkersMax:                   cfg.WorkersMax,
+		WorkersScaleInterval:         cfg.WorkersScaleInterval,
+		OrderBatchSize:               cfg.OrderBatchSize,
+		OrderFetchNewInterval:        cfg.OrderFetchNewInterval,
+		OrderFetchProcessingInterval: cfg.OrderFetchProcessingInterval,
+		BalanceInterval:              cfg.BalanceInterval,
+		ReconcileInterval:            cfg.ReconcileInterval,
+		ReconcileBatchSize:           cfg.ReconcileBatchSize,
+	}, nil
 }
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -131,6 +132,91 @@ func TestMetamorphosis(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "memory storage",
+			cfg: initConfig{
+				Address:        "http://localhost:8080",
+				DatabaseURI:    "http://test.db",
+				Accrual:        "/bin/accrual",
+				Environment:    "dev",
+				AccuralAddress: "http://accrual.local:9000",
+				Storage:        StorageMemory,
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown storage",
+			cfg: initConfig{
+				Address:        "http://localhost:8080",
+				DatabaseURI:    "http://test.db",
+				Accrual:        "/bin/accrual",
+				Environment:    "dev",
+				AccuralAddress: "http://accrual.local:9000",
+				Storage:        "redis",
+			},
+			wantErr: true,
+		},
+		{
+			name: "workers min greater than max",
+			cfg: initConfig{
+				Address:        "http://localhost:8080",
+				DatabaseURI:    "http://test.db",
+				Accrual:        "/bin/accrual",
+				Environment:    "dev",
+				AccuralAddress: "http://accrual.local:9000",
+				WorkersMin:     8,
+				WorkersMax:     2,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative batch size",
+			cfg: initConfig{
+				Address:        "http://localhost:8080",
+				DatabaseURI:    "http://test.db",
+				Accrual:        "/bin/accrual",
+				Environment:    "dev",
+				AccuralAddress: "http://accrual.local:9000",
+				OrderBatchSize: -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "prod without jwt key",
+			cfg: initConfig{
+				Address:        "http://localhost:8080",
+				DatabaseURI:    "http://test.db",
+				Accrual:        "/bin/accrual",
+				Environment:    "prod",
+				AccuralAddress: "http://accrual.local:9000",
+			},
+			wantErr: true,
+		},
+		{
+			name: "prod with jwt key",
+			cfg: initConfig{
+				Address:        "http://localhost:8080",
+				DatabaseURI:    "http://test.db",
+				Accrual:        "/bin/accrual",
+				Environment:    "prod",
+				AccuralAddress: "http://accrual.local:9000",
+				JWTPrivateKey:  "/etc/gophermart/jwt.pem",
+				JWTPublicKeys:  "/etc/gophermart/old.pem, ",
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative login lockout",
+			cfg: initConfig{
+				Address:        "http://localhost:8080",
+				DatabaseURI:    "http://test.db",
+				Accrual:        "/bin/accrual",
+				Environment:    "dev",
+				AccuralAddress: "http://accrual.local:9000",
+				LoginLockout:   -time.Minute,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
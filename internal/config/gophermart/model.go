@@ -1,19 +1,86 @@
 package gophermart
 
-import "net/url"
+import (
+	"net/url"
+	"time"
+)
 
 type initConfig struct {
-	Address        string `env:"RUN_ADDRESS"`
-	DatabaseURI    string `env:"DATABASE_URI"`
-	Accrual        string `env:"ACCURUAL_ADDRESS"`
-	Environment    string `env:"ENVIRONMENT"`
-	AccuralAddress string `env:"ACCRUAL_SYSTEM_ADDRESS"`
+	Address         string `env:"RUN_ADDRESS"`
+	DatabaseURI     string `env:"DATABASE_URI"`
+	Accrual         string `env:"ACCURUAL_ADDRESS"`
+	Environment     string `env:"ENVIRONMENT"`
+	AccuralAddress  string `env:"ACCRUAL_SYSTEM_ADDRESS"`
+	ReconcileRepair bool   `env:"RECONCILE_REPAIR"`
+	Storage         string `env:"STORAGE"`
+
+	AccrualTimeout          time.Duration `env:"ACCRUAL_TIMEOUT"`
+	AccrualBreakerThreshold int           `env:"ACCRUAL_BREAKER_THRESHOLD"`
+	AccrualBreakerCooldown  time.Duration `env:"ACCRUAL_BREAKER_COOLDOWN"`
+	AccrualRPM              int           `env:"ACCRUAL_RPM"`
+	AccrualWebhookSecret    string        `env:"ACCRUAL_WEBHOOK_SECRET"`
+
+	JWTPrivateKey string `env:"JWT_PRIVATE_KEY_FILE"`
+	JWTPublicKeys string `env:"JWT_PUBLIC_KEY_FILES"`
+
+	LoginMaxAttempts   int           `env:"LOGIN_MAX_ATTEMPTS"`
+	LoginIPMaxAttempts int           `env:"LOGIN_IP_MAX_ATTEMPTS"`
+	LoginLockout       time.Duration `env:"LOGIN_LOCKOUT"`
+
+	OrderRetryBase   time.Duration `env:"ORDER_RETRY_BASE"`
+	OrderRetryMax    time.Duration `env:"ORDER_RETRY_MAX"`
+	OrderMaxAttempts int           `env:"ORDER_MAX_ATTEMPTS"`
+	OrderMaxAge      time.Duration `env:"ORDER_MAX_AGE"`
+
+	WorkersMin                   int           `env:"WORKERS_MIN"`
+	WorkersMax                   int           `env:"WORKERS_MAX"`
+	WorkersScaleInterval         time.Duration `env:"WORKERS_SCALE_INTERVAL"`
+	OrderBatchSize               int           `env:"ORDER_BATCH_SIZE"`
+	OrderFetchNewInterval        time.Duration `env:"ORDER_FETCH_NEW_INTERVAL"`
+	OrderFetchProcessingInterval time.Duration `env:"ORDER_FETCH_PROCESSING_INTERVAL"`
+	BalanceInterval              time.Duration `env:"BALANCE_INTERVAL"`
+	ReconcileInterval            time.Duration `env:"RECONCILE_INTERVAL"`
+	ReconcileBatchSize           int           `env:"RECONCILE_BATCH_SIZE"`
 }
 
 type Config struct {
-	Address        *url.URL
-	DatabaseURI    *url.URL
-	Accrual        string
-	Environment    string
-	AccuralAddress *url.URL
+	Address         *url.URL
+	DatabaseURI     *url.URL
+	Accrual         string
+	Environment     string
+	AccuralAddress  *url.URL
+	ReconcileRepair bool
+	Storage         string
+
+	AccrualTimeout          time.Duration
+	AccrualBreakerThreshold int
+	AccrualBreakerCooldown  time.Duration
+	AccrualRPM              int
+	// AccrualWebhookSecret общий секрет подписи уведомлений, пустой отключает приём.
+	AccrualWebhookSecret string
+
+	// JWTPrivateKey PEM-файл ключа подписи токенов (RSA или Ed25519), обязателен в prod.
+	JWTPrivateKey string
+	// JWTPublicKeys PEM-файлы прежних ключей, которыми ещё проверяются токены.
+	JWTPublicKeys []string
+
+	// LoginMaxAttempts и LoginIPMaxAttempts неудачных входов на логин и на адрес до блокировки на LoginLockout.
+	LoginMaxAttempts   int
+	LoginIPMaxAttempts int
+	LoginLockout       time.Duration
+
+	OrderRetryBase   time.Duration
+	OrderRetryMax    time.Duration
+	OrderMaxAttempts int
+	OrderMaxAge      time.Duration
+
+	WorkersMin                   int
+	WorkersMax                   int
+	WorkersScaleInterval         time.Duration
+	OrderBatchSize               int
+	OrderFetchNewInterval        time.Duration
+	OrderFetchProcessingInterval time.Duration
+	BalanceInterval              time.Duration
+	ReconcileInterval            time.Duration
+	ReconcileBatchSize           int
 }
@@ -20,10 +20,20 @@ var (
 	ErrOrderCreatedByOtherUser = errors.New("order aldready created by other user")
 	ErrNoContent               = errors.New("no content")
 	ErrPaymentRequired         = errors.New("payment required")
+	ErrIdempotencyKeyReused    = errors.New("idempotency key reused with different payload")
+	ErrIdempotencyInProgress   = errors.New("request with that idempotency key is in progress")
+	ErrEntryNotFound           = errors.New("balance entry not found")
+	ErrEntryAlreadyReversed    = errors.New("balance entry already reversed")
+	ErrOrderNotFound           = errors.New("order not found")
+	ErrOrderFinalized          = errors.New("order already processed")
+	ErrInvalidToken            = errors.New("invalid or expired token")
+	ErrAPIKeyNotFound          = errors.New("api key not found")
 )
 
 type TooManyRequestsError struct {
 	RetryAfter time.Duration
+	// Limit бюджет запросов в минуту из ответа сервиса, 0 если неизвестен.
+	Limit int
 }
 
 func (e *TooManyRequestsError) Error() string {
@@ -0,0 +1,158 @@
+package gophermart
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+
+	"go.uber.org/zap"
+)
+
+const maxReasonLength = 1024
+
+func validateReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", domain.MakeError(fmt.Errorf("reason must be non-empty"), domain.ErrInvalidPayload)
+	}
+	if len(reason) > maxReasonLength {
+		return "", domain.MakeError(fmt.Errorf("reason must be shorter than %d characters", maxReasonLength), domain.ErrInvalidPayload)
+	}
+	return reason, nil
+}
+
+func (m *Mart) GetUserInfo(ctx context.Context, login string) (models.UserInfo, error) {
+	op := "gophermart.GetUserInfo"
+
+	user, err := m.db.GetUserByLogin(ctx, login)
+	if err != nil {
+		return models.UserInfo{}, domain.Wrap(op, err)
+	}
+
+	balance, err := m.db.GetBalance(ctx, user.ID)
+	if err != nil {
+		return models.UserInfo{}, domain.Wrap(op, err)
+	}
+
+	return models.UserInfo{ID: user.ID, Login: user.Login, Role: user.Role, Balance: balance}, nil
+}
+
+func (m *Mart) GetUserLedger(ctx context.Context, login string) ([]models.BalanceEntry, error) {
+	op := "gophermart.GetUserLedger"
+
+	user, err := m.db.GetUserByLogin(ctx, login)
+	if err != nil {
+		return []models.BalanceEntry{}, domain.Wrap(op, err)
+	}
+
+	entries, err := m.db.GetBalanceEntries(ctx, user.ID)
+	if err != nil {
+		return []models.BalanceEntry{}, domain.Wrap(op, err)
+	}
+
+	return entries, nil
+}
+
+func (m *Mart) ResetOrder(ctx context.Context, number string) error {
+	op := "gophermart.ResetOrder"
+
+	if err := m.db.ResetOrder(ctx, number); err != nil {
+		return domain.Wrap(op, err)
+	}
+
+	m.log.Info("order reset to NEW", zap.String("order", number))
+	return nil
+}
+
+// GetDeadLetterOrders заказы, снятые с опроса системы начислений, новые первыми.
+func (m *Mart) GetDeadLetterOrders(ctx context.Context, limit int) ([]models.DeadLetterOrder, error) {
+	op := "gophermart.GetDeadLetterOrders"
+
+	if limit == 0 {
+		limit = DefaultPageLimit
+	}
+	if limit < 0 || limit > MaxPageLimit {
+		return nil, domain.Wrap(op, domain.MakeError(fmt.Errorf("limit must be between 1 and %d", MaxPageLimit), domain.ErrInvalidPayload))
+	}
+
+	orders, err := m.db.GetDeadLetterOrders(ctx, limit)
+	if err != nil {
+		return nil, domain.Wrap(op, err)
+	}
+
+	return orders, nil
+}
+
+func (m *Mart) InvalidateOrder(ctx context.Context, number string) error {
+	op := "gophermart.InvalidateOrder"
+
+	if err := m.db.InvalidateOrder(ctx, number); err != nil {
+		return domain.Wrap(op, err)
+	}
+
+	m.log.Info("order marked INVALID", zap.String("order", number))
+	return nil
+}
+
+// AdjustBalance начисляет (Amount > 0) или списывает (Amount < 0) баллы вручную.
+func (m *Mart) AdjustBalance(ctx context.Context, login string, adj models.Adjustment) (models.BalanceEntry, error) {
+	op := "gophermart.AdjustBalance"
+
+	if adj.Amount == 0 {
+		return models.BalanceEntry{}, domain.Wrap(op, domain.MakeError(fmt.Errorf("amount must be non-zero"), domain.ErrInvalidPayload))
+	}
+
+	reason, err := validateReason(adj.Reason)
+	if err != nil {
+		return models.BalanceEntry{}, domain.Wrap(op, err)
+	}
+	adj.Reason = reason
+
+	user, err := m.db.GetUserByLogin(ctx, login)
+	if err != nil {
+		return models.BalanceEntry{}, domain.Wrap(op, err)
+	}
+	adj.UserID = user.ID
+
+	entry, err := m.db.AdjustBalance(ctx, adj)
+	if err != nil {
+		return models.BalanceEntry{}, domain.Wrap(op, err)
+	}
+
+	m.log.Info("balance adjusted",
+		zap.Uint64("user", adj.UserID),
+		zap.Uint64("operator", adj.OperatorID),
+		zap.Stringer("amount", adj.Amount),
+		zap.Int64("entry", entry.ID))
+
+	return entry, nil
+}
+
+// ReverseBalanceEntry отменяет запись журнала встречной корректировкой.
+func (m *Mart) ReverseBalanceEntry(ctx context.Context, rev models.Reversal) (models.BalanceEntry, error) {
+	op := "gophermart.ReverseBalanceEntry"
+
+	if rev.EntryID <= 0 {
+		return models.BalanceEntry{}, domain.Wrap(op, domain.MakeError(fmt.Errorf("invalid entry id"), domain.ErrInvalidPayload))
+	}
+
+	reason, err := validateReason(rev.Reason)
+	if err != nil {
+		return models.BalanceEntry{}, domain.Wrap(op, err)
+	}
+	rev.Reason = reason
+
+	entry, err := m.db.ReverseBalanceEntry(ctx, rev)
+	if err != nil {
+		return models.BalanceEntry{}, domain.Wrap(op, err)
+	}
+
+	m.log.Info("balance entry reversed",
+		zap.Int64("reversed", rev.EntryID),
+		zap.Uint64("operator", rev.OperatorID),
+		zap.Int64("entry", entry.ID))
+
+	return entry, nil
+}
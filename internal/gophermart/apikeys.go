@@ -0,0 +1,112 @@
+package gophermart
+
+import (
+	"context"
+	"yandex-diplom/internal/auth"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// CreateAPIKey пользователь выпускает ключ для себя.
+func (m *Mart) CreateAPIKey(ctx context.Context, user models.User, req models.APIKeyRequest) (models.IssuedAPIKey, error) {
+	op := "gophermart.CreateAPIKey"
+
+	req.UserID = user.ID
+	req.CreatedBy = user.ID
+
+	issued, err := m.issueAPIKey(ctx, req)
+	if err != nil {
+		return models.IssuedAPIKey{}, domain.Wrap(op, err)
+	}
+
+	return issued, nil
+}
+
+// IssueAPIKey администратор выпускает ключ для пользователя login.
+func (m *Mart) IssueAPIKey(ctx context.Context, login string, req models.APIKeyRequest) (models.IssuedAPIKey, error) {
+	op := "gophermart.IssueAPIKey"
+
+	user, err := m.db.GetUserByLogin(ctx, login)
+	if err != nil {
+		return models.IssuedAPIKey{}, domain.Wrap(op, err)
+	}
+	req.UserID = user.ID
+
+	issued, err := m.issueAPIKey(ctx, req)
+	if err != nil {
+		return models.IssuedAPIKey{}, domain.Wrap(op, err)
+	}
+
+	return issued, nil
+}
+
+func (m *Mart) issueAPIKey(ctx context.Context, req models.APIKeyRequest) (models.IssuedAPIKey, error) {
+	req, err := ValidateAPIKeyRequest(req)
+	if err != nil {
+		return models.IssuedAPIKey{}, err
+	}
+
+	key, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return models.IssuedAPIKey{}, domain.MakeError(err, domain.ErrInternal)
+	}
+
+	stored, err := m.db.CreateAPIKey(ctx, models.APIKey{
+		UserID:    req.UserID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		Scopes:    req.Scopes,
+		CreatedBy: req.CreatedBy,
+	}, hash)
+	if err != nil {
+		return models.IssuedAPIKey{}, err
+	}
+
+	m.log.Info("api key issued",
+		zap.Uint64("user", stored.UserID),
+		zap.Uint64("operator", stored.CreatedBy),
+		zap.Int64("key", stored.ID),
+		zap.Strings("scopes", stored.Scopes))
+
+	return models.IssuedAPIKey{APIKey: stored, Key: key}, nil
+}
+
+func (m *Mart) ListAPIKeys(ctx context.Context, user models.User) ([]models.APIKey, error) {
+	op := "gophermart.ListAPIKeys"
+
+	keys, err := m.db.ListAPIKeys(ctx, user.ID)
+	if err != nil {
+		return []models.APIKey{}, domain.Wrap(op, err)
+	}
+
+	return keys, nil
+}
+
+func (m *Mart) RevokeAPIKey(ctx context.Context, user models.User, id int64) error {
+	op := "gophermart.RevokeAPIKey"
+
+	if err := m.db.RevokeAPIKey(ctx, user.ID, id); err != nil {
+		return domain.Wrap(op, err)
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey владелец и права действующего ключа.
+func (m *Mart) AuthenticateAPIKey(ctx context.Context, key string) (models.User, []string, error) {
+	op := "gophermart.AuthenticateAPIKey"
+
+	stored, err := m.db.UseAPIKey(ctx, auth.HashAPIKey(key))
+	if err != nil {
+		return models.User{}, nil, domain.Wrap(op, err)
+	}
+
+	user, err := m.db.GetUserByID(ctx, int64(stored.UserID))
+	if err != nil {
+		return models.User{}, nil, domain.Wrap(op, err)
+	}
+
+	return user, stored.Scopes, nil
+}
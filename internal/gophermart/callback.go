@@ -0,0 +1,49 @@
+package gophermart
+
+import (
+	"context"
+	"errors"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// ApplyAccrual применяет ответ системы начислений к заказу в обработке.
+// Возвращает false, если расчёт ещё не завершён.
+func (m *Mart) ApplyAccrual(ctx context.Context, order models.Order, ext models.Order) (bool, error) {
+	switch ext.Status {
+	case models.OrderInvalid:
+		return true, m.UpdateOrderInvalid(ctx, order)
+	case models.OrderProcessed:
+		return true, m.FinalizeOrder(ctx, order, ext.Accrual)
+	}
+	return false, nil
+}
+
+// AccrualCallback применяет уведомление системы начислений. Возвращает false, если
+// статус не окончательный: такой заказ дождётся следующего уведомления или опроса.
+func (m *Mart) AccrualCallback(ctx context.Context, ext models.Order) (bool, error) {
+	op := "gophermart.AccrualCallback"
+
+	if ext.Status != models.OrderInvalid && ext.Status != models.OrderProcessed {
+		return false, nil
+	}
+
+	order, err := m.db.ClaimOrder(ctx, ext.Number)
+	if errors.Is(err, domain.ErrOrderFinalized) {
+		// повторная доставка уже применённого уведомления
+		return true, nil
+	}
+	if err != nil {
+		return false, domain.Wrap(op, err)
+	}
+
+	done, err := m.ApplyAccrual(ctx, order, ext)
+	if err != nil {
+		return false, domain.Wrap(op, err)
+	}
+
+	m.log.Info("accrual callback applied", zap.String("order", ext.Number), zap.String("status", ext.Status))
+	return done, nil
+}
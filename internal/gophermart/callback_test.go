@@ -0,0 +1,76 @@
+package gophermart
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/mocks"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccrualCallback_Processed(t *testing.T) {
+	repo := new(mocks.Repository)
+	mart := newTestMart(repo)
+
+	order := models.Order{UserID: 7, Number: "12345678903", Status: models.OrderProcessing}
+	repo.On("ClaimOrder", mock.Anything, order.Number).Return(order, nil).Once()
+	repo.On("FinalizeOrder", mock.Anything, order, money.FromInt(500)).Return(nil).Once()
+
+	done, err := mart.AccrualCallback(context.Background(), models.Order{Number: order.Number, Status: models.OrderProcessed, Accrual: money.FromInt(500)})
+	require.NoError(t, err)
+	require.True(t, done)
+	repo.AssertExpectations(t)
+}
+
+func TestAccrualCallback_Invalid(t *testing.T) {
+	repo := new(mocks.Repository)
+	mart := newTestMart(repo)
+
+	order := models.Order{UserID: 7, Number: "12345678903", Status: models.OrderProcessing}
+	repo.On("ClaimOrder", mock.Anything, order.Number).Return(order, nil).Once()
+	repo.On("UpdateOrderInvalid", mock.Anything, order.Number).Return(nil).Once()
+
+	done, err := mart.AccrualCallback(context.Background(), models.Order{Number: order.Number, Status: models.OrderInvalid})
+	require.NoError(t, err)
+	require.True(t, done)
+	repo.AssertExpectations(t)
+}
+
+func TestAccrualCallback_NotFinal(t *testing.T) {
+	repo := new(mocks.Repository)
+	mart := newTestMart(repo)
+
+	done, err := mart.AccrualCallback(context.Background(), models.Order{Number: "12345678903", Status: "REGISTERED"})
+	require.NoError(t, err)
+	require.False(t, done)
+	repo.AssertNotCalled(t, "ClaimOrder", mock.Anything, mock.Anything)
+}
+
+func TestAccrualCallback_Redelivered(t *testing.T) {
+	repo := new(mocks.Repository)
+	mart := newTestMart(repo)
+
+	repo.On("ClaimOrder", mock.Anything, "12345678903").
+		Return(models.Order{}, domain.MakeError(errors.New("already PROCESSED"), domain.ErrOrderFinalized)).Once()
+
+	done, err := mart.AccrualCallback(context.Background(), models.Order{Number: "12345678903", Status: models.OrderProcessed, Accrual: money.FromInt(500)})
+	require.NoError(t, err)
+	require.True(t, done)
+	repo.AssertNotCalled(t, "FinalizeOrder", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAccrualCallback_UnknownOrder(t *testing.T) {
+	repo := new(mocks.Repository)
+	mart := newTestMart(repo)
+
+	repo.On("ClaimOrder", mock.Anything, "12345678903").
+		Return(models.Order{}, domain.MakeError(errors.New("not found"), domain.ErrOrderNotFound)).Once()
+
+	_, err := mart.AccrualCallback(context.Background(), models.Order{Number: "12345678903", Status: models.OrderProcessed})
+	require.ErrorIs(t, err, domain.ErrOrderNotFound)
+}
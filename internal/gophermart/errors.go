@@ -13,14 +13,24 @@ func (m *Mart) WriteError(w http.ResponseWriter, err error) {
 		errors.Is(err, domain.ErrUserNotFound):
 		status = http.StatusBadRequest
 	case errors.Is(err, domain.ErrLoginAlreadyTaken),
-		errors.Is(err, domain.ErrOrderCreatedByOtherUser):
+		errors.Is(err, domain.ErrOrderCreatedByOtherUser),
+		errors.Is(err, domain.ErrOrderAlreadyExists),
+		errors.Is(err, domain.ErrIdempotencyInProgress),
+		errors.Is(err, domain.ErrEntryAlreadyReversed),
+		errors.Is(err, domain.ErrOrderFinalized):
 		status = http.StatusConflict
-	case errors.Is(err, domain.ErrInvalidCredentials):
+	case errors.Is(err, domain.ErrInvalidCredentials),
+		errors.Is(err, domain.ErrInvalidToken):
 		status = http.StatusUnauthorized
-	case errors.Is(err, domain.ErrUnprocessableOrder):
+	case errors.Is(err, domain.ErrUnprocessableOrder),
+		errors.Is(err, domain.ErrIdempotencyKeyReused):
 		status = http.StatusUnprocessableEntity
 	case errors.Is(err, domain.ErrOrderCreatedByUser):
 		status = http.StatusOK
+	case errors.Is(err, domain.ErrEntryNotFound),
+		errors.Is(err, domain.ErrOrderNotFound),
+		errors.Is(err, domain.ErrAPIKeyNotFound):
+		status = http.StatusNotFound
 	case errors.Is(err, domain.ErrNoContent):
 		status = http.StatusNoContent
 	case errors.Is(err, domain.ErrPaymentRequired):
@@ -29,7 +29,16 @@ func TestWriteError_StatusCodes(t *testing.T) {
 		{"user not found", domain.ErrUserNotFound, http.StatusBadRequest},
 		{"login already taken", domain.ErrLoginAlreadyTaken, http.StatusConflict},
 		{"order created by other", domain.ErrOrderCreatedByOtherUser, http.StatusConflict},
+		{"withdrawal already exists", domain.ErrOrderAlreadyExists, http.StatusConflict},
+		{"idempotency in progress", domain.ErrIdempotencyInProgress, http.StatusConflict},
+		{"idempotency key reused", domain.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity},
+		{"entry already reversed", domain.ErrEntryAlreadyReversed, http.StatusConflict},
+		{"entry not found", domain.ErrEntryNotFound, http.StatusNotFound},
+		{"order finalized", domain.ErrOrderFinalized, http.StatusConflict},
+		{"order not found", domain.ErrOrderNotFound, http.StatusNotFound},
+		{"api key not found", domain.ErrAPIKeyNotFound, http.StatusNotFound},
 		{"invalid credentials", domain.ErrInvalidCredentials, http.StatusUnauthorized},
+		{"invalid token", domain.ErrInvalidToken, http.StatusUnauthorized},
 		{"unprocessable order", domain.ErrUnprocessableOrder, http.StatusUnprocessableEntity},
 		{"order created by user", domain.ErrOrderCreatedByUser, http.StatusOK},
 		{"no content", domain.ErrNoContent, http.StatusNoContent},
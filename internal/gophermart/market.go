@@ -4,42 +4,88 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"net/url"
+	"sync"
 	"time"
+	"yandex-diplom/internal/accrual"
 	"yandex-diplom/internal/auth"
 	"yandex-diplom/internal/domain"
 	"yandex-diplom/internal/luhn"
 	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
 
 	"go.uber.org/zap"
 )
 
 type User interface {
-	Register(ctx context.Context, user models.User) (http.Cookie, error)
-	Login(ctx context.Context, user models.User) (http.Cookie, error)
+	Register(ctx context.Context, user models.User) (models.Tokens, error)
+	Login(ctx context.Context, user models.User) (models.Tokens, error)
+	RefreshTokens(ctx context.Context, refreshToken string) (models.Tokens, error)
+	Logout(ctx context.Context, sessionID string) error
+	CreateAPIKey(ctx context.Context, user models.User, req models.APIKeyRequest) (models.IssuedAPIKey, error)
+	ListAPIKeys(ctx context.Context, user models.User) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, user models.User, id int64) error
 	PutOrder(ctx context.Context, login string, order models.Order) error
 	GetOrders(ctx context.Context, login string) ([]models.Order, error)
+	GetOrdersPage(ctx context.Context, login string, filter models.OrderFilter) (models.OrderPage, error)
 	GetWithdrawals(ctx context.Context, userID uint64) ([]models.Withdrawal, error)
-	UpdateOrderProcessed(ctx context.Context, order models.Order, points float64) error
+	FinalizeOrder(ctx context.Context, order models.Order, points money.Amount) error
 	UpdateOrderInvalid(ctx context.Context, order models.Order) error
+	ApplyAccrual(ctx context.Context, order models.Order, ext models.Order) (bool, error)
+	ScheduleOrderRetry(ctx context.Context, order models.Order, next time.Time, reason string) error
+	DeadLetterOrder(ctx context.Context, order models.Order, reason string) error
+	ReleaseOrders(ctx context.Context, orders []models.Order) error
 	FetchNewOrders(ctx context.Context, limit int) ([]models.Order, error)
 	FetchProccesingOrders(ctx context.Context, limit int) ([]models.Order, error)
-	UpdateBalanceEntries(ctx context.Context, order models.Order) error
 	UpdateMissingBalanceEntries(ctx context.Context) error
 	GetBalance(ctx context.Context, user models.User) (models.Balance, error)
+	GetLedger(ctx context.Context, user models.User, filter models.LedgerFilter) (models.LedgerPage, error)
+	WriteStatement(ctx context.Context, user models.User, month time.Time, out StatementWriter) error
 	PutWithdrawl(ctx context.Context, user models.User, Withdrawal models.Withdrawal) error
 }
 
 type System interface {
 	WriteError(w http.ResponseWriter, err error)
 	GetUserByID(ctx context.Context, id int64) (models.User, error)
+	SessionRevoked(ctx context.Context, sessionID string) bool
+	AuthenticateAPIKey(ctx context.Context, key string) (models.User, []string, error)
 	GetOrderFromAccurual(ctx context.Context, number string) (models.Order, error)
+	CheckBalances(ctx context.Context, afterUserID uint64, limit int) ([]models.BalanceCheck, error)
+	RepairBalance(ctx context.Context, userID uint64) error
+	SaveReconcileReport(ctx context.Context, report models.ReconcileReport) error
+	SaveWorkerPoolStats(stats models.WorkerPoolStats)
 	GetLogger() *zap.Logger
 }
 
+type Idempotency interface {
+	ReserveIdempotencyKey(ctx context.Context, userID uint64, key string, requestHash string, ttl time.Duration) (models.IdempotentResponse, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, userID uint64, key string, resp models.IdempotentResponse) error
+	ReleaseIdempotencyKey(ctx context.Context, userID uint64, key string) error
+}
+
+type Callbacks interface {
+	AccrualCallback(ctx context.Context, ext models.Order) (bool, error)
+}
+
+type Admin interface {
+	GetUserInfo(ctx context.Context, login string) (models.UserInfo, error)
+	GetUserLedger(ctx context.Context, login string) ([]models.BalanceEntry, error)
+	ResetOrder(ctx context.Context, number string) error
+	InvalidateOrder(ctx context.Context, number string) error
+	AdjustBalance(ctx context.Context, login string, adj models.Adjustment) (models.BalanceEntry, error)
+	ReverseBalanceEntry(ctx context.Context, rev models.Reversal) (models.BalanceEntry, error)
+	IssueAPIKey(ctx context.Context, login string, req models.APIKeyRequest) (models.IssuedAPIKey, error)
+	GetReconcileReport(ctx context.Context) (models.ReconcileReport, error)
+	GetAccrualStats(ctx context.Context) models.AccrualStats
+	GetWorkerPoolStats(ctx context.Context) (models.WorkerPoolStats, bool)
+	GetDeadLetterOrders(ctx context.Context, limit int) ([]models.DeadLetterOrder, error)
+}
+
 type Service interface {
 	User
 	System
+	Idempotency
+	Callbacks
+	Admin
 }
 
 type Reposiroty interface {
@@ -51,29 +97,65 @@ type Reposiroty interface {
 	CheckUser(ctx context.Context, login string) (bool, error)
 	CheckOrder(ctx context.Context, user string, order models.Order) error
 	GetOrders(ctx context.Context, user string) ([]models.Order, error)
-	UpdateOrderProcessed(ctx context.Context, order string, points float64) error
+	GetOrdersPage(ctx context.Context, user string, filter models.OrderFilter) (models.OrderPage, error)
+	FinalizeOrder(ctx context.Context, order models.Order, points money.Amount) error
 	UpdateOrderInvalid(ctx context.Context, order string) error
 	FetchNewOrders(ctx context.Context, limit int) ([]models.Order, error)
 	FetchProccesingOrders(ctx context.Context, limit int) ([]models.Order, error)
-	UpdateBalanceEntries(ctx context.Context, order models.Order) error
+	ClaimOrder(ctx context.Context, orderNumber string) (models.Order, error)
 	UpdateMissingBalanceEntries(ctx context.Context) error
 	UpdateBalance(ctx context.Context, userID uint64) error
 	GetBalance(ctx context.Context, userID uint64) (models.Balance, error)
 	UpdateWithdrawlEntries(ctx context.Context, userID uint64, withdraw models.Withdrawal) error
 	GetWithdrawls(ctx context.Context, userID uint64) ([]models.Withdrawal, error)
+	ReserveIdempotencyKey(ctx context.Context, userID uint64, key string, requestHash string, ttl time.Duration) (models.IdempotentResponse, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, userID uint64, key string, resp models.IdempotentResponse) error
+	ReleaseIdempotencyKey(ctx context.Context, userID uint64, key string) error
+	AdjustBalance(ctx context.Context, adj models.Adjustment) (models.BalanceEntry, error)
+	ReverseBalanceEntry(ctx context.Context, rev models.Reversal) (models.BalanceEntry, error)
+	GetBalanceEntries(ctx context.Context, userID uint64) ([]models.BalanceEntry, error)
+	GetLedgerPage(ctx context.Context, userID uint64, filter models.LedgerFilter) (models.LedgerPage, error)
+	CheckBalances(ctx context.Context, afterUserID uint64, limit int) ([]models.BalanceCheck, error)
+	StreamBalanceEntries(ctx context.Context, userID uint64, from, to time.Time, opening func(money.Amount) error, fn func(models.BalanceEntry) error) error
+	SetUserRole(ctx context.Context, login string, role string) error
+	ResetOrder(ctx context.Context, orderNumber string) error
+	InvalidateOrder(ctx context.Context, orderNumber string) error
+	SaveReconcileReport(ctx context.Context, report models.ReconcileReport) error
+	GetReconcileReport(ctx context.Context) (models.ReconcileReport, error)
+	ScheduleOrderRetry(ctx context.Context, orderNumber string, next time.Time, reason string) error
+	DeadLetterOrder(ctx context.Context, orderNumber string, reason string) error
+	ReleaseOrders(ctx context.Context, orderNumbers []string) error
+	GetDeadLetterOrders(ctx context.Context, limit int) ([]models.DeadLetterOrder, error)
+	CreateSession(ctx context.Context, session models.Session, refreshHash string) error
+	RotateRefreshToken(ctx context.Context, oldHash string, newHash string, expiresAt time.Time) (models.Session, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	ListRevokedSessions(ctx context.Context, since time.Time) ([]string, error)
+	CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (models.APIKey, error)
+	UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID uint64) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID uint64, id int64) error
 }
 
 type Mart struct {
 	db          Reposiroty
 	log         *zap.Logger
 	Environment string
-	accurual    *url.URL
-	client      *http.Client
+	accrual     accrual.Client
+	revocations *auth.Revocations
+
+	// pool снимок пула воркеров этого процесса, другие инстансы его не видят
+	poolMu sync.RWMutex
+	pool   *models.WorkerPoolStats
 }
 
-func New(db Reposiroty, logger *zap.Logger, env string, accural *url.URL) Service {
-	return &Mart{db: db, log: logger, Environment: env, accurual: accural,
-		client: &http.Client{Timeout: 10 * time.Second}}
+func New(db Reposiroty, logger *zap.Logger, env string, client accrual.Client) Service {
+	return &Mart{
+		db:          db,
+		log:         logger,
+		Environment: env,
+		accrual:     client,
+		revocations: auth.NewRevocations(db, revocationRefresh),
+	}
 }
 
 func (m *Mart) GetUserByID(ctx context.Context, id int64) (models.User, error) {
@@ -87,83 +169,63 @@ func (m *Mart) GetUserByID(ctx context.Context, id int64) (models.User, error) {
 	return user, nil
 }
 
-func (m *Mart) Register(ctx context.Context, user models.User) (http.Cookie, error) {
+func (m *Mart) Register(ctx context.Context, user models.User) (models.Tokens, error) {
 	op := "gophermart.Register"
 
 	if err := ValidateUser(user); err != nil {
-		return http.Cookie{}, domain.Wrap(op, err)
+		return models.Tokens{}, domain.Wrap(op, err)
 	}
 
 	exist, err := m.db.CheckUser(ctx, user.Login)
 	if err != nil {
-		return http.Cookie{}, domain.Wrap(op, err)
+		return models.Tokens{}, domain.Wrap(op, err)
 	}
 
 	if exist {
-		return http.Cookie{}, domain.Wrap(op, domain.MakeError(fmt.Errorf("user already exist"), domain.ErrLoginAlreadyTaken))
+		return models.Tokens{}, domain.Wrap(op, domain.MakeError(fmt.Errorf("user already exist"), domain.ErrLoginAlreadyTaken))
 	}
 
 	err = m.db.RegisterUser(ctx, user)
 	if err != nil {
-		return http.Cookie{}, domain.Wrap(op, err)
+		return models.Tokens{}, domain.Wrap(op, err)
 	}
 
 	dbUser, err := m.db.GetUserByLogin(ctx, user.Login)
 	if err != nil {
-		return http.Cookie{}, domain.Wrap(op, err)
+		return models.Tokens{}, domain.Wrap(op, err)
 	}
 
-	token, err := auth.CreateJWTToken(dbUser.ID)
+	tokens, err := m.startSession(ctx, dbUser)
 	if err != nil {
-		return http.Cookie{}, domain.Wrap(op, err)
-	}
-
-	cookie := http.Cookie{
-		Name:     "Authorization",
-		Value:    token,
-		Path:     "/",
-		Expires:  time.Now().Add(auth.TTL),
-		HttpOnly: true,
-		Secure:   false,
-		SameSite: http.SameSiteLaxMode,
+		return models.Tokens{}, domain.Wrap(op, err)
 	}
 
-	return cookie, nil
+	return tokens, nil
 }
 
-func (m *Mart) Login(ctx context.Context, user models.User) (http.Cookie, error) {
+func (m *Mart) Login(ctx context.Context, user models.User) (models.Tokens, error) {
 	op := "gophermart.Login"
 
 	if err := ValidateUser(user); err != nil {
-		return http.Cookie{}, domain.Wrap(op, err)
+		return models.Tokens{}, domain.Wrap(op, err)
 	}
 
 	err := m.db.ValidateUser(ctx, user)
 	if err != nil {
-		return http.Cookie{}, domain.Wrap(op, err)
+		return models.Tokens{}, domain.Wrap(op, err)
 	}
 
 	dbUser, err := m.db.GetUserByLogin(ctx, user.Login)
 	if err != nil {
-		return http.Cookie{}, domain.Wrap(op, err)
+		return models.Tokens{}, domain.Wrap(op, err)
 	}
 
-	token, err := auth.CreateJWTToken(dbUser.ID)
+	tokens, err := m.startSession(ctx, dbUser)
 	if err != nil {
-		return http.Cookie{}, domain.Wrap(op, err)
-	}
-
-	cookie := http.Cookie{
-		Name:     "Authorization",
-		Value:    token,
-		Path:     "/",
-		Expires:  time.Now().Add(auth.TTL),
-		HttpOnly: true,
-		Secure:   false,
-		SameSite: http.SameSiteLaxMode,
+		return models.Tokens{}, domain.Wrap(op, err)
 	}
 
-	return cookie, nil
+	return tokens, nil
 }
 
 func (m *Mart) PutOrder(ctx context.Context, login string, order models.Order) error {
@@ -198,6 +260,22 @@ func (m *Mart) GetOrders(ctx context.Context, login string) ([]models.Order, err
 	return orders, nil
 }
 
+func (m *Mart) GetOrdersPage(ctx context.Context, login string, filter models.OrderFilter) (models.OrderPage, error) {
+	op := "gophermart.GetOrdersPage"
+
+	filter, err := ValidateOrderFilter(filter)
+	if err != nil {
+		return models.OrderPage{}, domain.Wrap(op, err)
+	}
+
+	page, err := m.db.GetOrdersPage(ctx, login, filter)
+	if err != nil {
+		return models.OrderPage{}, domain.Wrap(op, err)
+	}
+
+	return page, nil
+}
+
 func (m *Mart) GetBalance(ctx context.Context, user models.User) (models.Balance, error) {
 	op := "gophermart.GetBalance"
 
@@ -209,24 +287,31 @@ func (m *Mart) GetBalance(ctx context.Context, user models.User) (models.Balance
 	return balance, nil
 }
 
-func (m *Mart) PutWithdrawl(ctx context.Context, user models.User, Withdrawal models.Withdrawal) error {
-	op := "gophermart.PutWithdrawl"
+func (m *Mart) GetLedger(ctx context.Context, user models.User, filter models.LedgerFilter) (models.LedgerPage, error) {
+	op := "gophermart.GetLedger"
 
-	valid := luhn.Valid(Withdrawal.Order)
-	if !valid {
-		return domain.Wrap(op, domain.MakeError(fmt.Errorf("invalid order number"), domain.ErrUnprocessableOrder))
+	filter, err := ValidateLedgerFilter(filter)
+	if err != nil {
+		return models.LedgerPage{}, domain.Wrap(op, err)
 	}
 
-	balance, err := m.db.GetBalance(ctx, user.ID)
+	page, err := m.db.GetLedgerPage(ctx, user.ID, filter)
 	if err != nil {
-		return domain.Wrap(op, err)
+		return models.LedgerPage{}, domain.Wrap(op, err)
 	}
 
-	if balance.Current < Withdrawal.Sum {
-		return domain.Wrap(op, domain.MakeError(fmt.Errorf("the current balance is lower than the amount indicated"), domain.ErrPaymentRequired))
+	return page, nil
+}
+
+func (m *Mart) PutWithdrawl(ctx context.Context, user models.User, Withdrawal models.Withdrawal) error {
+	op := "gophermart.PutWithdrawl"
+
+	valid := luhn.Valid(Withdrawal.Order)
+	if !valid {
+		return domain.Wrap(op, domain.MakeError(fmt.Errorf("invalid order number"), domain.ErrUnprocessableOrder))
 	}
 
-	err = m.db.UpdateWithdrawlEntries(ctx, user.ID, Withdrawal)
+	err := m.db.UpdateWithdrawlEntries(ctx, user.ID, Withdrawal)
 	if err != nil {
 		return domain.Wrap(op, err)
 	}
@@ -257,8 +342,33 @@ func (m *Mart) UpdateOrderInvalid(ctx context.Context, order models.Order) error
 	return m.db.UpdateOrderInvalid(ctx, order.Number)
 }
 
-func (m *Mart) UpdateOrderProcessed(ctx context.Context, order models.Order, points float64) error {
-	return m.db.UpdateOrderProcessed(ctx, order.Number, points)
+func (m *Mart) FinalizeOrder(ctx context.Context, order models.Order, points money.Amount) error {
+	return m.db.FinalizeOrder(ctx, order, points)
+}
+
+func (m *Mart) ScheduleOrderRetry(ctx context.Context, order models.Order, next time.Time, reason string) error {
+	return m.db.ScheduleOrderRetry(ctx, order.Number, next, reason)
+}
+
+func (m *Mart) DeadLetterOrder(ctx context.Context, order models.Order, reason string) error {
+	if err := m.db.DeadLetterOrder(ctx, order.Number, reason); err != nil {
+		return err
+	}
+
+	m.log.Warn("order moved to dead letter", zap.String("order", order.Number), zap.Int("attempts", order.Attempts+1), zap.String("reason", reason))
+	return nil
+}
+
+func (m *Mart) ReleaseOrders(ctx context.Context, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	numbers := make([]string, 0, len(orders))
+	for _, o := range orders {
+		numbers = append(numbers, o.Number)
+	}
+	return m.db.ReleaseOrders(ctx, numbers)
 }
 
 func (m *Mart) FetchNewOrders(ctx context.Context, limit int) ([]models.Order, error) {
@@ -269,10 +379,37 @@ func (m *Mart) FetchProccesingOrders(ctx context.Context, limit int) ([]models.O
 	return m.db.FetchProccesingOrders(ctx, limit)
 }
 
-func (m *Mart) UpdateBalanceEntries(ctx context.Context, order models.Order) error {
-	return m.db.UpdateBalanceEntries(ctx, order)
-}
-
 func (m *Mart) UpdateMissingBalanceEntries(ctx context.Context) error {
 	return m.db.UpdateMissingBalanceEntries(ctx)
 }
+
+func (m *Mart) ReserveIdempotencyKey(ctx context.Context, userID uint64, key string, requestHash string, ttl time.Duration) (models.IdempotentResponse, bool, error) {
+	op := "gophermart.ReserveIdempotencyKey"
+
+	existing, reserved, err := m.db.ReserveIdempotencyKey(ctx, userID, key, requestHash, ttl)
+	if err != nil {
+		return models.IdempotentResponse{}, false, domain.Wrap(op, err)
+	}
+
+	return existing, reserved, nil
+}
+
+func (m *Mart) CompleteIdempotencyKey(ctx context.Context, userID uint64, key string, resp models.IdempotentResponse) error {
+	op := "gophermart.CompleteIdempotencyKey"
+
+	if err := m.db.CompleteIdempotencyKey(ctx, userID, key, resp); err != nil {
+		return domain.Wrap(op, err)
+	}
+
+	return nil
+}
+
+func (m *Mart) ReleaseIdempotencyKey(ctx context.Context, userID uint64, key string) error {
+	op := "gophermart.ReleaseIdempotencyKey"
+
+	if err := m.db.ReleaseIdempotencyKey(ctx, userID, key); err != nil {
+		return domain.Wrap(op, err)
+	}
+
+	return nil
+}
@@ -5,22 +5,27 @@ import (
 	"errors"
 	"net/url"
 	"testing"
+	"yandex-diplom/internal/accrual"
 	"yandex-diplom/internal/auth"
 	"yandex-diplom/internal/domain"
 	"yandex-diplom/internal/mocks"
 	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
-func TestRegister_Success(t *testing.T) {
-	repo := new(mocks.Repository)
-	logger, _ := zap.NewDevelopment()
+// newTestMart сервис поверх мока хранилища, система начислений не вызывается.
+func newTestMart(repo *mocks.Repository) Service {
 	accURL, _ := url.Parse("http://localhost:8080")
+	return New(repo, zap.NewNop(), "test", accrual.New(accURL, accrual.Config{}))
+}
 
-	mart := New(repo, logger, "test", accURL)
+func TestRegister_Success(t *testing.T) {
+	repo := new(mocks.Repository)
+	mart := newTestMart(repo)
 
 	user := models.User{Login: "testuser", Password: "password123"}
 
@@ -28,23 +33,25 @@ func TestRegister_Success(t *testing.T) {
 	repo.On("RegisterUser", mock.Anything, user).Return(nil)
 	repo.On("GetUserByLogin", mock.Anything, user.Login).
 		Return(models.User{ID: 1, Login: "testuser"}, nil)
+	repo.On("CreateSession", mock.Anything, mock.MatchedBy(func(s models.Session) bool {
+		return s.ID != "" && s.UserID == 1
+	}), mock.AnythingOfType("string")).Return(nil)
 
-	cookie, err := mart.Register(context.Background(), user)
+	tokens, err := mart.Register(context.Background(), user)
 	require.NoError(t, err)
-	require.Equal(t, "Authorization", cookie.Name)
-	require.NotEmpty(t, cookie.Value)
+	require.NotEmpty(t, tokens.Access)
+	require.NotEmpty(t, tokens.Refresh)
 
-	claims, err := auth.ParseJWT(cookie.Value)
+	claims, err := auth.ParseJWT(tokens.Access)
 	require.NoError(t, err)
 	require.Equal(t, float64(1), claims["userID"])
+	require.NotEmpty(t, claims["sid"])
+	repo.AssertExpectations(t)
 }
 
 func TestRegister_UserAlreadyExists(t *testing.T) {
 	repo := new(mocks.Repository)
-	logger, _ := zap.NewDevelopment()
-	accURL, _ := url.Parse("http://localhost:8080")
-
-	mart := New(repo, logger, "test", accURL)
+	mart := newTestMart(repo)
 
 	user := models.User{Login: "testuser", Password: "password123"}
 
@@ -57,16 +64,13 @@ func TestRegister_UserAlreadyExists(t *testing.T) {
 
 func TestPutWithdrawl_NotEnoughBalance(t *testing.T) {
 	repo := new(mocks.Repository)
-	logger, _ := zap.NewDevelopment()
-	accURL, _ := url.Parse("http://localhost:8080")
-
-	mart := New(repo, logger, "test", accURL)
+	mart := newTestMart(repo)
 
 	user := models.User{ID: 1, Login: "testuser"}
-	withdraw := models.Withdrawal{Order: "79927398713", Sum: 100}
+	withdraw := models.Withdrawal{Order: "79927398713", Sum: money.FromInt(100)}
 
-	repo.On("GetBalance", mock.Anything, user.ID).
-		Return(models.Balance{Current: 50}, nil)
+	repo.On("UpdateWithdrawlEntries", mock.Anything, user.ID, withdraw).
+		Return(domain.MakeError(errors.New("balance is lower"), domain.ErrPaymentRequired))
 
 	err := mart.PutWithdrawl(context.Background(), user, withdraw)
 	require.Error(t, err)
@@ -75,10 +79,7 @@ func TestPutWithdrawl_NotEnoughBalance(t *testing.T) {
 
 func TestGetWithdrawals_NoContent(t *testing.T) {
 	repo := new(mocks.Repository)
-	logger, _ := zap.NewDevelopment()
-	accURL, _ := url.Parse("http://localhost:8080")
-
-	mart := New(repo, logger, "test", accURL)
+	mart := newTestMart(repo)
 
 	repo.On("GetWithdrawls", mock.Anything, uint64(1)).
 		Return([]models.Withdrawal{}, nil)
@@ -87,3 +88,34 @@ func TestGetWithdrawals_NoContent(t *testing.T) {
 	require.Error(t, err)
 	require.True(t, errors.Is(err, domain.ErrNoContent))
 }
+
+func TestAdjustBalance_Validation(t *testing.T) {
+	repo := new(mocks.Repository)
+	mart := newTestMart(repo)
+
+	_, err := mart.AdjustBalance(context.Background(), "testuser", models.Adjustment{Amount: 0, Reason: "bonus"})
+	require.ErrorIs(t, err, domain.ErrInvalidPayload)
+
+	_, err = mart.AdjustBalance(context.Background(), "testuser", models.Adjustment{Amount: money.FromInt(5), Reason: "  "})
+	require.ErrorIs(t, err, domain.ErrInvalidPayload)
+
+	repo.AssertExpectations(t)
+}
+
+func TestAdjustBalance_Success(t *testing.T) {
+	repo := new(mocks.Repository)
+	mart := newTestMart(repo)
+
+	repo.On("GetUserByLogin", mock.Anything, "testuser").
+		Return(models.User{ID: 7, Login: "testuser"}, nil)
+	repo.On("AdjustBalance", mock.Anything, models.Adjustment{
+		UserID: 7, Amount: money.FromInt(-5), Reason: "refund", OperatorID: 1,
+	}).Return(models.BalanceEntry{ID: 10, UserID: 7, Type: models.EntryAdjustment, Amount: money.FromInt(-5)}, nil)
+
+	entry, err := mart.AdjustBalance(context.Background(), "testuser", models.Adjustment{
+		Amount: money.FromInt(-5), Reason: " refund ", OperatorID: 1,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(10), entry.ID)
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,69 @@
+package gophermart
+
+import (
+	"context"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+)
+
+func (m *Mart) CheckBalances(ctx context.Context, afterUserID uint64, limit int) ([]models.BalanceCheck, error) {
+	op := "gophermart.CheckBalances"
+
+	checks, err := m.db.CheckBalances(ctx, afterUserID, limit)
+	if err != nil {
+		return []models.BalanceCheck{}, domain.Wrap(op, err)
+	}
+
+	return checks, nil
+}
+
+// RepairBalance пересчитывает кэш баланса пользователя по журналу.
+func (m *Mart) RepairBalance(ctx context.Context, userID uint64) error {
+	op := "gophermart.RepairBalance"
+
+	if err := m.db.UpdateBalance(ctx, userID); err != nil {
+		return domain.Wrap(op, err)
+	}
+
+	return nil
+}
+
+// SaveReconcileReport сохраняет итог прогона в базе, чтобы отчёт видели все инстансы.
+func (m *Mart) SaveReconcileReport(ctx context.Context, report models.ReconcileReport) error {
+	op := "gophermart.SaveReconcileReport"
+
+	if err := m.db.SaveReconcileReport(ctx, report); err != nil {
+		return domain.Wrap(op, err)
+	}
+
+	return nil
+}
+
+// GetReconcileReport возвращает итог последнего прогона сверки, ErrNoContent если сверка ещё не запускалась.
+func (m *Mart) GetReconcileReport(ctx context.Context) (models.ReconcileReport, error) {
+	op := "gophermart.GetReconcileReport"
+
+	report, err := m.db.GetReconcileReport(ctx)
+	if err != nil {
+		return models.ReconcileReport{}, domain.Wrap(op, err)
+	}
+
+	return report, nil
+}
+
+func (m *Mart) SaveWorkerPoolStats(stats models.WorkerPoolStats) {
+	m.poolMu.Lock()
+	defer m.poolMu.Unlock()
+	m.pool = &stats
+}
+
+// GetWorkerPoolStats возвращает последний снимок пула воркеров этого процесса,
+// false если воркеры в нём не запущены. Пулы других инстансов сюда не попадают.
+func (m *Mart) GetWorkerPoolStats(ctx context.Context) (models.WorkerPoolStats, bool) {
+	m.poolMu.RLock()
+	defer m.poolMu.RUnlock()
+	if m.pool == nil {
+		return models.WorkerPoolStats{}, false
+	}
+	return *m.pool, true
+}
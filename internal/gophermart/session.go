@@ -0,0 +1,106 @@
+package gophermart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"yandex-diplom/internal/auth"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+)
+
+// revocationRefresh как часто перечитывается список отозванных сессий.
+// Столько же отзыв с другого экземпляра может оставаться незамеченным.
+const revocationRefresh = 5 * time.Second
+
+// startSession заводит сессию и выдаёт её первую пару токенов.
+func (m *Mart) startSession(ctx context.Context, user models.User) (models.Tokens, error) {
+	sid, err := auth.NewSessionID()
+	if err != nil {
+		return models.Tokens{}, domain.MakeError(err, domain.ErrInternal)
+	}
+
+	refresh, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return models.Tokens{}, domain.MakeError(err, domain.ErrInternal)
+	}
+
+	session := models.Session{
+		ID:        sid,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(auth.RefreshTTL),
+	}
+	if err := m.db.CreateSession(ctx, session, hash); err != nil {
+		return models.Tokens{}, err
+	}
+
+	return m.issueTokens(user, session, refresh)
+}
+
+func (m *Mart) issueTokens(user models.User, session models.Session, refresh string) (models.Tokens, error) {
+	access, err := auth.CreateJWTToken(user.ID, user.Role, session.ID)
+	if err != nil {
+		return models.Tokens{}, err
+	}
+
+	return models.Tokens{
+		Access:         access,
+		AccessExpires:  time.Now().Add(auth.AccessTTL),
+		Refresh:        refresh,
+		RefreshExpires: session.ExpiresAt,
+	}, nil
+}
+
+// RefreshTokens меняет refresh-токен на новую пару. Старый токен больше не
+// принимается; его повторное предъявление отзывает всю сессию.
+func (m *Mart) RefreshTokens(ctx context.Context, refreshToken string) (models.Tokens, error) {
+	op := "gophermart.RefreshTokens"
+
+	if refreshToken == "" {
+		return models.Tokens{}, domain.Wrap(op, domain.MakeError(fmt.Errorf("empty refresh token"), domain.ErrInvalidToken))
+	}
+
+	refresh, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return models.Tokens{}, domain.Wrap(op, domain.MakeError(err, domain.ErrInternal))
+	}
+
+	session, err := m.db.RotateRefreshToken(ctx, auth.HashRefreshToken(refreshToken), hash, time.Now().Add(auth.RefreshTTL))
+	if err != nil {
+		// при повторном использовании хранилище уже отозвало сессию и вернуло её
+		if session.ID != "" && errors.Is(err, domain.ErrInvalidToken) {
+			m.revocations.Revoke(session.ID)
+		}
+		return models.Tokens{}, domain.Wrap(op, err)
+	}
+
+	user, err := m.db.GetUserByID(ctx, int64(session.UserID))
+	if err != nil {
+		return models.Tokens{}, domain.Wrap(op, err)
+	}
+
+	tokens, err := m.issueTokens(user, session, refresh)
+	if err != nil {
+		return models.Tokens{}, domain.Wrap(op, err)
+	}
+
+	return tokens, nil
+}
+
+// Logout отзывает сессию: её refresh-токен перестаёт обновляться, а выданные
+// access-токены отклоняются middleware.
+func (m *Mart) Logout(ctx context.Context, sessionID string) error {
+	op := "gophermart.Logout"
+
+	if err := m.db.RevokeSession(ctx, sessionID); err != nil {
+		return domain.Wrap(op, err)
+	}
+	m.revocations.Revoke(sessionID)
+
+	return nil
+}
+
+func (m *Mart) SessionRevoked(ctx context.Context, sessionID string) bool {
+	return m.revocations.Revoked(ctx, sessionID)
+}
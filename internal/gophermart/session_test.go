@@ -0,0 +1,70 @@
+package gophermart
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"yandex-diplom/internal/auth"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/mocks"
+	"yandex-diplom/internal/models"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshTokens_Rotates(t *testing.T) {
+	repo := new(mocks.Repository)
+	mart := newTestMart(repo)
+
+	repo.On("RotateRefreshToken", mock.Anything, auth.HashRefreshToken("old"), mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).
+		Return(models.Session{ID: "sid-1", UserID: 7, ExpiresAt: time.Now().Add(auth.RefreshTTL)}, nil).Once()
+	repo.On("GetUserByID", mock.Anything, int64(7)).
+		Return(models.User{ID: 7, Login: "user", Role: models.RoleUser}, nil).Once()
+
+	tokens, err := mart.RefreshTokens(context.Background(), "old")
+	require.NoError(t, err)
+	require.NotEqual(t, "old", tokens.Refresh)
+
+	claims, err := auth.ParseJWT(tokens.Access)
+	require.NoError(t, err)
+	require.Equal(t, "sid-1", claims["sid"])
+	repo.AssertExpectations(t)
+}
+
+func TestRefreshTokens_ReuseRevokesSession(t *testing.T) {
+	repo := new(mocks.Repository)
+	mart := newTestMart(repo)
+
+	repo.On("RotateRefreshToken", mock.Anything, auth.HashRefreshToken("stolen"), mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).
+		Return(models.Session{ID: "sid-1", UserID: 7}, domain.MakeError(errors.New("reused"), domain.ErrInvalidToken)).Once()
+	repo.On("ListRevokedSessions", mock.Anything, mock.AnythingOfType("time.Time")).
+		Return([]string{}, nil)
+
+	_, err := mart.RefreshTokens(context.Background(), "stolen")
+	require.ErrorIs(t, err, domain.ErrInvalidToken)
+	require.True(t, mart.SessionRevoked(context.Background(), "sid-1"))
+}
+
+func TestRefreshTokens_Empty(t *testing.T) {
+	repo := new(mocks.Repository)
+	mart := newTestMart(repo)
+
+	_, err := mart.RefreshTokens(context.Background(), "")
+	require.ErrorIs(t, err, domain.ErrInvalidToken)
+	repo.AssertExpectations(t)
+}
+
+func TestLogout(t *testing.T) {
+	repo := new(mocks.Repository)
+	mart := newTestMart(repo)
+
+	repo.On("RevokeSession", mock.Anything, "sid-1").Return(nil).Once()
+	repo.On("ListRevokedSessions", mock.Anything, mock.AnythingOfType("time.Time")).
+		Return([]string{}, nil)
+
+	require.NoError(t, mart.Logout(context.Background(), "sid-1"))
+	require.True(t, mart.SessionRevoked(context.Background(), "sid-1"))
+	require.False(t, mart.SessionRevoked(context.Background(), "sid-2"))
+}
@@ -0,0 +1,50 @@
+package gophermart
+
+import (
+	"context"
+	"time"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+)
+
+// StatementWriter принимает выписку построчно: шапку, движения и итог.
+type StatementWriter interface {
+	WriteOpening(s models.Statement) error
+	WriteEntry(e models.LedgerEntry) error
+	WriteClosing(s models.Statement) error
+}
+
+// WriteStatement формирует выписку за календарный месяц (UTC) и пишет её в out по мере чтения из базы.
+func (m *Mart) WriteStatement(ctx context.Context, user models.User, month time.Time, out StatementWriter) error {
+	op := "gophermart.WriteStatement"
+
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	st := models.Statement{
+		Month: from.Format("2006-01"),
+		From:  from,
+		To:    from.AddDate(0, 1, 0),
+	}
+
+	var running money.Amount
+	err := m.db.StreamBalanceEntries(ctx, user.ID, st.From, st.To,
+		func(opening money.Amount) error {
+			st.Opening = opening
+			running = opening
+			return out.WriteOpening(st)
+		},
+		func(e models.BalanceEntry) error {
+			running += e.Amount
+			return out.WriteEntry(models.LedgerEntry{BalanceEntry: e, RunningBalance: running})
+		})
+	if err != nil {
+		return domain.Wrap(op, err)
+	}
+
+	st.Closing = running
+	if err := out.WriteClosing(st); err != nil {
+		return domain.Wrap(op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package gophermart
+
+import (
+	"context"
+	"testing"
+	"time"
+	"yandex-diplom/internal/mocks"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type recordStatement struct {
+	opening models.Statement
+	entries []models.LedgerEntry
+	closing models.Statement
+}
+
+func (r *recordStatement) WriteOpening(s models.Statement) error { r.opening = s; return nil }
+func (r *recordStatement) WriteEntry(e models.LedgerEntry) error {
+	r.entries = append(r.entries, e)
+	return nil
+}
+func (r *recordStatement) WriteClosing(s models.Statement) error { r.closing = s; return nil }
+
+func TestWriteStatement(t *testing.T) {
+	repo := new(mocks.Repository)
+	mart := newTestMart(repo)
+
+	from := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	repo.On("StreamBalanceEntries", mock.Anything, uint64(7), from, to).Return(nil, money.FromInt(30), []models.BalanceEntry{
+		{ID: 1, Type: models.EntryWithdrawal, Amount: money.FromInt(-10)},
+		{ID: 2, Type: models.EntryAccrual, Amount: money.FromCents(250)},
+	})
+
+	out := &recordStatement{}
+	err := mart.WriteStatement(context.Background(), models.User{ID: 7}, time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC), out)
+	require.NoError(t, err)
+
+	require.Equal(t, "2024-02", out.opening.Month)
+	require.Equal(t, money.FromInt(30), out.opening.Opening)
+	require.Len(t, out.entries, 2)
+	require.Equal(t, money.FromInt(20), out.entries[0].RunningBalance)
+	require.Equal(t, money.FromCents(2250), out.entries[1].RunningBalance)
+	require.Equal(t, money.FromCents(2250), out.closing.Closing)
+	repo.AssertExpectations(t)
+}
@@ -2,6 +2,8 @@ package gophermart
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"yandex-diplom/internal/domain"
 	"yandex-diplom/internal/models"
 )
@@ -20,3 +22,83 @@ func ValidateUser(user models.User) error {
 	}
 	return nil
 }
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 1000
+)
+
+var orderStatuses = map[string]struct{}{
+	models.OrderNew:        {},
+	models.OrderProcessing: {},
+	models.OrderInvalid:    {},
+	models.OrderProcessed:  {},
+}
+
+func ValidateOrderFilter(filter models.OrderFilter) (models.OrderFilter, error) {
+	if filter.Limit == 0 {
+		filter.Limit = DefaultPageLimit
+	}
+	if filter.Limit < 0 || filter.Limit > MaxPageLimit {
+		return models.OrderFilter{}, domain.MakeError(fmt.Errorf("limit must be between 1 and %d", MaxPageLimit), domain.ErrInvalidPayload)
+	}
+
+	for _, status := range filter.Statuses {
+		if _, ok := orderStatuses[status]; !ok {
+			return models.OrderFilter{}, domain.MakeError(fmt.Errorf("unknown order status %q", status), domain.ErrInvalidPayload)
+		}
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return models.OrderFilter{}, domain.MakeError(fmt.Errorf("from must be before to"), domain.ErrInvalidPayload)
+	}
+
+	return filter, nil
+}
+
+func ValidateLedgerFilter(filter models.LedgerFilter) (models.LedgerFilter, error) {
+	if filter.Limit == 0 {
+		filter.Limit = DefaultPageLimit
+	}
+	if filter.Limit < 0 || filter.Limit > MaxPageLimit {
+		return models.LedgerFilter{}, domain.MakeError(fmt.Errorf("limit must be between 1 and %d", MaxPageLimit), domain.ErrInvalidPayload)
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return models.LedgerFilter{}, domain.MakeError(fmt.Errorf("from must be before to"), domain.ErrInvalidPayload)
+	}
+
+	return filter, nil
+}
+
+const maxAPIKeyName = 128
+
+var apiKeyScopes = map[string]struct{}{
+	models.ScopeOrdersWrite: {},
+	models.ScopeOrdersRead:  {},
+	models.ScopeBalanceRead: {},
+}
+
+// ValidateAPIKeyRequest требует имя и хотя бы одно известное право; права
+// приводятся к отсортированному списку без повторов.
+func ValidateAPIKeyRequest(req models.APIKeyRequest) (models.APIKeyRequest, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxAPIKeyName {
+		return models.APIKeyRequest{}, domain.MakeError(fmt.Errorf("name must be 1 to %d characters", maxAPIKeyName), domain.ErrInvalidPayload)
+	}
+
+	if len(req.Scopes) == 0 {
+		return models.APIKeyRequest{}, domain.MakeError(fmt.Errorf("at least one scope is required"), domain.ErrInvalidPayload)
+	}
+	for _, scope := range req.Scopes {
+		if _, ok := apiKeyScopes[scope]; !ok {
+			return models.APIKeyRequest{}, domain.MakeError(fmt.Errorf("unknown scope %q", scope), domain.ErrInvalidPayload)
+		}
+	}
+
+	scopes := slices.Clone(req.Scopes)
+	slices.Sort(scopes)
+	req.Scopes = slices.Compact(scopes)
+
+	return req, nil
+}
@@ -2,6 +2,7 @@ package gophermart
 
 import (
 	"testing"
+	"time"
 	"yandex-diplom/internal/domain"
 	"yandex-diplom/internal/models"
 
@@ -50,3 +51,63 @@ func TestValidateUser(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateOrderFilter(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	got, err := ValidateOrderFilter(models.OrderFilter{})
+	require.NoError(t, err)
+	require.Equal(t, DefaultPageLimit, got.Limit)
+
+	_, err = ValidateOrderFilter(models.OrderFilter{Limit: MaxPageLimit + 1})
+	require.ErrorIs(t, err, domain.ErrInvalidPayload)
+
+	_, err = ValidateOrderFilter(models.OrderFilter{Statuses: []string{"NEW", "DONE"}})
+	require.ErrorIs(t, err, domain.ErrInvalidPayload)
+
+	_, err = ValidateOrderFilter(models.OrderFilter{From: &to, To: &from})
+	require.ErrorIs(t, err, domain.ErrInvalidPayload)
+
+	got, err = ValidateOrderFilter(models.OrderFilter{Statuses: []string{"NEW", "PROCESSED"}, From: &from, To: &to, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 10, got.Limit)
+}
+
+func TestValidateLedgerFilter(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	got, err := ValidateLedgerFilter(models.LedgerFilter{})
+	require.NoError(t, err)
+	require.Equal(t, DefaultPageLimit, got.Limit)
+
+	_, err = ValidateLedgerFilter(models.LedgerFilter{Limit: -1})
+	require.ErrorIs(t, err, domain.ErrInvalidPayload)
+
+	_, err = ValidateLedgerFilter(models.LedgerFilter{From: &to, To: &from})
+	require.ErrorIs(t, err, domain.ErrInvalidPayload)
+
+	got, err = ValidateLedgerFilter(models.LedgerFilter{From: &from, To: &to, Limit: 3})
+	require.NoError(t, err)
+	require.Equal(t, 3, got.Limit)
+}
+
+func TestValidateAPIKeyRequest(t *testing.T) {
+	got, err := ValidateAPIKeyRequest(models.APIKeyRequest{
+		Name:   "  shop  ",
+		Scopes: []string{models.ScopeOrdersWrite, models.ScopeBalanceRead, models.ScopeOrdersWrite},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "shop", got.Name)
+	require.Equal(t, []string{models.ScopeBalanceRead, models.ScopeOrdersWrite}, got.Scopes)
+
+	_, err = ValidateAPIKeyRequest(models.APIKeyRequest{Name: " ", Scopes: []string{models.ScopeOrdersRead}})
+	require.ErrorIs(t, err, domain.ErrInvalidPayload)
+
+	_, err = ValidateAPIKeyRequest(models.APIKeyRequest{Name: "shop"})
+	require.ErrorIs(t, err, domain.ErrInvalidPayload)
+
+	_, err = ValidateAPIKeyRequest(models.APIKeyRequest{Name: "shop", Scopes: []string{"balance:write"}})
+	require.ErrorIs(t, err, domain.ErrInvalidPayload)
+}
@@ -2,7 +2,9 @@ package job
 
 import (
 	"context"
+	"time"
 	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
 
 	"go.uber.org/zap"
 )
@@ -12,12 +14,35 @@ type Job interface {
 }
 
 type Service interface {
-	UpdateOrderProcessed(ctx context.Context, order models.Order, points float64) error
+	FinalizeOrder(ctx context.Context, order models.Order, points money.Amount) error
 	UpdateOrderInvalid(ctx context.Context, order models.Order) error
-	FetchNewOrders(ctx context.Context, limit int) ([]models.Order, error)
+	ApplyAccrual(ctx context.Context, order models.Order, ext models.Order) (bool, error)
+	ScheduleOrderRetry(ctx context.Context, order models.Order, next time.Time, reason string) error
+	DeadLetterOrder(ctx context.Context, order models.Order, reason string) error
+	ReleaseOrders(ctx context.Context, orders []models.Order) error
 	FetchProccesingOrders(ctx context.Context, limit int) ([]models.Order, error)
-	UpdateBalanceEntries(ctx context.Context, order models.Order) error
 	UpdateMissingBalanceEntries(ctx context.Context) error
 	GetOrderFromAccurual(ctx context.Context, number string) (models.Order, error)
+	CheckBalances(ctx context.Context, afterUserID uint64, limit int) ([]models.BalanceCheck, error)
+	RepairBalance(ctx context.Context, userID uint64) error
+	SaveReconcileReport(ctx context.Context, report models.ReconcileReport) error
+	SaveWorkerPoolStats(stats models.WorkerPoolStats)
+	GetAccrualStats(ctx context.Context) models.AccrualStats
 	GetLogger() *zap.Logger
 }
+
+// Queue очередь заданий для воркеров. Задание выдаётся одному воркеру на время
+// visibility и удаляется после AckTask; NackTask возвращает его в очередь к retryAt.
+type Queue interface {
+	EnqueueTasks(ctx context.Context, tasks ...models.Task) error
+	LeaseTasks(ctx context.Context, kinds []string, limit int, visibility time.Duration) ([]models.Task, error)
+	AckTask(ctx context.Context, task models.Task) error
+	NackTask(ctx context.Context, task models.Task, retryAt time.Time, reason string) error
+	// EnqueueNewOrders атомарно переводит до limit заказов из NEW в PROCESSING и ставит
+	// на каждый задание task(order). При любой ошибке заказы остаются в NEW.
+	EnqueueNewOrders(ctx context.Context, limit int, task func(models.Order) (models.Task, error)) ([]models.Order, error)
+	// CancelTasks удаляет невыданные задания с указанными ключами и возвращает ключи удалённых.
+	CancelTasks(ctx context.Context, keys []string) ([]string, error)
+	// CountTasks число заданий указанных видов, ожидающих воркера.
+	CountTasks(ctx context.Context, kinds []string) (int, error)
+}
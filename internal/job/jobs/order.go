@@ -3,6 +3,7 @@ package jobs
 import (
 	"context"
 	"errors"
+	"time"
 	"yandex-diplom/internal/domain"
 	"yandex-diplom/internal/job"
 	"yandex-diplom/internal/models"
@@ -18,48 +19,75 @@ const (
 )
 
 type OrderJob struct {
-	Order     models.Order
-	Throttler *job.Throttler
+	Order   models.Order
+	Limiter *job.Limiter
+	Retry   job.RetryPolicy
 }
 
 func (j *OrderJob) Process(ctx context.Context, svc job.Service, logger *zap.Logger) error {
-	if j.Throttler.IsPaused() {
-		logger.Debug("[OrderJob] throttled, skipping", zap.String("order", j.Order.Number))
-		return nil
-	}
-
-	ext, err := svc.GetOrderFromAccurual(ctx, j.Order.Number)
+	ext, err := j.fetch(ctx, svc, logger)
 	if err != nil {
-		if e := new(domain.TooManyRequestsError); errors.As(err, &e) {
-			logger.Info("[OrderJob] 429 received", zap.String("order", j.Order.Number))
-			j.Throttler.Pause(e.RetryAfter)
-			return nil
+		if ctx.Err() != nil {
+			return err
 		}
 		if errors.Is(err, domain.ErrNoContent) {
 			logger.Debug("[OrderJob] not created in accurual", zap.String("order", j.Order.Number))
-			return nil
+			return j.retry(ctx, svc, "order is not registered in accrual")
 		}
+
+		reason := err.Error()
+		if appErr := domain.GetAppErr(err); appErr != nil {
+			reason = appErr.Error()
+		}
+		// повтор назначается на самом заказе, задание очереди считается выполненным
+		logger.Warn("[OrderJob] accrual request failed", zap.String("order", j.Order.Number), zap.Error(err))
+		return j.retry(ctx, svc, reason)
+	}
+
+	done, err := svc.ApplyAccrual(ctx, j.Order, ext)
+	if err != nil || done {
 		return err
 	}
 
 	switch ext.Status {
 	case StatusRegistered, StatusProcessing:
-		return nil
-	case StatusInvalid:
-		return svc.UpdateOrderInvalid(ctx, j.Order)
-	case StatusProcessed:
-		err = svc.UpdateOrderProcessed(ctx, j.Order, ext.Accrual)
-		if err != nil {
-			return err
-		}
-		err = svc.UpdateBalanceEntries(ctx, j.Order)
-		if err != nil {
-			return err
-		}
-		return nil
+		return j.retry(ctx, svc, "accrual status "+ext.Status)
 	default:
 		logger.Warn("Unknown status", zap.String("status", ext.Status))
+		return j.retry(ctx, svc, "unknown accrual status "+ext.Status)
 	}
+}
+
+// retry назначает следующий опрос заказа или переводит его в dead letter,
+// если попытки или срок исчерпаны.
+func (j *OrderJob) retry(ctx context.Context, svc job.Service, reason string) error {
+	attempt := j.Order.Attempts + 1
+	now := time.Now()
+
+	if j.Retry.Exhausted(attempt, j.Order.UploadedAt, now) {
+		return svc.DeadLetterOrder(ctx, j.Order, reason)
+	}
+	return svc.ScheduleOrderRetry(ctx, j.Order, now.Add(j.Retry.Delay(attempt)), reason)
+}
 
-	return nil
+// fetch запрашивает заказ в пределах лимита. После 429 ждёт Retry-After,
+// подстраивает лимит под ответ сервиса и повторяет запрос.
+func (j *OrderJob) fetch(ctx context.Context, svc job.Service, logger *zap.Logger) (models.Order, error) {
+	for {
+		if err := j.Limiter.Wait(ctx); err != nil {
+			return models.Order{}, err
+		}
+
+		ext, err := svc.GetOrderFromAccurual(ctx, j.Order.Number)
+		e := new(domain.TooManyRequestsError)
+		if !errors.As(err, &e) {
+			return ext, err
+		}
+
+		logger.Info("[OrderJob] 429 received", zap.String("order", j.Order.Number), zap.Duration("retry_after", e.RetryAfter), zap.Int("limit", e.Limit))
+		j.Limiter.Pause(e.RetryAfter)
+		if e.Limit > 0 && e.Limit != j.Limiter.Rate() {
+			j.Limiter.SetRate(e.Limit)
+		}
+	}
 }
@@ -9,72 +9,138 @@ import (
 	"yandex-diplom/internal/job"
 	"yandex-diplom/internal/mocks"
 	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
-func TestOrderJob_Throttled(t *testing.T) {
-	th := job.NewThrottler()
-	th.Pause(time.Minute)
-
-	j := OrderJob{Order: models.Order{Number: "1"}, Throttler: th}
+func TestOrderJob_TooManyRequests(t *testing.T) {
+	limiter := job.NewLimiter(0)
+	j := OrderJob{Order: models.Order{Number: "1"}, Limiter: limiter}
 	mockSvc := new(mocks.MockService)
 
+	mockSvc.On("GetOrderFromAccurual", mock.Anything, "1").
+		Return(models.Order{}, domain.MakeError(errors.New("rate limit"), &domain.TooManyRequestsError{RetryAfter: 50 * time.Millisecond, Limit: 120})).Once()
+	mockSvc.On("GetOrderFromAccurual", mock.Anything, "1").
+		Return(models.Order{Number: "1", Status: "PROCESSED", Accrual: money.FromInt(10)}, nil).Once()
+	mockSvc.On("ApplyAccrual", mock.Anything, j.Order, models.Order{Number: "1", Status: "PROCESSED", Accrual: money.FromInt(10)}).Return(true, nil).Once()
+
+	start := time.Now()
 	err := j.Process(context.Background(), mockSvc, mockSvc.GetLogger())
 	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	require.Equal(t, 120, limiter.Rate())
 	mockSvc.AssertExpectations(t)
 }
 
-func TestOrderJob_TooManyRequests(t *testing.T) {
-	th := job.NewThrottler()
-	j := OrderJob{Order: models.Order{Number: "1"}, Throttler: th}
+func TestOrderJob_TooManyRequests_Cancelled(t *testing.T) {
+	j := OrderJob{Order: models.Order{Number: "1"}, Limiter: job.NewLimiter(0)}
 	mockSvc := new(mocks.MockService)
 
 	mockSvc.On("GetOrderFromAccurual", mock.Anything, "1").
-		Return(models.Order{}, domain.MakeError(errors.New("rate limit"), &domain.TooManyRequestsError{RetryAfter: time.Second}))
+		Return(models.Order{}, domain.MakeError(errors.New("rate limit"), &domain.TooManyRequestsError{RetryAfter: time.Hour})).Once()
 
-	err := j.Process(context.Background(), mockSvc, mockSvc.GetLogger())
-	require.NoError(t, err)
-	require.True(t, th.IsPaused())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := j.Process(ctx, mockSvc, mockSvc.GetLogger())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	mockSvc.AssertExpectations(t)
 }
 
 func TestOrderJob_NoContent(t *testing.T) {
-	th := job.NewThrottler()
-	j := OrderJob{Order: models.Order{Number: "1"}, Throttler: th}
+	j := OrderJob{Order: models.Order{Number: "1"}, Limiter: job.NewLimiter(0), Retry: job.RetryPolicy{BaseDelay: time.Minute}}
 	mockSvc := new(mocks.MockService)
 
 	mockSvc.On("GetOrderFromAccurual", mock.Anything, "1").
 		Return(models.Order{}, domain.MakeError(errors.New("not found"), domain.ErrNoContent))
+	mockSvc.On("ScheduleOrderRetry", mock.Anything, j.Order, mock.MatchedBy(func(next time.Time) bool {
+		return next.After(time.Now().Add(29 * time.Second))
+	}), "order is not registered in accrual").Return(nil).Once()
+
+	err := j.Process(context.Background(), mockSvc, mockSvc.GetLogger())
+	require.NoError(t, err)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestOrderJob_StillProcessing(t *testing.T) {
+	j := OrderJob{Order: models.Order{Number: "1", Attempts: 2}, Limiter: job.NewLimiter(0)}
+	mockSvc := new(mocks.MockService)
+
+	mockSvc.On("GetOrderFromAccurual", mock.Anything, "1").
+		Return(models.Order{Number: "1", Status: "PROCESSING"}, nil)
+	mockSvc.On("ApplyAccrual", mock.Anything, j.Order, mock.Anything).Return(false, nil)
+	mockSvc.On("ScheduleOrderRetry", mock.Anything, j.Order, mock.Anything, "accrual status PROCESSING").Return(nil).Once()
+
+	err := j.Process(context.Background(), mockSvc, mockSvc.GetLogger())
+	require.NoError(t, err)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestOrderJob_AccrualError(t *testing.T) {
+	j := OrderJob{Order: models.Order{Number: "1"}, Limiter: job.NewLimiter(0)}
+	mockSvc := new(mocks.MockService)
+
+	accrualErr := domain.MakeError(errors.New("unexpected status 500"), domain.ErrInternal)
+	mockSvc.On("GetOrderFromAccurual", mock.Anything, "1").Return(models.Order{}, accrualErr)
+	mockSvc.On("ScheduleOrderRetry", mock.Anything, j.Order, mock.Anything, "unexpected status 500").Return(nil).Once()
 
 	err := j.Process(context.Background(), mockSvc, mockSvc.GetLogger())
 	require.NoError(t, err)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestOrderJob_DeadLetter(t *testing.T) {
+	uploaded := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name  string
+		order models.Order
+		retry job.RetryPolicy
+	}{
+		{"attempts", models.Order{Number: "1", Attempts: 4}, job.RetryPolicy{MaxAttempts: 5}},
+		{"age", models.Order{Number: "1", UploadedAt: &uploaded}, job.RetryPolicy{MaxAge: time.Minute}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := OrderJob{Order: tt.order, Limiter: job.NewLimiter(0), Retry: tt.retry}
+			mockSvc := new(mocks.MockService)
+
+			mockSvc.On("GetOrderFromAccurual", mock.Anything, "1").
+				Return(models.Order{Number: "1", Status: "REGISTERED"}, nil)
+			mockSvc.On("ApplyAccrual", mock.Anything, tt.order, mock.Anything).Return(false, nil)
+			mockSvc.On("DeadLetterOrder", mock.Anything, tt.order, "accrual status REGISTERED").Return(nil).Once()
+
+			err := j.Process(context.Background(), mockSvc, mockSvc.GetLogger())
+			require.NoError(t, err)
+			mockSvc.AssertExpectations(t)
+		})
+	}
 }
 
 func TestOrderJob_InvalidStatus(t *testing.T) {
-	th := job.NewThrottler()
-	j := OrderJob{Order: models.Order{Number: "1"}, Throttler: th}
+	j := OrderJob{Order: models.Order{Number: "1"}, Limiter: job.NewLimiter(0)}
 	mockSvc := new(mocks.MockService)
 
 	mockSvc.On("GetOrderFromAccurual", mock.Anything, "1").
 		Return(models.Order{Number: "1", Status: "INVALID"}, nil)
-	mockSvc.On("UpdateOrderInvalid", mock.Anything, mock.Anything).Return(nil)
+	mockSvc.On("ApplyAccrual", mock.Anything, j.Order, models.Order{Number: "1", Status: "INVALID"}).Return(true, nil).Once()
 
 	err := j.Process(context.Background(), mockSvc, mockSvc.GetLogger())
 	require.NoError(t, err)
-	mockSvc.AssertCalled(t, "UpdateOrderInvalid", mock.Anything, mock.Anything)
+	mockSvc.AssertExpectations(t)
 }
 
 func TestOrderJob_Processed(t *testing.T) {
-	th := job.NewThrottler()
-	j := OrderJob{Order: models.Order{Number: "1"}, Throttler: th}
+	j := OrderJob{Order: models.Order{Number: "1"}, Limiter: job.NewLimiter(0)}
 	mockSvc := new(mocks.MockService)
 
 	mockSvc.On("GetOrderFromAccurual", mock.Anything, "1").
-		Return(models.Order{Number: "1", Status: "PROCESSED", Accrual: 10}, nil)
-	mockSvc.On("UpdateOrderProcessed", mock.Anything, mock.Anything, 10.0).Return(nil)
-	mockSvc.On("UpdateBalanceEntries", mock.Anything, mock.Anything).Return(nil)
+		Return(models.Order{Number: "1", Status: "PROCESSED", Accrual: money.FromInt(10)}, nil)
+	mockSvc.On("ApplyAccrual", mock.Anything, j.Order, models.Order{Number: "1", Status: "PROCESSED", Accrual: money.FromInt(10)}).Return(true, nil).Once()
 
 	err := j.Process(context.Background(), mockSvc, mockSvc.GetLogger())
 	require.NoError(t, err)
+	mockSvc.AssertExpectations(t)
 }
@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"context"
+	"time"
+	"yandex-diplom/internal/job"
+	"yandex-diplom/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// maxReconcileSamples сколько расхождений сохраняется в отчёте для админки.
+const maxReconcileSamples = 100
+
+// ReconcileJob сверяет кэш балансов с журналом пачками по BatchSize пользователей.
+// При Repair расхождения пересчитываются из журнала.
+type ReconcileJob struct {
+	BatchSize int
+	Repair    bool
+}
+
+func (j *ReconcileJob) Process(ctx context.Context, svc job.Service, logger *zap.Logger) error {
+	batch := j.BatchSize
+	if batch <= 0 {
+		batch = 500
+	}
+
+	report := models.ReconcileReport{StartedAt: time.Now(), Repair: j.Repair}
+
+	err := j.run(ctx, svc, logger, batch, &report)
+	report.FinishedAt = time.Now()
+	if err != nil {
+		report.Error = err.Error()
+	}
+	if saveErr := svc.SaveReconcileReport(ctx, report); saveErr != nil {
+		logger.Warn("[reconcile] failed to save report", zap.Error(saveErr))
+	}
+
+	logger.Info("[reconcile] finished",
+		zap.Int("checked", report.Checked),
+		zap.Int("mismatches", report.Mismatches),
+		zap.Int("repaired", report.Repaired),
+		zap.Duration("duration", report.FinishedAt.Sub(report.StartedAt)))
+
+	return err
+}
+
+func (j *ReconcileJob) run(ctx context.Context, svc job.Service, logger *zap.Logger, batch int, report *models.ReconcileReport) error {
+	var after uint64
+
+	for {
+		checks, err := svc.CheckBalances(ctx, after, batch)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range checks {
+			report.Checked++
+			if c.Consistent() {
+				continue
+			}
+
+			report.Mismatches++
+			if len(report.Samples) < maxReconcileSamples {
+				report.Samples = append(report.Samples, c)
+			}
+
+			logger.Warn("[reconcile] balance mismatch",
+				zap.Uint64("user", c.UserID),
+				zap.Stringer("cached_balance", c.CachedBalance),
+				zap.Stringer("ledger_balance", c.LedgerBalance),
+				zap.Stringer("cached_withdrawn", c.CachedWithdrawn),
+				zap.Stringer("ledger_withdrawn", c.LedgerWithdrawn))
+
+			if !j.Repair {
+				continue
+			}
+			if err := svc.RepairBalance(ctx, c.UserID); err != nil {
+				logger.Warn("[reconcile] repair failed", zap.Uint64("user", c.UserID), zap.Error(err))
+				continue
+			}
+			report.Repaired++
+		}
+
+		if len(checks) < batch {
+			return nil
+		}
+		after = checks[len(checks)-1].UserID
+	}
+}
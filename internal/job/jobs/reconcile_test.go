@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"yandex-diplom/internal/mocks"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileJob_Repair(t *testing.T) {
+	j := ReconcileJob{BatchSize: 2, Repair: true}
+	mockSvc := new(mocks.MockService)
+
+	mockSvc.On("CheckBalances", mock.Anything, uint64(0), 2).Return([]models.BalanceCheck{
+		{UserID: 1, CachedBalance: money.FromInt(5), LedgerBalance: money.FromInt(5)},
+		{UserID: 2, CachedBalance: money.FromInt(5), LedgerBalance: money.FromInt(7)},
+	}, nil)
+	mockSvc.On("CheckBalances", mock.Anything, uint64(2), 2).Return([]models.BalanceCheck{
+		{UserID: 3, CachedWithdrawn: money.FromInt(1)},
+	}, nil)
+	mockSvc.On("RepairBalance", mock.Anything, uint64(2)).Return(nil)
+	mockSvc.On("RepairBalance", mock.Anything, uint64(3)).Return(nil)
+
+	var report models.ReconcileReport
+	mockSvc.On("SaveReconcileReport", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		report = args.Get(1).(models.ReconcileReport)
+	}).Return(nil)
+
+	err := j.Process(context.Background(), mockSvc, mockSvc.GetLogger())
+	require.NoError(t, err)
+
+	require.Equal(t, 3, report.Checked)
+	require.Equal(t, 2, report.Mismatches)
+	require.Equal(t, 2, report.Repaired)
+	require.Len(t, report.Samples, 2)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestReconcileJob_ReportOnly(t *testing.T) {
+	j := ReconcileJob{BatchSize: 10}
+	mockSvc := new(mocks.MockService)
+
+	mockSvc.On("CheckBalances", mock.Anything, uint64(0), 10).Return([]models.BalanceCheck{
+		{UserID: 4, CachedBalance: money.FromInt(1)},
+	}, nil)
+
+	var report models.ReconcileReport
+	mockSvc.On("SaveReconcileReport", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		report = args.Get(1).(models.ReconcileReport)
+	}).Return(nil)
+
+	err := j.Process(context.Background(), mockSvc, mockSvc.GetLogger())
+	require.NoError(t, err)
+
+	require.Equal(t, 1, report.Mismatches)
+	require.Zero(t, report.Repaired)
+	mockSvc.AssertNotCalled(t, "RepairBalance", mock.Anything, mock.Anything)
+}
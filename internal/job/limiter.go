@@ -0,0 +1,92 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter общий для воркеров лимит запросов к системе начислений.
+// Запросы распределяются равномерно: не чаще одного за минуту/rpm.
+type Limiter struct {
+	mu          sync.Mutex
+	now         func() time.Time
+	interval    time.Duration
+	next        time.Time
+	pausedUntil time.Time
+}
+
+// NewLimiter создаёт лимитер на rpm запросов в минуту, rpm <= 0 без ограничения.
+func NewLimiter(rpm int) *Limiter {
+	l := &Limiter{now: time.Now}
+	l.SetRate(rpm)
+	return l
+}
+
+// SetRate меняет бюджет запросов в минуту.
+func (l *Limiter) SetRate(rpm int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if rpm <= 0 {
+		l.interval = 0
+		return
+	}
+	l.interval = time.Minute / time.Duration(rpm)
+}
+
+// Rate текущий бюджет запросов в минуту, 0 без ограничения.
+func (l *Limiter) Rate() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.interval == 0 {
+		return 0
+	}
+	return int(time.Minute / l.interval)
+}
+
+// Pause запрещает запросы на d, например после 429.
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := l.now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// Wait блокирует до момента, когда можно выполнить запрос. Слот занимается
+// только после ожидания, отменённый вызов не сдвигает остальных.
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if err := ctx.Err(); err != nil {
+			l.mu.Unlock()
+			return err
+		}
+
+		now := l.now()
+		at := now
+		if l.pausedUntil.After(at) {
+			at = l.pausedUntil
+		}
+		if l.next.After(at) {
+			at = l.next
+		}
+		if !at.After(now) {
+			l.next = now.Add(l.interval)
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(at.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Unlimited(t *testing.T) {
+	l := NewLimiter(0)
+
+	start := time.Now()
+	for range 100 {
+		require.NoError(t, l.Wait(context.Background()))
+	}
+	require.Less(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestLimiter_Rate(t *testing.T) {
+	// 1200 в минуту — один запрос в 50мс
+	l := NewLimiter(1200)
+	require.Equal(t, 1200, l.Rate())
+
+	start := time.Now()
+	for range 4 {
+		require.NoError(t, l.Wait(context.Background()))
+	}
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestLimiter_Pause(t *testing.T) {
+	l := NewLimiter(0)
+	l.Pause(50 * time.Millisecond)
+
+	start := time.Now()
+	require.NoError(t, l.Wait(context.Background()))
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestLimiter_WaitCancelled(t *testing.T) {
+	l := NewLimiter(0)
+	l.Pause(time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+}
+
+func TestLimiter_CancelledWaitKeepsSlot(t *testing.T) {
+	// 600 в минуту — один запрос в 100мс
+	l := NewLimiter(600)
+	require.NoError(t, l.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+
+	start := time.Now()
+	require.NoError(t, l.Wait(context.Background()))
+	require.Less(t, time.Since(start), 150*time.Millisecond)
+}
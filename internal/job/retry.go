@@ -0,0 +1,40 @@
+package job
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy расписание повторных опросов заказа в системе начислений.
+type RetryPolicy struct {
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxAttempts и MaxAge ограничивают опрос, после них заказ уходит в dead letter.
+	// Нулевые значения не ограничивают.
+	MaxAttempts int
+	MaxAge      time.Duration
+}
+
+// Delay задержка перед попыткой attempt (с 1): BaseDelay·2^(attempt-1), не больше
+// MaxDelay, со случайным разбросом в [d/2, d], чтобы заказы не опрашивались пачками.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Exhausted сообщает, что после попытки attempt заказ больше не опрашивается.
+func (p RetryPolicy) Exhausted(attempt int, uploadedAt *time.Time, now time.Time) bool {
+	if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+		return true
+	}
+	return p.MaxAge > 0 && uploadedAt != nil && now.Sub(*uploadedAt) >= p.MaxAge
+}
@@ -0,0 +1,43 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			d := p.Delay(tt.attempt)
+			require.GreaterOrEqual(t, d, tt.max/2, "attempt %d", tt.attempt)
+			require.LessOrEqual(t, d, tt.max, "attempt %d", tt.attempt)
+		}
+	}
+}
+
+func TestRetryPolicy_Exhausted(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	fresh, old := now.Add(-time.Minute), now.Add(-48*time.Hour)
+	p := RetryPolicy{MaxAttempts: 3, MaxAge: 24 * time.Hour}
+
+	require.False(t, p.Exhausted(1, &fresh, now))
+	require.True(t, p.Exhausted(3, &fresh, now))
+	require.True(t, p.Exhausted(1, &old, now))
+	require.False(t, RetryPolicy{}.Exhausted(100, &old, now))
+}
@@ -2,7 +2,9 @@ package mocks
 
 import (
 	"context"
+	"time"
 	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -51,7 +53,12 @@ func (m *Repository) GetOrders(ctx context.Context, user string) ([]models.Order
 	return args.Get(0).([]models.Order), args.Error(1)
 }
 
-func (m *Repository) UpdateOrderProcessed(ctx context.Context, order string, points float64) error {
+func (m *Repository) GetOrdersPage(ctx context.Context, user string, filter models.OrderFilter) (models.OrderPage, error) {
+	args := m.Called(ctx, user, filter)
+	return args.Get(0).(models.OrderPage), args.Error(1)
+}
+
+func (m *Repository) FinalizeOrder(ctx context.Context, order models.Order, points money.Amount) error {
 	args := m.Called(ctx, order, points)
 	return args.Error(0)
 }
@@ -71,11 +78,6 @@ func (m *Repository) FetchProccesingOrders(ctx context.Context, limit int) ([]mo
 	return args.Get(0).([]models.Order), args.Error(1)
 }
 
-func (m *Repository) UpdateBalanceEntries(ctx context.Context, order models.Order) error {
-	args := m.Called(ctx, order)
-	return args.Error(0)
-}
-
 func (m *Repository) UpdateMissingBalanceEntries(ctx context.Context) error {
 	args := m.Called(ctx)
 	return args.Error(0)
@@ -100,3 +102,153 @@ func (m *Repository) GetWithdrawls(ctx context.Context, userID uint64) ([]models
 	args := m.Called(ctx, userID)
 	return args.Get(0).([]models.Withdrawal), args.Error(1)
 }
+
+func (m *Repository) ReserveIdempotencyKey(ctx context.Context, userID uint64, key string, requestHash string, ttl time.Duration) (models.IdempotentResponse, bool, error) {
+	args := m.Called(ctx, userID, key, requestHash, ttl)
+	return args.Get(0).(models.IdempotentResponse), args.Bool(1), args.Error(2)
+}
+
+func (m *Repository) CompleteIdempotencyKey(ctx context.Context, userID uint64, key string, resp models.IdempotentResponse) error {
+	args := m.Called(ctx, userID, key, resp)
+	return args.Error(0)
+}
+
+func (m *Repository) ReleaseIdempotencyKey(ctx context.Context, userID uint64, key string) error {
+	args := m.Called(ctx, userID, key)
+	return args.Error(0)
+}
+
+func (m *Repository) AdjustBalance(ctx context.Context, adj models.Adjustment) (models.BalanceEntry, error) {
+	args := m.Called(ctx, adj)
+	return args.Get(0).(models.BalanceEntry), args.Error(1)
+}
+
+func (m *Repository) ReverseBalanceEntry(ctx context.Context, rev models.Reversal) (models.BalanceEntry, error) {
+	args := m.Called(ctx, rev)
+	return args.Get(0).(models.BalanceEntry), args.Error(1)
+}
+
+func (m *Repository) GetBalanceEntries(ctx context.Context, userID uint64) ([]models.BalanceEntry, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.BalanceEntry), args.Error(1)
+}
+
+func (m *Repository) SetUserRole(ctx context.Context, login string, role string) error {
+	args := m.Called(ctx, login, role)
+	return args.Error(0)
+}
+
+func (m *Repository) ResetOrder(ctx context.Context, orderNumber string) error {
+	args := m.Called(ctx, orderNumber)
+	return args.Error(0)
+}
+
+func (m *Repository) InvalidateOrder(ctx context.Context, orderNumber string) error {
+	args := m.Called(ctx, orderNumber)
+	return args.Error(0)
+}
+
+func (m *Repository) ScheduleOrderRetry(ctx context.Context, orderNumber string, next time.Time, reason string) error {
+	args := m.Called(ctx, orderNumber, next, reason)
+	return args.Error(0)
+}
+
+func (m *Repository) DeadLetterOrder(ctx context.Context, orderNumber string, reason string) error {
+	args := m.Called(ctx, orderNumber, reason)
+	return args.Error(0)
+}
+
+func (m *Repository) ClaimOrder(ctx context.Context, orderNumber string) (models.Order, error) {
+	args := m.Called(ctx, orderNumber)
+	return args.Get(0).(models.Order), args.Error(1)
+}
+
+func (m *Repository) ReleaseOrders(ctx context.Context, orderNumbers []string) error {
+	args := m.Called(ctx, orderNumbers)
+	return args.Error(0)
+}
+
+func (m *Repository) GetDeadLetterOrders(ctx context.Context, limit int) ([]models.DeadLetterOrder, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]models.DeadLetterOrder), args.Error(1)
+}
+
+func (m *Repository) GetLedgerPage(ctx context.Context, userID uint64, filter models.LedgerFilter) (models.LedgerPage, error) {
+	args := m.Called(ctx, userID, filter)
+	return args.Get(0).(models.LedgerPage), args.Error(1)
+}
+
+// StreamBalanceEntries отдаёт в opening баланс из Return вторым аргументом,
+// а в fn записи, переданные третьим.
+func (m *Repository) StreamBalanceEntries(ctx context.Context, userID uint64, from, to time.Time, opening func(money.Amount) error, fn func(models.BalanceEntry) error) error {
+	args := m.Called(ctx, userID, from, to)
+	if err := args.Error(0); err != nil {
+		return err
+	}
+	if err := opening(args.Get(1).(money.Amount)); err != nil {
+		return err
+	}
+	if entries, ok := args.Get(2).([]models.BalanceEntry); ok {
+		for _, e := range entries {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(0)
+}
+
+func (m *Repository) CheckBalances(ctx context.Context, afterUserID uint64, limit int) ([]models.BalanceCheck, error) {
+	args := m.Called(ctx, afterUserID, limit)
+	return args.Get(0).([]models.BalanceCheck), args.Error(1)
+}
+
+func (m *Repository) SaveReconcileReport(ctx context.Context, report models.ReconcileReport) error {
+	args := m.Called(ctx, report)
+	return args.Error(0)
+}
+
+func (m *Repository) GetReconcileReport(ctx context.Context) (models.ReconcileReport, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(models.ReconcileReport), args.Error(1)
+}
+
+func (m *Repository) CreateSession(ctx context.Context, session models.Session, refreshHash string) error {
+	args := m.Called(ctx, session, refreshHash)
+	return args.Error(0)
+}
+
+func (m *Repository) RotateRefreshToken(ctx context.Context, oldHash string, newHash string, expiresAt time.Time) (models.Session, error) {
+	args := m.Called(ctx, oldHash, newHash, expiresAt)
+	return args.Get(0).(models.Session), args.Error(1)
+}
+
+func (m *Repository) RevokeSession(ctx context.Context, sessionID string) error {
+	args := m.Called(ctx, sessionID)
+	return args.Error(0)
+}
+
+func (m *Repository) ListRevokedSessions(ctx context.Context, since time.Time) ([]string, error) {
+	args := m.Called(ctx, since)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *Repository) CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (models.APIKey, error) {
+	args := m.Called(ctx, key, keyHash)
+	return args.Get(0).(models.APIKey), args.Error(1)
+}
+
+func (m *Repository) UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	args := m.Called(ctx, keyHash)
+	return args.Get(0).(models.APIKey), args.Error(1)
+}
+
+func (m *Repository) ListAPIKeys(ctx context.Context, userID uint64) ([]models.APIKey, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (m *Repository) RevokeAPIKey(ctx context.Context, userID uint64, id int64) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
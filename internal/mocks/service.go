@@ -2,7 +2,9 @@ package mocks
 
 import (
 	"context"
+	"time"
 	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
 
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
@@ -12,7 +14,7 @@ type MockService struct {
 	mock.Mock
 }
 
-func (m *MockService) UpdateOrderProcessed(ctx context.Context, order models.Order, points float64) error {
+func (m *MockService) FinalizeOrder(ctx context.Context, order models.Order, points money.Amount) error {
 	args := m.Called(ctx, order, points)
 	return args.Error(0)
 }
@@ -22,21 +24,31 @@ func (m *MockService) UpdateOrderInvalid(ctx context.Context, order models.Order
 	return args.Error(0)
 }
 
-func (m *MockService) FetchNewOrders(ctx context.Context, limit int) ([]models.Order, error) {
-	args := m.Called(ctx, limit)
-	return args.Get(0).([]models.Order), args.Error(1)
+func (m *MockService) ApplyAccrual(ctx context.Context, order models.Order, ext models.Order) (bool, error) {
+	args := m.Called(ctx, order, ext)
+	return args.Bool(0), args.Error(1)
 }
 
-func (m *MockService) FetchProccesingOrders(ctx context.Context, limit int) ([]models.Order, error) {
-	args := m.Called(ctx, limit)
-	return args.Get(0).([]models.Order), args.Error(1)
+func (m *MockService) ScheduleOrderRetry(ctx context.Context, order models.Order, next time.Time, reason string) error {
+	args := m.Called(ctx, order, next, reason)
+	return args.Error(0)
 }
 
-func (m *MockService) UpdateBalanceEntries(ctx context.Context, order models.Order) error {
-	args := m.Called(ctx, order)
+func (m *MockService) DeadLetterOrder(ctx context.Context, order models.Order, reason string) error {
+	args := m.Called(ctx, order, reason)
 	return args.Error(0)
 }
 
+func (m *MockService) ReleaseOrders(ctx context.Context, orders []models.Order) error {
+	args := m.Called(ctx, orders)
+	return args.Error(0)
+}
+
+func (m *MockService) FetchProccesingOrders(ctx context.Context, limit int) ([]models.Order, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]models.Order), args.Error(1)
+}
+
 func (m *MockService) UpdateMissingBalanceEntries(ctx context.Context) error {
 	args := m.Called(ctx)
 	return args.Error(0)
@@ -47,6 +59,30 @@ func (m *MockService) GetOrderFromAccurual(ctx context.Context, number string) (
 	return args.Get(0).(models.Order), args.Error(1)
 }
 
+func (m *MockService) CheckBalances(ctx context.Context, afterUserID uint64, limit int) ([]models.BalanceCheck, error) {
+	args := m.Called(ctx, afterUserID, limit)
+	return args.Get(0).([]models.BalanceCheck), args.Error(1)
+}
+
+func (m *MockService) RepairBalance(ctx context.Context, userID uint64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockService) SaveReconcileReport(ctx context.Context, report models.ReconcileReport) error {
+	args := m.Called(ctx, report)
+	return args.Error(0)
+}
+
+func (m *MockService) SaveWorkerPoolStats(stats models.WorkerPoolStats) {
+	m.Called(stats)
+}
+
+func (m *MockService) GetAccrualStats(ctx context.Context) models.AccrualStats {
+	args := m.Called(ctx)
+	return args.Get(0).(models.AccrualStats)
+}
+
 func (m *MockService) GetLogger() *zap.Logger {
 	logger, _ := zap.NewDevelopment()
 	return logger
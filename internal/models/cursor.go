@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor позиция keyset-пагинации по паре (время, id), отсортированной по убыванию.
+type Cursor struct {
+	At time.Time
+	ID int64
+}
+
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.At.UnixNano(), 10) + ":" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	at, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	nanos, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	entryID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || entryID <= 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return Cursor{At: time.Unix(0, nanos).UTC(), ID: entryID}, nil
+}
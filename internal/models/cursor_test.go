@@ -0,0 +1,24 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	c := Cursor{At: time.Date(2020, 12, 10, 15, 15, 45, 123456000, time.UTC), ID: 42}
+
+	got, err := ParseCursor(c.Encode())
+	require.NoError(t, err)
+	require.True(t, c.At.Equal(got.At))
+	require.Equal(t, c.ID, got.ID)
+}
+
+func TestParseCursor_Invalid(t *testing.T) {
+	for _, s := range []string{"", "!!!", "bm9jb2xvbg", "MTIzOmFiYw", "MTIzOi0x"} {
+		_, err := ParseCursor(s)
+		require.ErrorIs(t, err, ErrInvalidCursor, s)
+	}
+}
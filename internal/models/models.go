@@ -1,29 +1,268 @@
 package models
 
-import "time"
+import (
+	"time"
+	"yandex-diplom/internal/money"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Права API-ключей. Сессия пользователя ими не ограничена.
+const (
+	ScopeOrdersWrite = "orders:write"
+	ScopeOrdersRead  = "orders:read"
+	ScopeBalanceRead = "balance:read"
+)
 
 type User struct {
 	ID       uint64 `json:"id"`
 	Login    string `json:"login"`
 	Password string `json:"password"`
+	Role     string `json:"-"`
+}
+
+// Session сессия входа. Живёт, пока её refresh-токен обновляется до ExpiresAt
+// и она не отозвана.
+type Session struct {
+	ID        string
+	UserID    uint64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
+
+// Tokens пара токенов, выдаваемая при входе и при обновлении.
+type Tokens struct {
+	Access         string
+	AccessExpires  time.Time
+	Refresh        string
+	RefreshExpires time.Time
+}
+
+// AuthToken тело ответа входа для клиентов, запросивших JSON.
+type AuthToken struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type UserInfo struct {
+	ID      uint64  `json:"id"`
+	Login   string  `json:"login"`
+	Role    string  `json:"role"`
+	Balance Balance `json:"balance"`
 }
 
 type Order struct {
-	UserID     uint64     `json:"user_id"`
-	Number     string     `json:"number"`
-	Status     string     `json:"status"`
-	Accrual    float64    `json:"accrual,omitempty"`
-	UploadedAt *time.Time `json:"uploaded_at,omitempty"`
+	UserID     uint64       `json:"user_id"`
+	Number     string       `json:"number"`
+	Status     string       `json:"status"`
+	Accrual    money.Amount `json:"accrual,omitempty"`
+	UploadedAt *time.Time   `json:"uploaded_at,omitempty"`
+	// Attempts число неудачных опросов системы начислений.
+	Attempts int `json:"-"`
 }
 
 type Balance struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current   money.Amount `json:"current"`
+	Withdrawn money.Amount `json:"withdrawn"`
 }
 
 type Withdrawal struct {
-	UserID      string     `json:"user_id"`
-	Order       string     `json:"order"`
-	Sum         float64    `json:"sum"`
-	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+	UserID      string       `json:"user_id"`
+	Order       string       `json:"order"`
+	Sum         money.Amount `json:"sum"`
+	ProcessedAt *time.Time   `json:"processed_at,omitempty"`
+}
+
+type IdempotentResponse struct {
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	Completed   bool
+}
+
+const (
+	EntryAccrual    = "accrual"
+	EntryWithdrawal = "withdrawal"
+	EntryAdjustment = "adjustment"
+)
+
+// BalanceEntry запись журнала баллов. Amount — влияние на баланс со знаком.
+type BalanceEntry struct {
+	ID         int64        `json:"id"`
+	UserID     uint64       `json:"user_id"`
+	Type       string       `json:"type"`
+	Amount     money.Amount `json:"amount"`
+	Order      string       `json:"order,omitempty"`
+	Reference  string       `json:"reference,omitempty"`
+	Reason     string       `json:"reason,omitempty"`
+	OperatorID uint64       `json:"operator_id,omitempty"`
+	ReversesID int64        `json:"reverses_id,omitempty"`
+	PostedAt   *time.Time   `json:"posted_at,omitempty"`
+}
+
+type Adjustment struct {
+	UserID     uint64       `json:"-"`
+	Amount     money.Amount `json:"amount"`
+	Reason     string       `json:"reason"`
+	OperatorID uint64       `json:"-"`
+}
+
+// APIKey ключ партнёрской интеграции. Сам ключ не хранится, только его хэш;
+// Prefix помогает узнать ключ в списке.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     uint64     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  uint64     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IssuedAPIKey новый ключ; Key показывается один раз при выпуске.
+type IssuedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type APIKeyRequest struct {
+	UserID    uint64   `json:"-"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	CreatedBy uint64   `json:"-"`
+}
+
+type Reversal struct {
+	EntryID    int64  `json:"-"`
+	Reason     string `json:"reason"`
+	OperatorID uint64 `json:"-"`
+}
+
+// DeadLetterOrder заказ, снятый с опроса после исчерпания попыток.
+type DeadLetterOrder struct {
+	Number         string     `json:"number"`
+	UserID         uint64     `json:"user_id"`
+	Login          string     `json:"login"`
+	Attempts       int        `json:"attempts"`
+	LastError      string     `json:"last_error,omitempty"`
+	UploadedAt     *time.Time `json:"uploaded_at,omitempty"`
+	DeadLetteredAt *time.Time `json:"dead_lettered_at,omitempty"`
+}
+
+const (
+	OrderNew        = "NEW"
+	OrderProcessing = "PROCESSING"
+	OrderInvalid    = "INVALID"
+	OrderProcessed  = "PROCESSED"
+)
+
+// OrderFilter параметры постраничной выдачи заказов. Пустые поля не фильтруют.
+type OrderFilter struct {
+	Statuses []string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+	After    *Cursor
+}
+
+type OrderPage struct {
+	Orders []Order
+	Next   *Cursor
+}
+
+type LedgerEntry struct {
+	BalanceEntry
+	RunningBalance money.Amount `json:"running_balance"`
+}
+
+type LedgerFilter struct {
+	From  *time.Time
+	To    *time.Time
+	Limit int
+	After *Cursor
+}
+
+type LedgerPage struct {
+	Entries []LedgerEntry
+	Next    *Cursor
+}
+
+// Statement выписка по счёту за период [From, To).
+type Statement struct {
+	Month   string       `json:"month"`
+	From    time.Time    `json:"from"`
+	To      time.Time    `json:"to"`
+	Opening money.Amount `json:"opening_balance"`
+	Closing money.Amount `json:"closing_balance"`
+}
+
+// BalanceCheck сравнение кэша user_point_balances с суммой по журналу.
+type BalanceCheck struct {
+	UserID          uint64       `json:"user_id"`
+	CachedBalance   money.Amount `json:"cached_balance"`
+	LedgerBalance   money.Amount `json:"ledger_balance"`
+	CachedWithdrawn money.Amount `json:"cached_withdrawn"`
+	LedgerWithdrawn money.Amount `json:"ledger_withdrawn"`
+}
+
+func (c BalanceCheck) Consistent() bool {
+	return c.CachedBalance == c.LedgerBalance && c.CachedWithdrawn == c.LedgerWithdrawn
+}
+
+// ReconcileReport итог одного прогона сверки балансов.
+type ReconcileReport struct {
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Checked    int            `json:"checked"`
+	Mismatches int            `json:"mismatches"`
+	Repaired   int            `json:"repaired"`
+	Repair     bool           `json:"repair"`
+	Samples    []BalanceCheck `json:"samples,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// AccrualStats счётчики запросов к системе начислений.
+type AccrualStats struct {
+	Requests        int64         `json:"requests"`
+	Succeeded       int64         `json:"succeeded"`
+	NotRegistered   int64         `json:"not_registered"`
+	TooManyRequests int64         `json:"too_many_requests"`
+	ServerErrors    int64         `json:"server_errors"`
+	Timeouts        int64         `json:"timeouts"`
+	Failures        int64         `json:"failures"`
+	Rejected        int64         `json:"rejected"`
+	AvgLatency      time.Duration `json:"avg_latency_ns"`
+	BreakerState    string        `json:"breaker_state"`
+}
+
+// Task задание из очереди job_queue.
+type Task struct {
+	ID   int64
+	Kind string
+	// Key исключает повторную постановку, пока задание с тем же ключом в очереди. Пустой не ограничивает.
+	Key       string
+	Payload   []byte
+	Attempts  int
+	LastError string
+}
+
+// WorkerPoolStats состояние пула воркеров на момент последнего масштабирования.
+type WorkerPoolStats struct {
+	Workers        int           `json:"workers"`
+	Busy           int           `json:"busy"`
+	Idle           int           `json:"idle"`
+	MinWorkers     int           `json:"min_workers"`
+	MaxWorkers     int           `json:"max_workers"`
+	QueueLength    int           `json:"queue_length"`
+	AccrualLatency time.Duration `json:"accrual_latency_ns"`
+	UpdatedAt      time.Time     `json:"updated_at"`
 }
@@ -0,0 +1,181 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale количество знаков после запятой, соответствует NUMERIC(14,2).
+const Scale = 2
+
+const factor = 100
+
+var (
+	ErrPrecision = errors.New("amount has more than two decimal places")
+	ErrRange     = errors.New("amount is out of range")
+	ErrSyntax    = errors.New("amount is not a number")
+)
+
+// Amount сумма баллов в сотых долях, чтобы не терять копейки на float.
+type Amount int64
+
+// FromInt возвращает сумму из целого количества баллов.
+func FromInt(points int64) Amount {
+	return Amount(points * factor)
+}
+
+// FromCents возвращает сумму из сотых долей балла.
+func FromCents(cents int64) Amount {
+	return Amount(cents)
+}
+
+// Cents возвращает сумму в сотых долях балла.
+func (a Amount) Cents() int64 {
+	return int64(a)
+}
+
+// Float64 нужен только для отчётов и логов, в расчётах не используется.
+func (a Amount) Float64() float64 {
+	return float64(a) / factor
+}
+
+// Parse разбирает десятичную запись и отклоняет больше двух знаков после запятой.
+func Parse(s string) (Amount, error) {
+	r, err := parseRat(s)
+	if err != nil {
+		return 0, err
+	}
+	if !r.IsInt() {
+		return 0, fmt.Errorf("%w: %q", ErrPrecision, s)
+	}
+	return fromIntRat(r, s)
+}
+
+// ParseRound разбирает десятичную запись, округляя до сотых (half away from zero).
+// Используется для ответов внешних систем, которые мы не можем отклонить.
+func ParseRound(s string) (Amount, error) {
+	r, err := parseRat(s)
+	if err != nil {
+		return 0, err
+	}
+	if !r.IsInt() {
+		num := new(big.Int).Set(r.Num())
+		den := r.Denom()
+		half := new(big.Int).Quo(den, big.NewInt(2))
+		if num.Sign() < 0 {
+			num.Sub(num, half)
+		} else {
+			num.Add(num, half)
+		}
+		r.SetInt(num.Quo(num, den))
+	}
+	return fromIntRat(r, s)
+}
+
+func parseRat(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.ContainsRune(s, '/') {
+		return nil, fmt.Errorf("%w: %q", ErrSyntax, s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrSyntax, s)
+	}
+	return r.Mul(r, big.NewRat(factor, 1)), nil
+}
+
+func fromIntRat(r *big.Rat, s string) (Amount, error) {
+	n := r.Num()
+	if !n.IsInt64() {
+		return 0, fmt.Errorf("%w: %q", ErrRange, s)
+	}
+	return Amount(n.Int64()), nil
+}
+
+// String возвращает минимальную десятичную запись: 500, 500.5, 42.55.
+func (a Amount) String() string {
+	sign, whole, frac := a.parts()
+	switch {
+	case frac == 0:
+		return fmt.Sprintf("%s%d", sign, whole)
+	case frac%10 == 0:
+		return fmt.Sprintf("%s%d.%d", sign, whole, frac/10)
+	default:
+		return fmt.Sprintf("%s%d.%02d", sign, whole, frac)
+	}
+}
+
+func (a Amount) parts() (string, uint64, uint64) {
+	sign := ""
+	v := uint64(a)
+	if a < 0 {
+		sign = "-"
+		v = -v
+	}
+	return sign, v / factor, v % factor
+}
+
+// MarshalJSON сохраняет числовой формат из спецификации.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		return fmt.Errorf("%w: %s", ErrSyntax, s)
+	}
+
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Value отдаёт сумму строкой, чтобы NUMERIC получил её без округления.
+func (a Amount) Value() (driver.Value, error) {
+	sign, whole, frac := a.parts()
+	return fmt.Sprintf("%s%d.%02d", sign, whole, frac), nil
+}
+
+func (a *Amount) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	case int64:
+		*a = FromInt(v)
+		return nil
+	case float64:
+		parsed, err := ParseRound(strconv.FormatFloat(v, 'f', -1, 64))
+		if err != nil {
+			return err
+		}
+		*a = parsed
+		return nil
+	default:
+		return fmt.Errorf("money: unsupported scan type %T", src)
+	}
+}
+
+func (a *Amount) scanString(s string) error {
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
@@ -0,0 +1,95 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr error
+	}{
+		{"500", FromInt(500), nil},
+		{"500.5", FromCents(50050), nil},
+		{"42.55", FromCents(4255), nil},
+		{"0.01", FromCents(1), nil},
+		{"-3.1", FromCents(-310), nil},
+		{"1e2", FromInt(100), nil},
+		{"0.001", 0, ErrPrecision},
+		{"10.555", 0, ErrPrecision},
+		{"abc", 0, ErrSyntax},
+		{"1/3", 0, ErrSyntax},
+		{"", 0, ErrSyntax},
+		{"99999999999999999999", 0, ErrRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseRound(t *testing.T) {
+	got, err := ParseRound("729.985")
+	require.NoError(t, err)
+	require.Equal(t, FromCents(72999), got)
+
+	got, err = ParseRound("-0.005")
+	require.NoError(t, err)
+	require.Equal(t, FromCents(-1), got)
+}
+
+func TestString(t *testing.T) {
+	require.Equal(t, "500", FromInt(500).String())
+	require.Equal(t, "500.5", FromCents(50050).String())
+	require.Equal(t, "42.05", FromCents(4205).String())
+	require.Equal(t, "-0.3", FromCents(-30).String())
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		Sum Amount `json:"sum"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(`{"sum": 751.25}`), &v))
+	require.Equal(t, FromCents(75125), v.Sum)
+
+	require.ErrorIs(t, json.Unmarshal([]byte(`{"sum": 751.255}`), &v), ErrPrecision)
+	require.Error(t, json.Unmarshal([]byte(`{"sum": "751"}`), &v))
+
+	out, err := json.Marshal(struct {
+		Current   Amount `json:"current"`
+		Withdrawn Amount `json:"withdrawn"`
+	}{FromCents(50050), FromInt(42)})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"current": 500.5, "withdrawn": 42}`, string(out))
+}
+
+func TestSQL(t *testing.T) {
+	v, err := FromCents(-1205).Value()
+	require.NoError(t, err)
+	require.Equal(t, "-12.05", v)
+
+	var a Amount
+	require.NoError(t, a.Scan([]byte("12.50")))
+	require.Equal(t, FromCents(1250), a)
+
+	require.NoError(t, a.Scan(float64(0.1)+float64(0.2)))
+	require.Equal(t, FromCents(30), a)
+
+	require.NoError(t, a.Scan(nil))
+	require.Equal(t, Amount(0), a)
+
+	require.Error(t, a.Scan(true))
+}
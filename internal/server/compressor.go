@@ -35,3 +35,15 @@ func (c *compressWriter) WriteHeader(statusCode int) {
 func (c *compressWriter) Close() error {
 	return c.zw.Close()
 }
+
+// FlushError отправляет клиенту уже сжатые данные, нужен потоковым ответам.
+func (c *compressWriter) FlushError() error {
+	if err := c.zw.Flush(); err != nil {
+		return err
+	}
+	return http.NewResponseController(c.w).Flush()
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.w
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressWriter_Flush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	cw := newCompressWriter(rec)
+
+	lw := &loggerRW{ResponseWriter: cw, responseData: &responseData{}}
+	_, err := lw.Write([]byte("first line\n"))
+	require.NoError(t, err)
+	require.False(t, rec.Flushed)
+
+	require.NoError(t, http.NewResponseController(lw).Flush())
+	require.True(t, rec.Flushed)
+
+	zr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	buf := make([]byte, len("first line\n"))
+	_, err = io.ReadFull(zr, buf)
+	require.NoError(t, err)
+	require.Equal(t, "first line\n", string(buf))
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"yandex-diplom/internal/auth"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/lib"
+	"yandex-diplom/internal/models"
+)
+
+const (
+	idempotencyHeader   = "Idempotency-Key"
+	idempotencyTTL      = 24 * time.Hour
+	idempotencyKeyLimit = 255
+)
+
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, userID uint64, key string, requestHash string, ttl time.Duration) (models.IdempotentResponse, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, userID uint64, key string, resp models.IdempotentResponse) error
+	ReleaseIdempotencyKey(ctx context.Context, userID uint64, key string) error
+	WriteError(w http.ResponseWriter, err error)
+}
+
+type recorderRW struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recorderRW) WriteHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recorderRW) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Idempotency запоминает ответ на запрос с заголовком Idempotency-Key и
+// отдаёт его повторно на ретраи того же пользователя. Должен стоять после auth.Middleware.
+func Idempotency(store IdempotencyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			const op = "server.Idempotency"
+
+			key := r.Header.Get(idempotencyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user := auth.GetUserFromContext(r.Context())
+			if user == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if len(key) > idempotencyKeyLimit {
+				store.WriteError(w, domain.MakeError(
+					lib.StandardError(op, errors.New("idempotency key is too long")),
+					domain.ErrInvalidPayload))
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, 5<<20))
+			if err != nil {
+				store.WriteError(w, domain.MakeError(lib.StandardError(op, err), domain.ErrInvalidPayload))
+				return
+			}
+			_ = r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			hash := requestHash(r, body)
+
+			existing, reserved, err := store.ReserveIdempotencyKey(r.Context(), user.ID, key, hash, idempotencyTTL)
+			if err != nil {
+				store.WriteError(w, err)
+				return
+			}
+
+			if !reserved {
+				switch {
+				case existing.RequestHash != hash:
+					store.WriteError(w, domain.MakeError(
+						lib.StandardError(op, errors.New("key reused with different payload")),
+						domain.ErrIdempotencyKeyReused))
+				case !existing.Completed:
+					store.WriteError(w, domain.MakeError(
+						lib.StandardError(op, errors.New("original request is still running")),
+						domain.ErrIdempotencyInProgress))
+				default:
+					replay(w, existing)
+				}
+				return
+			}
+
+			// контекст запроса к этому моменту может быть уже отменён
+			ctx := context.WithoutCancel(r.Context())
+
+			// паника обработчика не должна оставить ключ занятым до истечения TTL,
+			// дальше её обрабатывает middleware.Recoverer
+			defer func() {
+				if p := recover(); p != nil {
+					_ = store.ReleaseIdempotencyKey(ctx, user.ID, key)
+					panic(p)
+				}
+			}()
+
+			rec := &recorderRW{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+				_ = store.ReleaseIdempotencyKey(ctx, user.ID, key)
+				return
+			}
+
+			_ = store.CompleteIdempotencyKey(ctx, user.ID, key, models.IdempotentResponse{
+				RequestHash: hash,
+				StatusCode:  rec.status,
+				ContentType: w.Header().Get("Content-Type"),
+				Body:        rec.body.Bytes(),
+				Completed:   true,
+			})
+		})
+	}
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, resp models.IdempotentResponse) {
+	if resp.ContentType != "" {
+		w.Header().Set("Content-Type", resp.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(resp.Body)
+}
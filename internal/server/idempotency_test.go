@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"yandex-diplom/internal/auth"
+	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/models"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeIdempotencyStore struct {
+	mu   sync.Mutex
+	keys map[string]models.IdempotentResponse
+}
+
+func newFakeIdempotencyStore() *fakeIdempotencyStore {
+	return &fakeIdempotencyStore{keys: make(map[string]models.IdempotentResponse)}
+}
+
+func (s *fakeIdempotencyStore) ReserveIdempotencyKey(ctx context.Context, userID uint64, key string, requestHash string, ttl time.Duration) (models.IdempotentResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.keys[key]; ok {
+		return existing, false, nil
+	}
+	s.keys[key] = models.IdempotentResponse{RequestHash: requestHash}
+	return models.IdempotentResponse{}, true, nil
+}
+
+func (s *fakeIdempotencyStore) CompleteIdempotencyKey(ctx context.Context, userID uint64, key string, resp models.IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key] = resp
+	return nil
+}
+
+func (s *fakeIdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, userID uint64, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, key)
+	return nil
+}
+
+func (s *fakeIdempotencyStore) WriteError(w http.ResponseWriter, err error) {
+	logger, _ := zap.NewDevelopment()
+	gophermart.New(nil, logger, "test", nil).WriteError(w, err)
+}
+
+func doIdempotent(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(body))
+	req.Header.Set(idempotencyHeader, key)
+	req = req.WithContext(auth.WithUser(req.Context(), &models.User{ID: 1, Login: "user"}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotency_Replay(t *testing.T) {
+	calls := 0
+	h := Idempotency(newFakeIdempotencyStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+
+	first := doIdempotent(h, "key-1", `{"order":"2377225624","sum":751}`)
+	second := doIdempotent(h, "key-1", `{"order":"2377225624","sum":751}`)
+
+	require.Equal(t, 1, calls)
+	require.Equal(t, http.StatusOK, second.Code)
+	require.Equal(t, first.Body.String(), second.Body.String())
+	require.Equal(t, "application/json", second.Header().Get("Content-Type"))
+	require.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+}
+
+func TestIdempotency_DifferentPayload(t *testing.T) {
+	h := Idempotency(newFakeIdempotencyStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	require.Equal(t, http.StatusOK, doIdempotent(h, "key-1", `{"sum":1}`).Code)
+	require.Equal(t, http.StatusUnprocessableEntity, doIdempotent(h, "key-1", `{"sum":2}`).Code)
+}
+
+func TestIdempotency_ServerErrorReleasesKey(t *testing.T) {
+	calls := 0
+	h := Idempotency(newFakeIdempotencyStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	require.Equal(t, http.StatusInternalServerError, doIdempotent(h, "key-1", `{}`).Code)
+	require.Equal(t, http.StatusOK, doIdempotent(h, "key-1", `{}`).Code)
+	require.Equal(t, 2, calls)
+}
+
+func TestIdempotency_PanicReleasesKey(t *testing.T) {
+	calls := 0
+	h := Idempotency(newFakeIdempotencyStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	require.PanicsWithValue(t, "boom", func() { doIdempotent(h, "key-1", `{}`) })
+	require.Equal(t, http.StatusOK, doIdempotent(h, "key-1", `{}`).Code)
+	require.Equal(t, 2, calls)
+}
+
+func TestIdempotency_InProgress(t *testing.T) {
+	store := newFakeIdempotencyStore()
+	_, _, _ = store.ReserveIdempotencyKey(context.Background(), 1, "key-1", requestHash(
+		httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", nil), []byte(`{}`)), time.Hour)
+
+	h := Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("handler should not be called")
+	}))
+
+	rec := doIdempotent(h, "key-1", `{}`)
+	require.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestIdempotency_NoKey(t *testing.T) {
+	calls := 0
+	h := Idempotency(newFakeIdempotencyStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+
+	doIdempotent(h, "", `{}`)
+	doIdempotent(h, "", `{}`)
+	require.Equal(t, 2, calls)
+}
@@ -32,3 +32,14 @@ func (w *loggerRW) Write(b []byte) (int, error) {
 	w.responseData.size += size
 	return size, err
 }
+
+func (w *loggerRW) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *loggerRW) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// LoginPolicy ограничения попыток входа. Нулевые поля заменяются значениями по умолчанию.
+type LoginPolicy struct {
+	// FreeAttempts неудачных попыток на логин без задержки.
+	FreeAttempts int
+	// BaseDelay первая задержка, дальше удваивается с каждой неудачей.
+	BaseDelay time.Duration
+	// MaxAttempts неудачных попыток на логин до блокировки.
+	MaxAttempts int
+	// IPMaxAttempts неудачных попыток с одного адреса до блокировки.
+	IPMaxAttempts int
+	// Lockout длительность блокировки и окно, после которого неудачи забываются.
+	Lockout time.Duration
+}
+
+func (p LoginPolicy) withDefaults() LoginPolicy {
+	if p.FreeAttempts <= 0 {
+		p.FreeAttempts = 3
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = time.Second
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 10
+	}
+	if p.IPMaxAttempts <= 0 {
+		p.IPMaxAttempts = 100
+	}
+	if p.Lockout <= 0 {
+		p.Lockout = 15 * time.Minute
+	}
+	return p
+}
+
+type loginAttempts struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// LoginGuard считает попытки входа по логину и по адресу в памяти процесса.
+// Попытка засчитывается до проверки пароля, поэтому параллельный перебор
+// упирается в задержку так же, как последовательный; успешный вход её снимает.
+// У каждого экземпляра сервера свои счётчики.
+type LoginGuard struct {
+	policy LoginPolicy
+	now    func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*loginAttempts
+	lastSweep time.Time
+}
+
+func NewLoginGuard(policy LoginPolicy) *LoginGuard {
+	return &LoginGuard{
+		policy:  policy.withDefaults(),
+		now:     time.Now,
+		entries: make(map[string]*loginAttempts),
+	}
+}
+
+func loginKey(login string) string {
+	return "login:" + strings.ToLower(strings.TrimSpace(login))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Attempt засчитывает попытку входа. Если логин или адрес заблокированы,
+// попытка не засчитывается и возвращается, сколько ждать.
+func (g *LoginGuard) Attempt(login, ip string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	g.sweep(now)
+
+	byLogin, byIP := g.entry(loginKey(login), now), g.entry(ipKey(ip), now)
+	if wait := max(byLogin.blockedUntil.Sub(now), byIP.blockedUntil.Sub(now)); wait > 0 {
+		return wait
+	}
+
+	g.fail(byLogin, now, g.policy.FreeAttempts, g.policy.MaxAttempts)
+	// с одного адреса за NAT входит много людей, поэтому без прогрессивной задержки
+	g.fail(byIP, now, g.policy.IPMaxAttempts, g.policy.IPMaxAttempts)
+	return 0
+}
+
+// Done завершает попытку по статусу ответа: успешный вход обнуляет счётчик
+// логина, а ответы кроме 401 не считаются подбором пароля.
+func (g *LoginGuard) Done(login, ip string, status int) {
+	if status == http.StatusUnauthorized {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if status == http.StatusOK {
+		delete(g.entries, loginKey(login))
+	} else {
+		g.refund(loginKey(login))
+	}
+	g.refund(ipKey(ip))
+}
+
+// Unlock снимает блокировку и счётчик логина. Возвращает false, если счётчика не было.
+func (g *LoginGuard) Unlock(login string) bool {
+	return g.clear(loginKey(login))
+}
+
+// UnlockIP снимает блокировку и счётчик адреса. Возвращает false, если счётчика не было.
+func (g *LoginGuard) UnlockIP(ip string) bool {
+	return g.clear(ipKey(ip))
+}
+
+func (g *LoginGuard) clear(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	_, ok := g.entries[key]
+	delete(g.entries, key)
+	return ok
+}
+
+func (g *LoginGuard) entry(key string, now time.Time) *loginAttempts {
+	a, ok := g.entries[key]
+	if !ok || g.expired(a, now) {
+		a = &loginAttempts{}
+		g.entries[key] = a
+	}
+	return a
+}
+
+func (g *LoginGuard) expired(a *loginAttempts, now time.Time) bool {
+	return now.After(a.blockedUntil) && now.Sub(a.lastFailure) > g.policy.Lockout
+}
+
+func (g *LoginGuard) fail(a *loginAttempts, now time.Time, free, limit int) {
+	a.failures++
+	a.lastFailure = now
+
+	switch {
+	case a.failures >= limit:
+		a.blockedUntil = now.Add(g.policy.Lockout)
+	case a.failures > free:
+		delay := time.Duration(float64(g.policy.BaseDelay) * math.Pow(2, float64(a.failures-free-1)))
+		a.blockedUntil = now.Add(min(delay, g.policy.Lockout))
+	}
+}
+
+func (g *LoginGuard) refund(key string) {
+	if a, ok := g.entries[key]; ok && a.failures > 0 {
+		a.failures--
+	}
+}
+
+// sweep раз в минуту выбрасывает забытые счётчики, чтобы перебор по случайным
+// логинам не раздувал память.
+func (g *LoginGuard) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < time.Minute {
+		return
+	}
+	g.lastSweep = now
+
+	for key, a := range g.entries {
+		if g.expired(a, now) {
+			delete(g.entries, key)
+		}
+	}
+}
+
+// clientIP адрес после middleware.RealIP: там либо голый IP, либо host:port.
+// RealIP доверяет X-Forwarded-For и X-Real-IP, поэтому счётчик по адресу
+// работает только за прокси, который перезаписывает эти заголовки: клиент,
+// обращающийся к серверу напрямую, может подставлять любой адрес.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// LoginThrottle не пускает к проверке пароля заблокированные логины и адреса:
+// отвечает 429 с Retry-After. Должен стоять после middleware.RealIP.
+func LoginThrottle(guard *LoginGuard, logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, 64<<10))
+			if err != nil {
+				http.Error(w, "", http.StatusBadRequest)
+				return
+			}
+			_ = r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// разбор тела остаётся за обработчиком, здесь нужен только логин
+			var creds struct {
+				Login string `json:"login"`
+			}
+			_ = json.Unmarshal(body, &creds)
+
+			ip := clientIP(r)
+			if wait := guard.Attempt(creds.Login, ip); wait > 0 {
+				logger.Info("login throttled",
+					zap.String("login", creds.Login),
+					zap.String("ip", ip),
+					zap.Duration("wait", wait))
+
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "Too many login attempts", http.StatusTooManyRequests)
+				return
+			}
+
+			rec := &recorderRW{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			guard.Done(creds.Login, ip, status)
+		})
+	}
+}
+
+// UnlockLogin снимает блокировку входа с пользователя из пути.
+// Блокировку по адресу снимает UnlockAddress.
+func UnlockLogin(guard *LoginGuard) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !guard.Unlock(chi.URLParam(r, "login")) {
+			http.Error(w, "No failed logins for user", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// UnlockAddress снимает блокировку входа с адреса из пути.
+func UnlockAddress(guard *LoginGuard) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := net.ParseIP(chi.URLParam(r, "ip"))
+		if ip == nil {
+			http.Error(w, "Invalid address", http.StatusBadRequest)
+			return
+		}
+
+		if !guard.UnlockIP(ip.String()) {
+			http.Error(w, "No failed logins from address", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
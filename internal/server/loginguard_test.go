@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestGuard(policy LoginPolicy) (*LoginGuard, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := NewLoginGuard(policy)
+	g.now = func() time.Time { return now }
+	return g, &now
+}
+
+func TestLoginGuard_ProgressiveDelay(t *testing.T) {
+	g, now := newTestGuard(LoginPolicy{FreeAttempts: 2, BaseDelay: time.Second, MaxAttempts: 5, Lockout: time.Hour})
+
+	// две неудачи без задержки, третья включает задержку 1s
+	for range 3 {
+		require.Zero(t, g.Attempt("alice", "10.0.0.1"))
+		g.Done("alice", "10.0.0.1", http.StatusUnauthorized)
+	}
+	require.Equal(t, time.Second, g.Attempt("alice", "10.0.0.1"))
+	// логин в другом регистре тот же
+	require.Equal(t, time.Second, g.Attempt("ALICE", "10.0.0.2"))
+
+	*now = now.Add(time.Second)
+	require.Zero(t, g.Attempt("alice", "10.0.0.1"))
+	g.Done("alice", "10.0.0.1", http.StatusUnauthorized)
+	require.Equal(t, 2*time.Second, g.Attempt("alice", "10.0.0.1"))
+
+	// пятая неудача блокирует логин на Lockout
+	*now = now.Add(2 * time.Second)
+	require.Zero(t, g.Attempt("alice", "10.0.0.1"))
+	g.Done("alice", "10.0.0.1", http.StatusUnauthorized)
+	require.Equal(t, time.Hour, g.Attempt("alice", "10.0.0.1"))
+
+	// другие логины не затронуты
+	require.Zero(t, g.Attempt("bob", "10.0.0.3"))
+
+	require.True(t, g.Unlock("Alice"))
+	require.Zero(t, g.Attempt("alice", "10.0.0.1"))
+}
+
+func TestLoginGuard_SuccessResets(t *testing.T) {
+	g, _ := newTestGuard(LoginPolicy{FreeAttempts: 1, MaxAttempts: 5})
+
+	require.Zero(t, g.Attempt("alice", "10.0.0.1"))
+	g.Done("alice", "10.0.0.1", http.StatusUnauthorized)
+	require.Zero(t, g.Attempt("alice", "10.0.0.1"))
+	g.Done("alice", "10.0.0.1", http.StatusOK)
+
+	require.Zero(t, g.Attempt("alice", "10.0.0.1"))
+	g.Done("alice", "10.0.0.1", http.StatusUnauthorized)
+	require.Zero(t, g.Attempt("alice", "10.0.0.1"))
+}
+
+func TestLoginGuard_IPLockout(t *testing.T) {
+	g, now := newTestGuard(LoginPolicy{MaxAttempts: 10, IPMaxAttempts: 3, Lockout: time.Minute})
+
+	// перебор логинов с одного адреса
+	for _, login := range []string{"a1", "a2", "a3"} {
+		require.Zero(t, g.Attempt(login, "10.0.0.1"))
+		g.Done(login, "10.0.0.1", http.StatusUnauthorized)
+	}
+	require.Equal(t, time.Minute, g.Attempt("a4", "10.0.0.1"))
+	require.Zero(t, g.Attempt("a4", "10.0.0.2"))
+
+	// снятие блокировки с логина адрес не разблокирует
+	require.True(t, g.Unlock("a4"))
+	require.Equal(t, time.Minute, g.Attempt("a4", "10.0.0.1"))
+
+	require.True(t, g.UnlockIP("10.0.0.1"))
+	require.False(t, g.UnlockIP("10.0.0.9"))
+	require.Zero(t, g.Attempt("a5", "10.0.0.1"))
+	g.Done("a5", "10.0.0.1", http.StatusUnauthorized)
+
+	// блокировка и счётчики истекают
+	*now = now.Add(2 * time.Minute)
+	require.Zero(t, g.Attempt("a4", "10.0.0.1"))
+}
+
+func TestLoginThrottle(t *testing.T) {
+	g, _ := newTestGuard(LoginPolicy{FreeAttempts: 1, BaseDelay: 1500 * time.Millisecond, MaxAttempts: 5})
+
+	var gotLogin string
+	login := LoginThrottle(g, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var creds struct {
+			Login    string `json:"login"`
+			Password string `json:"password"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&creds))
+		gotLogin = creds.Login
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+
+	do := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(`{"login":"alice","password":"wrong"}`))
+		r.RemoteAddr = "10.0.0.1:5555"
+		w := httptest.NewRecorder()
+		login.ServeHTTP(w, r)
+		return w
+	}
+
+	require.Equal(t, http.StatusUnauthorized, do().Code)
+	require.Equal(t, "alice", gotLogin)
+	require.Equal(t, http.StatusUnauthorized, do().Code)
+
+	w := do()
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	r := chi.NewRouter()
+	r.Post("/users/{login}/unlock", UnlockLogin(g))
+	unlock := httptest.NewRecorder()
+	r.ServeHTTP(unlock, httptest.NewRequest(http.MethodPost, "/users/alice/unlock", nil))
+	require.Equal(t, http.StatusNoContent, unlock.Code)
+
+	require.Equal(t, http.StatusUnauthorized, do().Code)
+
+	unknown := httptest.NewRecorder()
+	r.ServeHTTP(unknown, httptest.NewRequest(http.MethodPost, "/users/nobody/unlock", nil))
+	require.Equal(t, http.StatusNotFound, unknown.Code)
+
+	r.Post("/addresses/{ip}/unlock", UnlockAddress(g))
+	for path, want := range map[string]int{
+		"/addresses/10.0.0.1/unlock":  http.StatusNoContent,
+		"/addresses/10.0.0.9/unlock":  http.StatusNotFound,
+		"/addresses/not-an-ip/unlock": http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		require.Equal(t, want, w.Code, path)
+	}
+}
@@ -2,11 +2,11 @@ package server
 
 import (
 	"net/http"
-	"os"
 	"time"
 	"yandex-diplom/internal/auth"
 	config "yandex-diplom/internal/config/gophermart"
 	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/models"
 	"yandex-diplom/internal/transport/httpx"
 
 	"github.com/go-chi/chi/v5"
@@ -32,7 +32,18 @@ func New(cfg config.Config, svc gophermart.Service) (*server, error) {
 	r.Use(middleware.Timeout(time.Second * 60))
 	r.Use(Logging(logger))
 
-	r.Mount("/api/user", userRoutes(svc))
+	r.Get("/.well-known/jwks.json", httpx.GetJWKS(svc))
+	guard := NewLoginGuard(LoginPolicy{
+		MaxAttempts:   cfg.LoginMaxAttempts,
+		IPMaxAttempts: cfg.LoginIPMaxAttempts,
+		Lockout:       cfg.LoginLockout,
+	})
+
+	r.Mount("/api/user", userRoutes(svc, guard))
+	r.Mount("/api/admin", adminRoutes(svc, guard))
+	if cfg.AccrualWebhookSecret != "" {
+		r.Mount("/api/internal", internalRoutes(svc, []byte(cfg.AccrualWebhookSecret)))
+	}
 
 	srv := &http.Server{
 		Addr:    cfg.Address.Host,
@@ -45,21 +56,34 @@ func New(cfg config.Config, svc gophermart.Service) (*server, error) {
 	}, nil
 }
 
-func userRoutes(svc gophermart.Service) chi.Router {
+func userRoutes(svc gophermart.Service, guard *LoginGuard) chi.Router {
 	r := chi.NewRouter()
 
 	r.Post("/register", httpx.RegisterUser(svc))
-	r.Post("/login", httpx.LoginUser(svc))
+	r.With(LoginThrottle(guard, svc.GetLogger())).Post("/login", httpx.LoginUser(svc))
+	r.Post("/token/refresh", httpx.RefreshToken(svc))
 
+	// открыты и для API-ключей, права проверяют обработчики
 	r.Group(func(r chi.Router) {
+		r.Use(auth.APIKeyMiddleware(svc))
 		r.Use(auth.Middleware(svc))
-		r.Post("/orders", httpx.CreateOrder(svc))
+		r.With(Idempotency(svc)).Post("/orders", httpx.CreateOrder(svc))
 		r.Get("/balance", httpx.GetBalance(svc))
-		r.Post("/balance/withdraw", httpx.CreateWithdraw(svc))
+		r.With(gzipCompession()).Get("/orders", httpx.GetOrders(svc))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(auth.Middleware(svc))
+		r.Post("/logout", httpx.Logout(svc))
+		r.With(Idempotency(svc)).Post("/balance/withdraw", httpx.CreateWithdraw(svc))
+		r.Post("/api-keys", httpx.CreateAPIKey(svc))
+		r.Get("/api-keys", httpx.GetAPIKeys(svc))
+		r.Delete("/api-keys/{id}", httpx.RevokeAPIKey(svc))
 		r.Group(func(r chi.Router) {
 			r.Use(gzipCompession())
 			r.Get("/withdrawals", httpx.GetWithdraws(svc))
-			r.Get("/orders", httpx.GetOrders(svc))
+			r.Get("/balance/history", httpx.GetBalanceHistory(svc))
+			r.Get("/statement", httpx.GetStatement(svc))
 		})
 
 	})
@@ -67,6 +91,39 @@ func userRoutes(svc gophermart.Service) chi.Router {
 	return r
 }
 
+func adminRoutes(svc gophermart.Service, guard *LoginGuard) chi.Router {
+	r := chi.NewRouter()
+
+	r.Use(auth.Middleware(svc))
+	r.Use(auth.RequireRole(models.RoleAdmin))
+
+	r.Get("/users/{login}", httpx.GetUserInfo(svc))
+	r.Get("/users/{login}/orders", httpx.GetUserOrders(svc))
+	r.Get("/users/{login}/ledger", httpx.GetUserLedger(svc))
+	r.Post("/users/{login}/adjustments", httpx.AdjustBalance(svc))
+	r.Post("/users/{login}/api-keys", httpx.IssueAPIKey(svc))
+	r.Post("/users/{login}/unlock", UnlockLogin(guard))
+	r.Post("/addresses/{ip}/unlock", UnlockAddress(guard))
+	r.Get("/orders/dead-letter", httpx.GetDeadLetterOrders(svc))
+	r.Post("/orders/{number}/reset", httpx.ResetOrder(svc))
+	r.Post("/orders/{number}/invalidate", httpx.InvalidateOrder(svc))
+	r.Post("/balance/entries/{id}/reversal", httpx.ReverseBalanceEntry(svc))
+	r.Get("/balance/reconcile", httpx.GetReconcileReport(svc))
+	r.Get("/accrual/stats", httpx.GetAccrualStats(svc))
+	r.Get("/workers/stats", httpx.GetWorkerPoolStats(svc))
+
+	return r
+}
+
+// internalRoutes принимают вызовы других систем, подписанные общим секретом.
+func internalRoutes(svc gophermart.Service, secret []byte) chi.Router {
+	r := chi.NewRouter()
+
+	r.With(Signed(secret)).Post("/accrual/callback", httpx.AccrualCallback(svc))
+
+	return r
+}
+
 func (s *server) logStartupInfo() {
 	s.logger.Info("Starting server",
 		zap.String("Address", s.Server.Addr),
@@ -76,11 +133,6 @@ func (s *server) logStartupInfo() {
 func (s *server) Start() *http.Server {
 	s.logStartupInfo()
 
-	_, exists := os.LookupEnv("SECRET")
-	if !exists {
-		s.logger.Info("Using default secret")
-	}
-
 	if err := s.Server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		s.logger.Fatal("Error occurred while running server", zap.Error(err))
 	}
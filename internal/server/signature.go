@@ -0,0 +1,32 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"yandex-diplom/internal/accrual"
+)
+
+const maxCallbackBody = 64 << 10
+
+// Signed пропускает только запросы, тело которых подписано secret по правилам accrual.Sign.
+func Signed(secret []byte) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCallbackBody))
+			if err != nil {
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+			_ = r.Body.Close()
+
+			if !accrual.VerifySignature(secret, body, r.Header.Get(accrual.SignatureHeader)) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"yandex-diplom/internal/accrual"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigned(t *testing.T) {
+	secret := []byte("secret")
+	body := `{"order":"12345678903","status":"PROCESSED","accrual":500}`
+
+	h := Signed(secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		require.Equal(t, body, string(got))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name      string
+		signature string
+		want      int
+	}{
+		{"valid", accrual.Sign(secret, []byte(body)), http.StatusOK},
+		{"wrong secret", accrual.Sign([]byte("other"), []byte(body)), http.StatusUnauthorized},
+		{"missing", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/accrual/callback", strings.NewReader(body))
+			if tt.signature != "" {
+				req.Header.Set(accrual.SignatureHeader, tt.signature)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			require.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+)
+
+type apiKey struct {
+	key  models.APIKey
+	hash string
+}
+
+func copyAPIKey(k models.APIKey) models.APIKey {
+	k.Scopes = append([]string(nil), k.Scopes...)
+	if k.LastUsedAt != nil {
+		at := *k.LastUsedAt
+		k.LastUsedAt = &at
+	}
+	if k.RevokedAt != nil {
+		at := *k.RevokedAt
+		k.RevokedAt = &at
+	}
+	return k
+}
+
+func (s *MemoryStorage) CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[key.UserID]; !ok {
+		return models.APIKey{}, domain.MakeError(fmt.Errorf("memory.CreateAPIKey user %d not found", key.UserID), domain.ErrUserNotFound)
+	}
+	if _, ok := s.apiKeyHashes[keyHash]; ok {
+		return models.APIKey{}, domain.MakeError(fmt.Errorf("memory.CreateAPIKey duplicate key"), domain.ErrInternal)
+	}
+
+	s.lastAPIKeyID++
+	key.ID = s.lastAPIKeyID
+	key.CreatedAt = s.timestamp()
+	key.LastUsedAt, key.RevokedAt = nil, nil
+
+	s.apiKeys[key.ID] = &apiKey{key: copyAPIKey(key), hash: keyHash}
+	s.apiKeyHashes[keyHash] = key.ID
+	return copyAPIKey(key), nil
+}
+
+func (s *MemoryStorage) UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.apiKeyHashes[keyHash]
+	if !ok || s.apiKeys[id].key.RevokedAt != nil {
+		return models.APIKey{}, domain.MakeError(fmt.Errorf("memory.UseAPIKey unknown or revoked key"), domain.ErrInvalidToken)
+	}
+
+	k := s.apiKeys[id]
+	now := s.timestamp()
+	k.key.LastUsedAt = &now
+	return copyAPIKey(k.key), nil
+}
+
+func (s *MemoryStorage) ListAPIKeys(ctx context.Context, userID uint64) ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]models.APIKey, 0)
+	for _, k := range s.apiKeys {
+		if k.key.UserID == userID {
+			keys = append(keys, copyAPIKey(k.key))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (s *MemoryStorage) RevokeAPIKey(ctx context.Context, userID uint64, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.apiKeys[id]
+	if !ok || k.key.UserID != userID {
+		return domain.MakeError(fmt.Errorf("memory.RevokeAPIKey key %d not found", id), domain.ErrAPIKeyNotFound)
+	}
+	if k.key.RevokedAt == nil {
+		now := s.timestamp()
+		k.key.RevokedAt = &now
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"time"
+	"yandex-diplom/internal/models"
+)
+
+// ReserveIdempotencyKey занимает ключ за пользователем. Если ключ уже занят,
+// возвращает сохранённую запись и false.
+func (s *MemoryStorage) ReserveIdempotencyKey(ctx context.Context, userID uint64, key string, requestHash string, ttl time.Duration) (models.IdempotentResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.timestamp()
+	for k, rec := range s.idempotency {
+		if rec.expiresAt.Before(now) {
+			delete(s.idempotency, k)
+		}
+	}
+
+	k := idempotencyKey{userID: userID, key: key}
+	if rec, ok := s.idempotency[k]; ok {
+		resp := rec.resp
+		resp.Body = append([]byte(nil), rec.resp.Body...)
+		return resp, false, nil
+	}
+
+	s.idempotency[k] = &idempotencyRecord{
+		resp:      models.IdempotentResponse{RequestHash: requestHash},
+		expiresAt: now.Add(ttl),
+	}
+	return models.IdempotentResponse{}, true, nil
+}
+
+func (s *MemoryStorage) CompleteIdempotencyKey(ctx context.Context, userID uint64, key string, resp models.IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.idempotency[idempotencyKey{userID: userID, key: key}]
+	if !ok {
+		return nil
+	}
+
+	rec.resp.StatusCode = resp.StatusCode
+	rec.resp.ContentType = resp.ContentType
+	rec.resp.Body = append([]byte(nil), resp.Body...)
+	rec.resp.Completed = true
+	return nil
+}
+
+func (s *MemoryStorage) ReleaseIdempotencyKey(ctx context.Context, userID uint64, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{userID: userID, key: key}
+	if rec, ok := s.idempotency[k]; ok && !rec.resp.Completed {
+		delete(s.idempotency, k)
+	}
+	return nil
+}
@@ -0,0 +1,374 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+)
+
+// signed знак влияния записи на баланс, как amount в balanceEntryColumns.
+func (e *entry) signed() money.Amount {
+	if e.typ == models.EntryWithdrawal {
+		return -e.amount
+	}
+	return e.amount
+}
+
+func (s *MemoryStorage) entryModel(e *entry) models.BalanceEntry {
+	postedAt := e.postedAt
+	m := models.BalanceEntry{
+		ID:         e.id,
+		UserID:     e.userID,
+		Type:       e.typ,
+		Amount:     e.signed(),
+		Reference:  e.ref,
+		Reason:     e.reason,
+		OperatorID: e.operatorID,
+		ReversesID: e.reverses,
+		PostedAt:   &postedAt,
+	}
+	if o, ok := s.orders[e.orderID]; ok {
+		m.Order = o.number
+	}
+	return m
+}
+
+// appendEntry добавляет запись в журнал. Вызывается под блокировкой.
+func (s *MemoryStorage) appendEntry(e *entry) *entry {
+	s.lastEntryID++
+	e.id = s.lastEntryID
+	e.postedAt = s.timestamp()
+	s.entries = append(s.entries, e)
+	if e.typ == models.EntryWithdrawal {
+		s.withdrawals[e.ref] = e.id
+	}
+	if e.reverses != 0 {
+		s.reversed[e.reverses] = e.id
+	}
+	return e
+}
+
+func (s *MemoryStorage) findEntry(id int64) (*entry, bool) {
+	// id выдаются по порядку и записи не удаляются
+	if id <= 0 || id > int64(len(s.entries)) {
+		return nil, false
+	}
+	return s.entries[id-1], true
+}
+
+func (s *MemoryStorage) hasAccrual(orderID int64) bool {
+	for _, e := range s.entries {
+		if e.orderID == orderID && e.typ == models.EntryAccrual {
+			return true
+		}
+	}
+	return false
+}
+
+// balance строка кэша баланса, создаётся при первом обращении. Вызывается под блокировкой.
+func (s *MemoryStorage) balance(userID uint64) *models.Balance {
+	b, ok := s.balances[userID]
+	if !ok {
+		b = &models.Balance{}
+		s.balances[userID] = b
+	}
+	return b
+}
+
+// ledgerTotals баланс и сумма списаний по журналу, как в UpdateBalance.
+func (s *MemoryStorage) ledgerTotals(userID uint64) models.Balance {
+	var b models.Balance
+	for _, e := range s.entries {
+		if e.userID != userID {
+			continue
+		}
+		b.Current += e.signed()
+		switch {
+		case e.typ == models.EntryWithdrawal:
+			b.Withdrawn += e.amount
+		case e.reverses != 0:
+			if orig, ok := s.findEntry(e.reverses); ok && orig.typ == models.EntryWithdrawal {
+				b.Withdrawn -= e.amount
+			}
+		}
+	}
+	return b
+}
+
+func (s *MemoryStorage) UpdateMissingBalanceEntries(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := make(map[uint64]struct{})
+	for _, o := range s.sortedOrders(func(o *order) bool { return o.status == models.OrderProcessed }, newestFirst) {
+		if s.hasAccrual(o.id) {
+			continue
+		}
+		s.appendEntry(&entry{userID: o.userID, typ: models.EntryAccrual, amount: o.accrual, orderID: o.id})
+		users[o.userID] = struct{}{}
+	}
+
+	for u := range users {
+		*s.balance(u) = s.ledgerTotals(u)
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) UpdateBalance(ctx context.Context, userID uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return domain.MakeError(fmt.Errorf("memory.UpdateBalance user %d not found", userID), domain.ErrUserNotFound)
+	}
+
+	*s.balance(userID) = s.ledgerTotals(userID)
+	return nil
+}
+
+func (s *MemoryStorage) GetBalance(ctx context.Context, userID uint64) (models.Balance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return *s.balance(userID), nil
+}
+
+func (s *MemoryStorage) UpdateWithdrawlEntries(ctx context.Context, userID uint64, withdraw models.Withdrawal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.balance(userID)
+	if b.Current < withdraw.Sum {
+		return domain.MakeError(fmt.Errorf("memory.UpdateWithdrawlEntries balance is lower than the amount indicated"), domain.ErrPaymentRequired)
+	}
+
+	if _, ok := s.withdrawals[withdraw.Order]; ok {
+		return domain.MakeError(fmt.Errorf("memory.UpdateWithdrawlEntries withdrawal %q already exists", withdraw.Order), domain.ErrOrderAlreadyExists)
+	}
+
+	s.appendEntry(&entry{userID: userID, typ: models.EntryWithdrawal, amount: withdraw.Sum, ref: withdraw.Order})
+	b.Current -= withdraw.Sum
+	b.Withdrawn += withdraw.Sum
+
+	return nil
+}
+
+func (s *MemoryStorage) GetWithdrawls(ctx context.Context, userID uint64) ([]models.Withdrawal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	withdrawals := make([]models.Withdrawal, 0)
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		e := s.entries[i]
+		if e.userID != userID || e.typ != models.EntryWithdrawal {
+			continue
+		}
+		processedAt := e.postedAt
+		withdrawals = append(withdrawals, models.Withdrawal{Order: e.ref, Sum: e.amount, ProcessedAt: &processedAt})
+	}
+
+	return withdrawals, nil
+}
+
+func (s *MemoryStorage) AdjustBalance(ctx context.Context, adj models.Adjustment) (models.BalanceEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[adj.UserID]; !ok {
+		return models.BalanceEntry{}, domain.MakeError(fmt.Errorf("memory.AdjustBalance user %d not found", adj.UserID), domain.ErrUserNotFound)
+	}
+
+	b := s.balance(adj.UserID)
+	if b.Current+adj.Amount < 0 {
+		return models.BalanceEntry{}, domain.MakeError(fmt.Errorf("memory.AdjustBalance balance is lower than the debit"), domain.ErrPaymentRequired)
+	}
+
+	e := s.appendEntry(&entry{userID: adj.UserID, typ: models.EntryAdjustment, amount: adj.Amount, reason: adj.Reason, operatorID: adj.OperatorID})
+	b.Current += adj.Amount
+
+	return s.entryModel(e), nil
+}
+
+func (s *MemoryStorage) ReverseBalanceEntry(ctx context.Context, rev models.Reversal) (models.BalanceEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orig, ok := s.findEntry(rev.EntryID)
+	if !ok {
+		return models.BalanceEntry{}, domain.MakeError(fmt.Errorf("memory.ReverseBalanceEntry entry %d not found", rev.EntryID), domain.ErrEntryNotFound)
+	}
+
+	if orig.reverses != 0 {
+		return models.BalanceEntry{}, domain.MakeError(fmt.Errorf("memory.ReverseBalanceEntry entry %d is a reversal itself", rev.EntryID), domain.ErrInvalidPayload)
+	}
+
+	if _, ok := s.reversed[rev.EntryID]; ok {
+		return models.BalanceEntry{}, domain.MakeError(fmt.Errorf("memory.ReverseBalanceEntry entry %d already reversed", rev.EntryID), domain.ErrEntryAlreadyReversed)
+	}
+
+	b := s.balance(orig.userID)
+	delta := -orig.signed()
+	if b.Current+delta < 0 {
+		return models.BalanceEntry{}, domain.MakeError(fmt.Errorf("memory.ReverseBalanceEntry balance is lower than the reversal"), domain.ErrPaymentRequired)
+	}
+
+	e := s.appendEntry(&entry{
+		userID:     orig.userID,
+		typ:        models.EntryAdjustment,
+		amount:     delta,
+		reason:     rev.Reason,
+		operatorID: rev.OperatorID,
+		reverses:   rev.EntryID,
+	})
+	b.Current += delta
+	if orig.typ == models.EntryWithdrawal {
+		b.Withdrawn -= delta
+	}
+
+	return s.entryModel(e), nil
+}
+
+func (s *MemoryStorage) GetBalanceEntries(ctx context.Context, userID uint64) ([]models.BalanceEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]models.BalanceEntry, 0)
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if s.entries[i].userID == userID {
+			entries = append(entries, s.entryModel(s.entries[i]))
+		}
+	}
+
+	return entries, nil
+}
+
+func (s *MemoryStorage) GetLedgerPage(ctx context.Context, userID uint64, filter models.LedgerFilter) (models.LedgerPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// накопительный итог считается по всему журналу, а фильтры применяются после
+	var (
+		running money.Amount
+		ledger  []models.LedgerEntry
+	)
+	for _, e := range s.entries {
+		if e.userID != userID {
+			continue
+		}
+		running += e.signed()
+		ledger = append(ledger, models.LedgerEntry{BalanceEntry: s.entryModel(e), RunningBalance: running})
+	}
+
+	page := models.LedgerPage{Entries: make([]models.LedgerEntry, 0, filter.Limit)}
+	for i := len(ledger) - 1; i >= 0; i-- {
+		e := ledger[i]
+		at := *e.PostedAt
+		if filter.From != nil && at.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !at.Before(*filter.To) {
+			continue
+		}
+		if filter.After != nil && !before(at, e.ID, *filter.After) {
+			continue
+		}
+
+		if len(page.Entries) == filter.Limit {
+			last := page.Entries[len(page.Entries)-1]
+			page.Next = &models.Cursor{At: *last.PostedAt, ID: last.ID}
+			break
+		}
+		page.Entries = append(page.Entries, e)
+	}
+
+	return page, nil
+}
+
+// StreamBalanceEntries отдаёт баланс на начало from и записи журнала за [from, to).
+// Всё читается под одной блокировкой, а колбэки вызываются уже без неё,
+// поэтому могут обращаться к хранилищу.
+func (s *MemoryStorage) StreamBalanceEntries(ctx context.Context, userID uint64, from, to time.Time, opening func(money.Amount) error, fn func(models.BalanceEntry) error) error {
+	s.mu.RLock()
+	var balance money.Amount
+	var entries []models.BalanceEntry
+	for _, e := range s.entries {
+		if e.userID != userID || !e.postedAt.Before(to) {
+			continue
+		}
+		if e.postedAt.Before(from) {
+			balance += e.signed()
+			continue
+		}
+		entries = append(entries, s.entryModel(e))
+	}
+	s.mu.RUnlock()
+
+	if err := opening(balance); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return domain.MakeError(fmt.Errorf("memory.StreamBalanceEntries: %w", err), domain.ErrServiceUnavailable)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) CheckBalances(ctx context.Context, afterUserID uint64, limit int) ([]models.BalanceCheck, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	checks := make([]models.BalanceCheck, 0, limit)
+	for id := afterUserID + 1; id <= s.lastUserID && len(checks) < limit; id++ {
+		if _, ok := s.users[id]; !ok {
+			continue
+		}
+
+		var cached models.Balance
+		if b, ok := s.balances[id]; ok {
+			cached = *b
+		}
+		ledger := s.ledgerTotals(id)
+
+		checks = append(checks, models.BalanceCheck{
+			UserID:          id,
+			CachedBalance:   cached.Current,
+			LedgerBalance:   ledger.Current,
+			CachedWithdrawn: cached.Withdrawn,
+			LedgerWithdrawn: ledger.Withdrawn,
+		})
+	}
+
+	return checks, nil
+}
+
+func (s *MemoryStorage) SaveReconcileReport(ctx context.Context, report models.ReconcileReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report.Samples = append([]models.BalanceCheck(nil), report.Samples...)
+	s.reconcile = &report
+	return nil
+}
+
+func (s *MemoryStorage) GetReconcileReport(ctx context.Context) (models.ReconcileReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.reconcile == nil {
+		return models.ReconcileReport{}, domain.MakeError(fmt.Errorf("memory.GetReconcileReport no report yet"), domain.ErrNoContent)
+	}
+
+	report := *s.reconcile
+	report.Samples = append([]models.BalanceCheck(nil), s.reconcile.Samples...)
+	return report, nil
+}
@@ -0,0 +1,637 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// processingLease на сколько заказ закрепляется за воркером при выдаче, как в PostgresStorage.
+const processingLease = 5 * time.Minute
+
+type user struct {
+	id       uint64
+	login    string
+	password []byte
+	role     string
+}
+
+type order struct {
+	id                  int64
+	userID              uint64
+	number              string
+	status              string
+	accrual             money.Amount
+	createdAt           time.Time
+	processingStartedAt *time.Time
+	attempts            int
+	nextAttemptAt       *time.Time
+	lastError           string
+	deadLetteredAt      *time.Time
+}
+
+type entry struct {
+	id         int64
+	userID     uint64
+	typ        string
+	amount     money.Amount // как в базе: начисления и списания положительные, корректировки со знаком
+	orderID    int64
+	ref        string
+	reason     string
+	operatorID uint64
+	reverses   int64
+	postedAt   time.Time
+}
+
+type idempotencyKey struct {
+	userID uint64
+	key    string
+}
+
+type idempotencyRecord struct {
+	resp      models.IdempotentResponse
+	expiresAt time.Time
+}
+
+// MemoryStorage реализация gophermart.Reposiroty в памяти процесса с той же
+// семантикой, что и PostgresStorage. Подходит для демо и тестов, данные не сохраняются.
+type MemoryStorage struct {
+	mu  sync.RWMutex
+	now func() time.Time
+
+	users  map[uint64]*user
+	logins map[string]uint64
+
+	orders  map[int64]*order
+	numbers map[string]int64
+
+	entries     []*entry
+	withdrawals map[string]int64
+	reversed    map[int64]int64
+	balances    map[uint64]*models.Balance
+
+	idempotency map[idempotencyKey]*idempotencyRecord
+
+	reconcile *models.ReconcileReport
+
+	tasks    map[int64]*task
+	taskKeys map[string]int64
+
+	sessions      map[string]*session
+	refreshTokens map[string]*refreshToken
+
+	apiKeys      map[int64]*apiKey
+	apiKeyHashes map[string]int64
+
+	lastUserID  uint64
+	lastOrderID int64
+	lastEntryID int64
+	lastTaskID  int64
+
+	lastAPIKeyID int64
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		now:         time.Now,
+		users:       make(map[uint64]*user),
+		logins:      make(map[string]uint64),
+		orders:      make(map[int64]*order),
+		numbers:     make(map[string]int64),
+		withdrawals: make(map[string]int64),
+		reversed:    make(map[int64]int64),
+		balances:    make(map[uint64]*models.Balance),
+		idempotency: make(map[idempotencyKey]*idempotencyRecord),
+		tasks:       make(map[int64]*task),
+		taskKeys:    make(map[string]int64),
+
+		sessions:      make(map[string]*session),
+		refreshTokens: make(map[string]*refreshToken),
+
+		apiKeys:      make(map[int64]*apiKey),
+		apiKeyHashes: make(map[string]int64),
+	}
+}
+
+// timestamp текущее время с точностью timestamptz.
+func (s *MemoryStorage) timestamp() time.Time {
+	return s.now().UTC().Truncate(time.Microsecond)
+}
+
+// loginKey логины сравниваются без учёта регистра, как CITEXT.
+func loginKey(login string) string {
+	return strings.ToLower(login)
+}
+
+func (s *MemoryStorage) userByLogin(login string) (*user, bool) {
+	id, ok := s.logins[loginKey(login)]
+	if !ok {
+		return nil, false
+	}
+	return s.users[id], true
+}
+
+func (u *user) model() models.User {
+	return models.User{ID: u.id, Login: u.login, Role: u.role}
+}
+
+func (o *order) model() models.Order {
+	createdAt := o.createdAt
+	return models.Order{UserID: o.userID, Number: o.number, Status: o.status, Accrual: o.accrual, UploadedAt: &createdAt, Attempts: o.attempts}
+}
+
+func (s *MemoryStorage) CheckUser(ctx context.Context, login string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.userByLogin(login)
+	return ok, nil
+}
+
+func (s *MemoryStorage) GetUserByLogin(ctx context.Context, login string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.userByLogin(login)
+	if !ok {
+		return models.User{}, domain.MakeError(fmt.Errorf("memory.GetUserByLogin user %q not found", login), domain.ErrUserNotFound)
+	}
+	return u.model(), nil
+}
+
+func (s *MemoryStorage) GetUserByID(ctx context.Context, id int64) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[uint64(id)]
+	if !ok {
+		return models.User{}, domain.MakeError(fmt.Errorf("memory.GetUserByID user %d not found", id), domain.ErrUserNotFound)
+	}
+	return u.model(), nil
+}
+
+func (s *MemoryStorage) RegisterUser(ctx context.Context, u models.User) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return domain.MakeError(fmt.Errorf("memory.RegisterUser: %w", err), domain.ErrInvalidPayload)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.userByLogin(u.Login); ok {
+		return domain.MakeError(fmt.Errorf("memory.RegisterUser login %q is taken", u.Login), domain.ErrLoginAlreadyTaken)
+	}
+
+	s.lastUserID++
+	s.users[s.lastUserID] = &user{id: s.lastUserID, login: u.Login, password: hash, role: models.RoleUser}
+	s.logins[loginKey(u.Login)] = s.lastUserID
+
+	return nil
+}
+
+func (s *MemoryStorage) ValidateUser(ctx context.Context, u models.User) error {
+	s.mu.RLock()
+	stored, ok := s.userByLogin(strings.TrimSpace(u.Login))
+	s.mu.RUnlock()
+
+	if !ok || bcrypt.CompareHashAndPassword(stored.password, []byte(u.Password)) != nil {
+		return domain.MakeError(fmt.Errorf("memory.ValidateUser doesn't match"), domain.ErrInvalidCredentials)
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) CheckOrder(ctx context.Context, login string, o models.Order) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.checkOrder(login, o.Number)
+}
+
+func (s *MemoryStorage) checkOrder(login string, number string) error {
+	id, ok := s.numbers[number]
+	if !ok {
+		return nil
+	}
+
+	u, found := s.userByLogin(login)
+	if found && s.orders[id].userID == u.id {
+		return domain.MakeError(fmt.Errorf("memory.CheckOrder order already created by user"), domain.ErrOrderCreatedByUser)
+	}
+	return domain.MakeError(fmt.Errorf("memory.CheckOrder order already created by other user"), domain.ErrOrderCreatedByOtherUser)
+}
+
+func (s *MemoryStorage) CreateOrder(ctx context.Context, login string, o models.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.userByLogin(login)
+	if !ok {
+		return domain.MakeError(fmt.Errorf("memory.CreateOrder Check User"), domain.ErrUserNotFound)
+	}
+
+	// проверка повторяется под блокировкой, чтобы два параллельных запроса не создали один номер
+	if err := s.checkOrder(login, o.Number); err != nil {
+		return err
+	}
+
+	s.lastOrderID++
+	s.orders[s.lastOrderID] = &order{
+		id:        s.lastOrderID,
+		userID:    u.id,
+		number:    o.Number,
+		status:    models.OrderNew,
+		createdAt: s.timestamp(),
+	}
+	s.numbers[o.Number] = s.lastOrderID
+
+	return nil
+}
+
+// sortedOrders заказы, отсортированные по less. Вызывается под блокировкой.
+func (s *MemoryStorage) sortedOrders(keep func(*order) bool, less func(a, b *order) bool) []*order {
+	out := make([]*order, 0)
+	for _, o := range s.orders {
+		if keep(o) {
+			out = append(out, o)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return less(out[i], out[j]) })
+	return out
+}
+
+func newestFirst(a, b *order) bool {
+	if !a.createdAt.Equal(b.createdAt) {
+		return a.createdAt.After(b.createdAt)
+	}
+	return a.id > b.id
+}
+
+func (s *MemoryStorage) FetchNewOrders(ctx context.Context, limit int) ([]models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	candidates := s.sortedOrders(
+		func(o *order) bool { return o.status == models.OrderNew },
+		func(a, b *order) bool { return newestFirst(b, a) },
+	)
+
+	now := s.timestamp()
+	orders := make([]models.Order, 0, limit)
+	for _, o := range candidates {
+		if len(orders) == limit {
+			break
+		}
+		o.status = models.OrderProcessing
+		o.lease(now)
+		orders = append(orders, o.model())
+	}
+
+	return orders, nil
+}
+
+func (s *MemoryStorage) FetchProccesingOrders(ctx context.Context, limit int) ([]models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.timestamp()
+	candidates := s.sortedOrders(
+		func(o *order) bool {
+			return o.status == models.OrderProcessing && o.deadLetteredAt == nil && !o.dueAt(now).After(now)
+		},
+		func(a, b *order) bool {
+			da, db := a.nextAttemptAt, b.nextAttemptAt
+			if da == nil {
+				da = a.processingStartedAt
+			}
+			if db == nil {
+				db = b.processingStartedAt
+			}
+			switch {
+			case da == nil && db != nil:
+				return true
+			case da != nil && db == nil:
+				return false
+			case da != nil && !da.Equal(*db):
+				return da.Before(*db)
+			}
+			return a.createdAt.Before(b.createdAt)
+		},
+	)
+
+	orders := make([]models.Order, 0, limit)
+	for _, o := range candidates {
+		if len(orders) == limit {
+			break
+		}
+		o.lease(now)
+		orders = append(orders, o.model())
+	}
+
+	return orders, nil
+}
+
+// lease закрепляет заказ за воркером до now+processingLease.
+func (o *order) lease(now time.Time) {
+	started, next := now, now.Add(processingLease)
+	o.processingStartedAt = &started
+	o.nextAttemptAt = &next
+}
+
+// dueAt когда заказ снова можно отдать воркеру, как COALESCE в FetchProccesingOrders.
+func (o *order) dueAt(now time.Time) time.Time {
+	switch {
+	case o.nextAttemptAt != nil:
+		return *o.nextAttemptAt
+	case o.processingStartedAt != nil:
+		return o.processingStartedAt.Add(processingLease)
+	}
+	return now
+}
+
+func (s *MemoryStorage) FinalizeOrder(ctx context.Context, o models.Order, points money.Amount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.numbers[o.Number]
+	if !ok {
+		return nil
+	}
+	stored := s.orders[id]
+	if stored.userID != o.UserID || stored.status != models.OrderProcessing {
+		return nil
+	}
+
+	stored.status = models.OrderProcessed
+	stored.accrual = points
+	stored.deadLetteredAt = nil
+
+	if s.hasAccrual(id) {
+		return nil
+	}
+	s.appendEntry(&entry{userID: stored.userID, typ: models.EntryAccrual, amount: points, orderID: id})
+	s.balance(stored.userID).Current += points
+
+	return nil
+}
+
+// ClaimOrder переводит заказ NEW в обработку. Заказ уже в обработке возвращается как есть.
+func (s *MemoryStorage) ClaimOrder(ctx context.Context, number string) (models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.numbers[number]
+	if !ok {
+		return models.Order{}, domain.MakeError(fmt.Errorf("memory.ClaimOrder order %q not found", number), domain.ErrOrderNotFound)
+	}
+
+	o := s.orders[id]
+	switch o.status {
+	case models.OrderNew:
+		o.status = models.OrderProcessing
+		o.lease(s.timestamp())
+	case models.OrderProcessing:
+	default:
+		return models.Order{}, domain.MakeError(fmt.Errorf("memory.ClaimOrder order %q already %s", number, o.status), domain.ErrOrderFinalized)
+	}
+
+	return o.model(), nil
+}
+
+func (s *MemoryStorage) UpdateOrderInvalid(ctx context.Context, number string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.numbers[number]; ok && s.orders[id].status == models.OrderProcessing {
+		s.orders[id].status = models.OrderInvalid
+		s.orders[id].deadLetteredAt = nil
+	}
+	return nil
+}
+
+func (s *MemoryStorage) GetOrders(ctx context.Context, login string) ([]models.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	orders := make([]models.Order, 0)
+
+	u, ok := s.userByLogin(login)
+	if !ok {
+		return orders, nil
+	}
+
+	for _, o := range s.sortedOrders(func(o *order) bool { return o.userID == u.id }, newestFirst) {
+		m := o.model()
+		m.UserID = 0
+		orders = append(orders, m)
+	}
+
+	return orders, nil
+}
+
+func (s *MemoryStorage) GetOrdersPage(ctx context.Context, login string, filter models.OrderFilter) (models.OrderPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	page := models.OrderPage{Orders: make([]models.Order, 0, filter.Limit)}
+
+	u, ok := s.userByLogin(login)
+	if !ok {
+		return page, nil
+	}
+
+	keep := func(o *order) bool {
+		if o.userID != u.id {
+			return false
+		}
+		if len(filter.Statuses) > 0 && !contains(filter.Statuses, o.status) {
+			return false
+		}
+		if filter.From != nil && o.createdAt.Before(*filter.From) {
+			return false
+		}
+		if filter.To != nil && !o.createdAt.Before(*filter.To) {
+			return false
+		}
+		return filter.After == nil || before(o.createdAt, o.id, *filter.After)
+	}
+
+	var last *order
+	for _, o := range s.sortedOrders(keep, newestFirst) {
+		if len(page.Orders) == filter.Limit {
+			page.Next = &models.Cursor{At: last.createdAt, ID: last.id}
+			break
+		}
+		m := o.model()
+		m.UserID = 0
+		page.Orders = append(page.Orders, m)
+		last = o
+	}
+
+	return page, nil
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// before сравнивает пару (at, id) с курсором так же, как (posted_at, id) < ($1, $2) в SQL.
+func before(at time.Time, id int64, c models.Cursor) bool {
+	if !at.Equal(c.At) {
+		return at.Before(c.At)
+	}
+	return id < c.ID
+}
+
+func (s *MemoryStorage) SetUserRole(ctx context.Context, login string, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.userByLogin(login)
+	if !ok {
+		if role == models.RoleUser {
+			return nil
+		}
+		return domain.MakeError(fmt.Errorf("memory.SetUserRole user %q not found", login), domain.ErrUserNotFound)
+	}
+
+	u.role = role
+	return nil
+}
+
+// ResetOrder возвращает заказ в NEW, чтобы он снова ушёл в систему начислений.
+func (s *MemoryStorage) ResetOrder(ctx context.Context, number string) error {
+	return s.setOrderStatus("memory.ResetOrder", number, models.OrderNew)
+}
+
+// InvalidateOrder принудительно помечает заказ INVALID.
+func (s *MemoryStorage) InvalidateOrder(ctx context.Context, number string) error {
+	return s.setOrderStatus("memory.InvalidateOrder", number, models.OrderInvalid)
+}
+
+func (s *MemoryStorage) setOrderStatus(op string, number string, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.numbers[number]
+	if !ok {
+		return domain.MakeError(fmt.Errorf("%s order %q not found", op, number), domain.ErrOrderNotFound)
+	}
+
+	o := s.orders[id]
+	if o.status == models.OrderProcessed {
+		return domain.MakeError(fmt.Errorf("%s order %q already processed", op, number), domain.ErrOrderFinalized)
+	}
+
+	o.status = status
+	o.processingStartedAt = nil
+	o.attempts = 0
+	o.nextAttemptAt = nil
+	o.lastError = ""
+	o.deadLetteredAt = nil
+	return nil
+}
+
+// ScheduleOrderRetry откладывает следующий опрос заказа до next.
+func (s *MemoryStorage) ScheduleOrderRetry(ctx context.Context, number string, next time.Time, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if o, ok := s.retryable(number); ok {
+		next = next.UTC().Truncate(time.Microsecond)
+		o.attempts++
+		o.nextAttemptAt = &next
+		o.lastError = reason
+	}
+	return nil
+}
+
+// DeadLetterOrder снимает заказ с опроса. Заказ остаётся в PROCESSING до решения администратора.
+func (s *MemoryStorage) DeadLetterOrder(ctx context.Context, number string, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if o, ok := s.retryable(number); ok {
+		now := s.timestamp()
+		o.attempts++
+		o.nextAttemptAt = nil
+		o.lastError = reason
+		o.deadLetteredAt = &now
+	}
+	return nil
+}
+
+// ReleaseOrders возвращает в NEW заказы, взятые в обработку, но так и не опрошенные.
+func (s *MemoryStorage) ReleaseOrders(ctx context.Context, numbers []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, number := range numbers {
+		if o, ok := s.retryable(number); ok {
+			o.status = models.OrderNew
+			o.processingStartedAt = nil
+			o.nextAttemptAt = nil
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) retryable(number string) (*order, bool) {
+	id, ok := s.numbers[number]
+	if !ok {
+		return nil, false
+	}
+	o := s.orders[id]
+	return o, o.status == models.OrderProcessing && o.deadLetteredAt == nil
+}
+
+func (s *MemoryStorage) GetDeadLetterOrders(ctx context.Context, limit int) ([]models.DeadLetterOrder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dead := s.sortedOrders(
+		func(o *order) bool { return o.status == models.OrderProcessing && o.deadLetteredAt != nil },
+		func(a, b *order) bool {
+			if !a.deadLetteredAt.Equal(*b.deadLetteredAt) {
+				return a.deadLetteredAt.After(*b.deadLetteredAt)
+			}
+			return a.id > b.id
+		},
+	)
+
+	orders := make([]models.DeadLetterOrder, 0, min(limit, len(dead)))
+	for _, o := range dead {
+		if len(orders) == limit {
+			break
+		}
+		createdAt, deadAt := o.createdAt, *o.deadLetteredAt
+		orders = append(orders, models.DeadLetterOrder{
+			Number:         o.number,
+			UserID:         o.userID,
+			Login:          s.users[o.userID].login,
+			Attempts:       o.attempts,
+			LastError:      o.lastError,
+			UploadedAt:     &createdAt,
+			DeadLetteredAt: &deadAt,
+		})
+	}
+
+	return orders, nil
+}
+
+var _ gophermart.Reposiroty = (*MemoryStorage)(nil)
@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestUser(t *testing.T, s *MemoryStorage, login string) models.User {
+	t.Helper()
+	ctx := context.Background()
+
+	require.NoError(t, s.RegisterUser(ctx, models.User{Login: login, Password: "password"}))
+	user, err := s.GetUserByLogin(ctx, login)
+	require.NoError(t, err)
+	return user
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	s := NewMemoryStorage()
+	ctx := context.Background()
+
+	user := newTestUser(t, s, "alice")
+	require.NoError(t, s.CreateOrder(ctx, user.Login, models.Order{Number: "1"}))
+	require.NoError(t, s.CreateOrder(ctx, user.Login, models.Order{Number: "2"}))
+
+	orders, err := s.FetchNewOrders(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	require.Equal(t, "1", orders[0].Number)
+
+	again, err := s.FetchNewOrders(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, again)
+
+	// только что взятые заказы не отдаются повторно до истечения таймаута
+	processing, err := s.FetchProccesingOrders(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, processing)
+
+	s.now = func() time.Time { return time.Now().Add(processingLease + time.Minute) }
+	processing, err = s.FetchProccesingOrders(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, processing, 2)
+
+	require.NoError(t, s.FinalizeOrder(ctx, orders[0], money.FromInt(7)))
+	require.NoError(t, s.FinalizeOrder(ctx, orders[0], money.FromInt(7)))
+	require.NoError(t, s.UpdateOrderInvalid(ctx, "2"))
+
+	require.ErrorIs(t, s.ResetOrder(ctx, "1"), domain.ErrOrderFinalized)
+	require.NoError(t, s.ResetOrder(ctx, "2"))
+
+	list, err := s.GetOrders(ctx, user.Login)
+	require.NoError(t, err)
+	require.Equal(t, models.OrderNew, list[0].Status)
+	require.Equal(t, models.OrderProcessed, list[1].Status)
+
+	balance, err := s.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, money.FromInt(7), balance.Current)
+}
+
+func TestUpdateWithdrawlEntries_Concurrent(t *testing.T) {
+	s := NewMemoryStorage()
+	ctx := context.Background()
+
+	user := newTestUser(t, s, "alice")
+	_, err := s.AdjustBalance(ctx, models.Adjustment{UserID: user.ID, Amount: money.FromInt(55), Reason: "seed"})
+	require.NoError(t, err)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		ok      int
+		payment int
+	)
+	for i := range 20 {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := s.UpdateWithdrawlEntries(ctx, user.ID, models.Withdrawal{Order: fmt.Sprint(i), Sum: money.FromInt(10)})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				ok++
+			case errors.Is(err, domain.ErrPaymentRequired):
+				payment++
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Equal(t, 5, ok)
+	require.Equal(t, 15, payment)
+
+	checks, err := s.CheckBalances(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, checks, 1)
+	require.True(t, checks[0].Consistent())
+}
+
+func TestReverseBalanceEntry_Withdrawal(t *testing.T) {
+	s := NewMemoryStorage()
+	ctx := context.Background()
+
+	user := newTestUser(t, s, "alice")
+	_, err := s.AdjustBalance(ctx, models.Adjustment{UserID: user.ID, Amount: money.FromInt(20), Reason: "seed"})
+	require.NoError(t, err)
+	require.NoError(t, s.UpdateWithdrawlEntries(ctx, user.ID, models.Withdrawal{Order: "w1", Sum: money.FromInt(15)}))
+	require.ErrorIs(t, s.UpdateWithdrawlEntries(ctx, user.ID, models.Withdrawal{Order: "w1", Sum: money.FromInt(1)}), domain.ErrOrderAlreadyExists)
+
+	entry, err := s.ReverseBalanceEntry(ctx, models.Reversal{EntryID: 2, Reason: "cancelled"})
+	require.NoError(t, err)
+	require.Equal(t, money.FromInt(15), entry.Amount)
+
+	_, err = s.ReverseBalanceEntry(ctx, models.Reversal{EntryID: 2, Reason: "twice"})
+	require.ErrorIs(t, err, domain.ErrEntryAlreadyReversed)
+
+	balance, err := s.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, money.FromInt(20), balance.Current)
+	require.Equal(t, money.Amount(0), balance.Withdrawn)
+
+	require.NoError(t, s.UpdateBalance(ctx, user.ID))
+	recomputed, err := s.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, balance, recomputed)
+}
@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+)
+
+type task struct {
+	models.Task
+	availableAt time.Time
+	leasedUntil *time.Time
+}
+
+func (s *MemoryStorage) EnqueueTasks(ctx context.Context, tasks ...models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.insertTasks(s.timestamp(), tasks)
+	return nil
+}
+
+// EnqueueNewOrders переводит до limit заказов из NEW в PROCESSING и ставит на них
+// задания атомарно: задания собираются до изменения заказов, при ошибке task
+// заказы остаются в NEW.
+func (s *MemoryStorage) EnqueueNewOrders(ctx context.Context, limit int, newTask func(models.Order) (models.Task, error)) ([]models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	candidates := s.sortedOrders(
+		func(o *order) bool { return o.status == models.OrderNew },
+		func(a, b *order) bool { return newestFirst(b, a) },
+	)
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	orders := make([]models.Order, 0, len(candidates))
+	tasks := make([]models.Task, 0, len(candidates))
+	for _, o := range candidates {
+		m := o.model()
+		m.Status = models.OrderProcessing
+
+		t, err := newTask(m)
+		if err != nil {
+			return []models.Order{}, domain.MakeError(fmt.Errorf("memory.EnqueueNewOrders task for order %q: %w", o.number, err), domain.ErrInternal)
+		}
+		orders = append(orders, m)
+		tasks = append(tasks, t)
+	}
+
+	now := s.timestamp()
+	for _, o := range candidates {
+		o.status = models.OrderProcessing
+		o.lease(now)
+	}
+	s.insertTasks(now, tasks)
+
+	return orders, nil
+}
+
+// insertTasks вызывается под s.mu, задания с занятым ключом пропускаются.
+func (s *MemoryStorage) insertTasks(now time.Time, tasks []models.Task) {
+	for _, t := range tasks {
+		if t.Key != "" {
+			if _, ok := s.taskKeys[t.Key]; ok {
+				continue
+			}
+		}
+
+		s.lastTaskID++
+		stored := &task{Task: models.Task{ID: s.lastTaskID, Kind: t.Kind, Key: t.Key, Payload: append([]byte(nil), t.Payload...)}, availableAt: now}
+		s.tasks[stored.ID] = stored
+		if t.Key != "" {
+			s.taskKeys[t.Key] = stored.ID
+		}
+	}
+}
+
+func (s *MemoryStorage) LeaseTasks(ctx context.Context, kinds []string, limit int, visibility time.Duration) ([]models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.timestamp()
+	available := make([]*task, 0)
+	for _, t := range s.tasks {
+		if !contains(kinds, t.Kind) || t.availableAt.After(now) {
+			continue
+		}
+		if t.leasedUntil != nil && t.leasedUntil.After(now) {
+			continue
+		}
+		available = append(available, t)
+	}
+	sort.Slice(available, func(i, j int) bool {
+		if !available[i].availableAt.Equal(available[j].availableAt) {
+			return available[i].availableAt.Before(available[j].availableAt)
+		}
+		return available[i].ID < available[j].ID
+	})
+
+	leased := make([]models.Task, 0, min(limit, len(available)))
+	for _, t := range available {
+		if len(leased) == limit {
+			break
+		}
+		until := now.Add(visibility)
+		t.Attempts++
+		t.leasedUntil = &until
+
+		m := t.Task
+		m.Payload = append([]byte(nil), t.Payload...)
+		leased = append(leased, m)
+	}
+
+	return leased, nil
+}
+
+func (s *MemoryStorage) AckTask(ctx context.Context, t models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tasks[t.ID]
+	if !ok || stored.Attempts != t.Attempts {
+		return nil
+	}
+
+	delete(s.tasks, t.ID)
+	if stored.Key != "" {
+		delete(s.taskKeys, stored.Key)
+	}
+	return nil
+}
+
+func (s *MemoryStorage) NackTask(ctx context.Context, t models.Task, retryAt time.Time, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tasks[t.ID]
+	if !ok || stored.Attempts != t.Attempts {
+		return nil
+	}
+
+	stored.leasedUntil = nil
+	stored.availableAt = retryAt.UTC().Truncate(time.Microsecond)
+	stored.LastError = reason
+	return nil
+}
+
+func (s *MemoryStorage) CancelTasks(ctx context.Context, keys []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.timestamp()
+	cancelled := make([]string, 0, len(keys))
+	for _, key := range keys {
+		id, ok := s.taskKeys[key]
+		if !ok {
+			continue
+		}
+		if t := s.tasks[id]; t.leasedUntil != nil && t.leasedUntil.After(now) {
+			continue
+		}
+
+		delete(s.tasks, id)
+		delete(s.taskKeys, key)
+		cancelled = append(cancelled, key)
+	}
+
+	return cancelled, nil
+}
+
+func (s *MemoryStorage) CountTasks(ctx context.Context, kinds []string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := s.timestamp()
+	count := 0
+	for _, t := range s.tasks {
+		if contains(kinds, t.Kind) && !t.availableAt.After(now) && (t.leasedUntil == nil || !t.leasedUntil.After(now)) {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+)
+
+type session struct {
+	id        string
+	userID    uint64
+	createdAt time.Time
+	expiresAt time.Time
+	revokedAt *time.Time
+}
+
+type refreshToken struct {
+	sessionID string
+	usedAt    *time.Time
+}
+
+func (s *session) model() models.Session {
+	m := models.Session{ID: s.id, UserID: s.userID, CreatedAt: s.createdAt, ExpiresAt: s.expiresAt}
+	if s.revokedAt != nil {
+		at := *s.revokedAt
+		m.RevokedAt = &at
+	}
+	return m
+}
+
+func (s *MemoryStorage) CreateSession(ctx context.Context, sess models.Session, refreshHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[sess.UserID]; !ok {
+		return domain.MakeError(fmt.Errorf("memory.CreateSession user %d not found", sess.UserID), domain.ErrUserNotFound)
+	}
+	if _, ok := s.sessions[sess.ID]; ok {
+		return domain.MakeError(fmt.Errorf("memory.CreateSession session %s already exists", sess.ID), domain.ErrInternal)
+	}
+
+	s.sessions[sess.ID] = &session{
+		id:        sess.ID,
+		userID:    sess.UserID,
+		createdAt: s.timestamp(),
+		expiresAt: sess.ExpiresAt,
+	}
+	s.refreshTokens[refreshHash] = &refreshToken{sessionID: sess.ID}
+	return nil
+}
+
+// RotateRefreshToken повторяет семантику PostgresStorage.RotateRefreshToken.
+func (s *MemoryStorage) RotateRefreshToken(ctx context.Context, oldHash string, newHash string, expiresAt time.Time) (models.Session, error) {
+	const op = "memory.RotateRefreshToken"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.timestamp()
+	tok, ok := s.refreshTokens[oldHash]
+	if !ok {
+		return models.Session{}, domain.MakeError(fmt.Errorf("%s unknown refresh token", op), domain.ErrInvalidToken)
+	}
+	sess, ok := s.sessions[tok.sessionID]
+	if !ok || sess.revokedAt != nil || !sess.expiresAt.After(now) {
+		return models.Session{}, domain.MakeError(fmt.Errorf("%s session %s is closed", op, tok.sessionID), domain.ErrInvalidToken)
+	}
+
+	if tok.usedAt != nil {
+		sess.revokedAt = &now
+		return sess.model(), domain.MakeError(fmt.Errorf("%s refresh token reused, session %s revoked", op, sess.id), domain.ErrInvalidToken)
+	}
+
+	tok.usedAt = &now
+	s.refreshTokens[newHash] = &refreshToken{sessionID: sess.id}
+	sess.expiresAt = expiresAt
+	return sess.model(), nil
+}
+
+func (s *MemoryStorage) RevokeSession(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sess, ok := s.sessions[sessionID]; ok && sess.revokedAt == nil {
+		now := s.timestamp()
+		sess.revokedAt = &now
+	}
+	return nil
+}
+
+func (s *MemoryStorage) ListRevokedSessions(ctx context.Context, since time.Time) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0)
+	for id, sess := range s.sessions {
+		if sess.revokedAt != nil && !sess.revokedAt.Before(since) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
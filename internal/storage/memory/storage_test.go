@@ -0,0 +1,20 @@
+package memory
+
+import (
+	"testing"
+	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/job"
+	"yandex-diplom/internal/storage/storagetest"
+)
+
+func TestRepositoryContract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) gophermart.Reposiroty {
+		return NewMemoryStorage()
+	})
+}
+
+func TestQueueContract(t *testing.T) {
+	storagetest.RunQueue(t, func(t *testing.T) job.Queue {
+		return NewMemoryStorage()
+	})
+}
@@ -0,0 +1,218 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/lib"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+
+	"github.com/jackc/pgerrcode"
+)
+
+// balanceEntryColumns колонки записи журнала, amount приводится к знаку влияния на баланс.
+const balanceEntryColumns = `
+	e.id, e.user_id, e.entry_type,
+	CASE WHEN e.entry_type = 'withdrawal' THEN -e.amount_points ELSE e.amount_points END AS amount,
+	COALESCE(uo.order_number, '') AS order_number,
+	COALESCE(e.withdrawal_ref, '') AS reference,
+	COALESCE(e.reason, '') AS reason,
+	COALESCE(e.operator_id, 0) AS operator_id,
+	COALESCE(e.reverses_entry_id, 0) AS reverses_entry_id,
+	e.posted_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanBalanceEntry(row rowScanner) (models.BalanceEntry, error) {
+	var e models.BalanceEntry
+	err := row.Scan(&e.ID, &e.UserID, &e.Type, &e.Amount, &e.Order, &e.Reference, &e.Reason, &e.OperatorID, &e.ReversesID, &e.PostedAt)
+	return e, err
+}
+
+// lockBalance создаёт строку баланса при необходимости и блокирует её до конца транзакции.
+func lockBalance(ctx context.Context, tx *sql.Tx, userID uint64) (money.Amount, error) {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO user_point_balances (user_id, balance, withdrawal)
+		VALUES ($1, 0, 0)
+		ON CONFLICT (user_id) DO NOTHING;
+		`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	var current money.Amount
+	err = tx.QueryRowContext(ctx, `
+		SELECT balance
+		FROM user_point_balances
+		WHERE user_id = $1
+		FOR UPDATE;
+		`, userID).Scan(&current)
+	if err != nil {
+		return 0, err
+	}
+
+	return current, nil
+}
+
+func (s *PostgresStorage) AdjustBalance(ctx context.Context, adj models.Adjustment) (models.BalanceEntry, error) {
+	var entry models.BalanceEntry
+
+	err := retryWrapper(ctx, func() error {
+		tx, err := s.Database.BeginTx(ctx, &sql.TxOptions{
+			Isolation: sql.LevelReadCommitted,
+		})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		current, err := lockBalance(ctx, tx, adj.UserID)
+		if err != nil {
+			return err
+		}
+
+		if current+adj.Amount < 0 {
+			return domain.MakeError(fmt.Errorf("postgresql.AdjustBalance balance is lower than the debit"), domain.ErrPaymentRequired)
+		}
+
+		var id int64
+		err = tx.QueryRowContext(ctx, `
+		INSERT INTO user_balance_entries (user_id, entry_type, amount_points, reason, operator_id)
+		VALUES ($1, 'adjustment', $2, $3, NULLIF($4, 0))
+		RETURNING id;
+		`, adj.UserID, adj.Amount, adj.Reason, int64(adj.OperatorID)).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		UPDATE user_point_balances
+		SET balance    = balance + $2,
+			updated_at = now()
+		WHERE user_id = $1;
+		`, adj.UserID, adj.Amount)
+		if err != nil {
+			return err
+		}
+
+		entry, err = getBalanceEntry(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return models.BalanceEntry{}, translateTx("postgresql.AdjustBalance", err)
+	}
+
+	return entry, nil
+}
+
+func (s *PostgresStorage) ReverseBalanceEntry(ctx context.Context, rev models.Reversal) (models.BalanceEntry, error) {
+	var entry models.BalanceEntry
+
+	err := retryWrapper(ctx, func() error {
+		tx, err := s.Database.BeginTx(ctx, &sql.TxOptions{
+			Isolation: sql.LevelReadCommitted,
+		})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		orig, err := scanBalanceEntry(tx.QueryRowContext(ctx, `
+		SELECT `+balanceEntryColumns+`
+		FROM user_balance_entries e
+		LEFT JOIN user_orders uo ON uo.id = e.order_id
+		WHERE e.id = $1
+		FOR UPDATE OF e;
+		`, rev.EntryID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.MakeError(fmt.Errorf("postgresql.ReverseBalanceEntry entry %d not found", rev.EntryID), domain.ErrEntryNotFound)
+		}
+		if err != nil {
+			return err
+		}
+
+		if orig.ReversesID != 0 {
+			return domain.MakeError(fmt.Errorf("postgresql.ReverseBalanceEntry entry %d is a reversal itself", rev.EntryID), domain.ErrInvalidPayload)
+		}
+
+		var reversed bool
+		err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM user_balance_entries WHERE reverses_entry_id = $1);
+		`, rev.EntryID).Scan(&reversed)
+		if err != nil {
+			return err
+		}
+		if reversed {
+			return domain.MakeError(fmt.Errorf("postgresql.ReverseBalanceEntry entry %d already reversed", rev.EntryID), domain.ErrEntryAlreadyReversed)
+		}
+
+		current, err := lockBalance(ctx, tx, orig.UserID)
+		if err != nil {
+			return err
+		}
+
+		delta := -orig.Amount
+		if current+delta < 0 {
+			return domain.MakeError(fmt.Errorf("postgresql.ReverseBalanceEntry balance is lower than the reversal"), domain.ErrPaymentRequired)
+		}
+
+		var withdrawn money.Amount
+		if orig.Type == models.EntryWithdrawal {
+			withdrawn = delta
+		}
+
+		var id int64
+		err = tx.QueryRowContext(ctx, `
+		INSERT INTO user_balance_entries (user_id, entry_type, amount_points, reason, operator_id, reverses_entry_id)
+		VALUES ($1, 'adjustment', $2, $3, NULLIF($4, 0), $5)
+		RETURNING id;
+		`, orig.UserID, delta, rev.Reason, int64(rev.OperatorID), rev.EntryID).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		UPDATE user_point_balances
+		SET balance    = balance + $2,
+			withdrawal = withdrawal - $3,
+			updated_at = now()
+		WHERE user_id = $1;
+		`, orig.UserID, delta, withdrawn)
+		if err != nil {
+			return err
+		}
+
+		entry, err = getBalanceEntry(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		if code, ok := sqlState(err); ok && code == pgerrcode.UniqueViolation {
+			return models.BalanceEntry{}, domain.MakeError(lib.StandardError("postgresql.ReverseBalanceEntry", err), domain.ErrEntryAlreadyReversed)
+		}
+		return models.BalanceEntry{}, translateTx("postgresql.ReverseBalanceEntry", err)
+	}
+
+	return entry, nil
+}
+
+func getBalanceEntry(ctx context.Context, tx *sql.Tx, id int64) (models.BalanceEntry, error) {
+	return scanBalanceEntry(tx.QueryRowContext(ctx, `
+		SELECT `+balanceEntryColumns+`
+		FROM user_balance_entries e
+		LEFT JOIN user_orders uo ON uo.id = e.order_id
+		WHERE e.id = $1;
+		`, id))
+}
@@ -0,0 +1,140 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+)
+
+func (s *PostgresStorage) SetUserRole(ctx context.Context, login string, role string) error {
+	err := retryWrapper(ctx, func() error {
+		if role == models.RoleUser {
+			_, err := s.Database.ExecContext(ctx, `
+			DELETE FROM user_roles r
+			USING users u
+			WHERE r.user_id = u.id AND u.login_name = $1;
+			`, login)
+			return err
+		}
+
+		result, err := s.Database.ExecContext(ctx, `
+		INSERT INTO user_roles (user_id, role)
+		SELECT id, $2 FROM users WHERE login_name = $1
+		ON CONFLICT (user_id) DO UPDATE
+		SET role       = EXCLUDED.role,
+			granted_at = now();
+		`, login, role)
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return domain.MakeError(fmt.Errorf("postgresql.SetUserRole Check Rows"), domain.ErrInternal)
+		}
+		if affected == 0 {
+			return domain.MakeError(fmt.Errorf("postgresql.SetUserRole user %q not found", login), domain.ErrUserNotFound)
+		}
+		return nil
+	})
+	if err != nil {
+		return translateTx("postgresql.SetUserRole", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetBalanceEntries(ctx context.Context, userID uint64) ([]models.BalanceEntry, error) {
+	entries := make([]models.BalanceEntry, 0)
+
+	err := retryWrapper(ctx, func() error {
+		entries = entries[:0]
+
+		rows, err := s.Database.QueryContext(ctx, `
+			SELECT `+balanceEntryColumns+`
+			FROM user_balance_entries e
+			LEFT JOIN user_orders uo ON uo.id = e.order_id
+			WHERE e.user_id = $1
+			ORDER BY e.posted_at DESC, e.id DESC`,
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			e, err := scanBalanceEntry(rows)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, e)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return []models.BalanceEntry{}, translate("postgresql.GetBalanceEntries", err)
+	}
+
+	return entries, nil
+}
+
+// ResetOrder возвращает заказ в NEW, чтобы он снова ушёл в систему начислений.
+func (s *PostgresStorage) ResetOrder(ctx context.Context, orderNumber string) error {
+	return s.setOrderStatus(ctx, "postgresql.ResetOrder", orderNumber, "NEW")
+}
+
+// InvalidateOrder принудительно помечает заказ INVALID.
+func (s *PostgresStorage) InvalidateOrder(ctx context.Context, orderNumber string) error {
+	return s.setOrderStatus(ctx, "postgresql.InvalidateOrder", orderNumber, "INVALID")
+}
+
+func (s *PostgresStorage) setOrderStatus(ctx context.Context, op string, orderNumber string, status string) error {
+	err := retryWrapper(ctx, func() error {
+		tx, err := s.Database.BeginTx(ctx, &sql.TxOptions{
+			Isolation: sql.LevelReadCommitted,
+		})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		var current string
+		err = tx.QueryRowContext(ctx, `
+		SELECT status FROM user_orders WHERE order_number = $1 FOR UPDATE;
+		`, orderNumber).Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.MakeError(fmt.Errorf("%s order %q not found", op, orderNumber), domain.ErrOrderNotFound)
+		}
+		if err != nil {
+			return err
+		}
+
+		if current == "PROCESSED" {
+			return domain.MakeError(fmt.Errorf("%s order %q already processed", op, orderNumber), domain.ErrOrderFinalized)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		UPDATE user_orders
+		SET status = $2,
+			processing_started_at = NULL,
+			attempts = 0,
+			next_attempt_at = NULL,
+			last_error = NULL,
+			dead_lettered_at = NULL
+		WHERE order_number = $1;
+		`, orderNumber, status)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return translateTx(op, err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+
+	"github.com/lib/pq"
+)
+
+func (s *PostgresStorage) CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (models.APIKey, error) {
+	err := retryWrapper(ctx, func() error {
+		return s.Database.QueryRowContext(ctx, `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, created_by)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0))
+		RETURNING id, created_at;
+		`, key.UserID, key.Name, key.Prefix, keyHash, pq.Array(key.Scopes), key.CreatedBy).Scan(&key.ID, &key.CreatedAt)
+	})
+	if err != nil {
+		return models.APIKey{}, translate("postgresql.CreateAPIKey", err)
+	}
+
+	return key, nil
+}
+
+// UseAPIKey находит действующий ключ по хэшу и отмечает время использования.
+func (s *PostgresStorage) UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	const op = "postgresql.UseAPIKey"
+	var key models.APIKey
+
+	err := retryWrapper(ctx, func() error {
+		key = models.APIKey{}
+
+		var createdBy sql.NullInt64
+		err := s.Database.QueryRowContext(ctx, `
+		UPDATE api_keys SET last_used_at = now()
+		WHERE key_hash = $1 AND revoked_at IS NULL
+		RETURNING id, user_id, name, prefix, scopes, created_by, created_at, last_used_at;
+		`, keyHash).Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &createdBy, &key.CreatedAt, &key.LastUsedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.MakeError(fmt.Errorf("%s unknown or revoked key", op), domain.ErrInvalidToken)
+		}
+		key.CreatedBy = uint64(createdBy.Int64)
+		return err
+	})
+	if err != nil {
+		return models.APIKey{}, translateTx(op, err)
+	}
+
+	return key, nil
+}
+
+func (s *PostgresStorage) ListAPIKeys(ctx context.Context, userID uint64) ([]models.APIKey, error) {
+	keys := make([]models.APIKey, 0)
+
+	err := retryWrapper(ctx, func() error {
+		keys = keys[:0]
+
+		rows, err := s.Database.QueryContext(ctx, `
+		SELECT id, user_id, name, prefix, scopes, created_by, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY id;
+		`, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				k         models.APIKey
+				createdBy sql.NullInt64
+			)
+			if err := rows.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, pq.Array(&k.Scopes), &createdBy, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt); err != nil {
+				return err
+			}
+			k.CreatedBy = uint64(createdBy.Int64)
+			keys = append(keys, k)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return []models.APIKey{}, translate("postgresql.ListAPIKeys", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey отзывает ключ пользователя. Повторный отзыв ничего не меняет,
+// чужой или несуществующий ключ — ErrAPIKeyNotFound.
+func (s *PostgresStorage) RevokeAPIKey(ctx context.Context, userID uint64, id int64) error {
+	const op = "postgresql.RevokeAPIKey"
+
+	err := retryWrapper(ctx, func() error {
+		var found bool
+		err := s.Database.QueryRowContext(ctx, `
+		WITH upd AS (
+			UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now())
+			WHERE id = $1 AND user_id = $2
+			RETURNING id
+		)
+		SELECT EXISTS (SELECT 1 FROM upd);
+		`, id, userID).Scan(&found)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return domain.MakeError(fmt.Errorf("%s key %d not found", op, id), domain.ErrAPIKeyNotFound)
+		}
+		return nil
+	})
+	if err != nil {
+		return translateTx(op, err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/lib"
+	"yandex-diplom/internal/models"
+)
+
+// idempotencyPurgeBatch сколько истёкших ключей удаляется за одно резервирование.
+const idempotencyPurgeBatch = 100
+
+// ReserveIdempotencyKey занимает ключ за пользователем. Если ключ уже занят,
+// возвращает сохранённую запись и false.
+func (s *PostgresStorage) ReserveIdempotencyKey(ctx context.Context, userID uint64, key string, requestHash string, ttl time.Duration) (models.IdempotentResponse, bool, error) {
+	var (
+		existing models.IdempotentResponse
+		reserved bool
+	)
+
+	err := retryWrapper(ctx, func() error {
+		existing = models.IdempotentResponse{}
+		reserved = false
+
+		tx, err := s.Database.BeginTx(ctx, &sql.TxOptions{
+			Isolation: sql.LevelReadCommitted,
+		})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		// истёкший ключ удаляется сразу, чтобы его можно было занять заново,
+		// остальные истёкшие записи чистятся порциями по индексу expires_at
+		_, err = tx.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2 AND expires_at < now();
+		`, userID, key)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE ctid IN (
+			SELECT ctid
+			FROM idempotency_keys
+			WHERE expires_at < now()
+			ORDER BY expires_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		);
+		`, idempotencyPurgeBatch)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash, expires_at)
+		VALUES ($1, $2, $3, now() + make_interval(secs => $4))
+		ON CONFLICT (user_id, idempotency_key) DO NOTHING;
+		`, userID, key, requestHash, ttl.Seconds())
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 1 {
+			reserved = true
+			return tx.Commit()
+		}
+
+		var status sql.NullInt64
+		err = tx.QueryRowContext(ctx, `
+		SELECT request_hash, status_code, content_type, response_body
+		FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2;
+		`, userID, key).Scan(&existing.RequestHash, &status, &existing.ContentType, &existing.Body)
+		if err != nil {
+			return err
+		}
+		existing.StatusCode = int(status.Int64)
+		existing.Completed = status.Valid
+
+		return tx.Commit()
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// запись истекла и удалена параллельным запросом, клиент может повторить
+			return models.IdempotentResponse{}, false, domain.MakeError(lib.StandardError("postgresql.ReserveIdempotencyKey", err), domain.ErrIdempotencyInProgress)
+		}
+		return models.IdempotentResponse{}, false, translate("postgresql.ReserveIdempotencyKey", err)
+	}
+
+	return existing, reserved, nil
+}
+
+func (s *PostgresStorage) CompleteIdempotencyKey(ctx context.Context, userID uint64, key string, resp models.IdempotentResponse) error {
+	err := retryWrapper(ctx, func() error {
+		_, err := s.Database.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET status_code   = $3,
+			content_type  = $4,
+			response_body = $5
+		WHERE user_id = $1 AND idempotency_key = $2;
+		`, userID, key, resp.StatusCode, resp.ContentType, resp.Body)
+		return err
+	})
+	if err != nil {
+		return translate("postgresql.CompleteIdempotencyKey", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) ReleaseIdempotencyKey(ctx context.Context, userID uint64, key string) error {
+	err := retryWrapper(ctx, func() error {
+		_, err := s.Database.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2 AND status_code IS NULL;
+		`, userID, key)
+		return err
+	})
+	if err != nil {
+		return translate("postgresql.ReleaseIdempotencyKey", err)
+	}
+	return nil
+}
@@ -3,9 +3,14 @@ package postgresql
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/lib"
 	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+
+	"github.com/jackc/pgerrcode"
 )
 
 func (s *PostgresStorage) CheckUser(ctx context.Context, login string) (bool, error) {
@@ -36,11 +41,17 @@ func (s *PostgresStorage) GetUserByLogin(ctx context.Context, login string) (mod
 
 	err := retryWrapper(ctx, func() error {
 		return s.Database.QueryRowContext(ctx,
-			`SELECT id, login_name FROM users WHERE login_name = $1`,
+			`SELECT u.id, u.login_name, COALESCE(r.role, 'user')
+			 FROM users u
+			 LEFT JOIN user_roles r ON r.user_id = u.id
+			 WHERE u.login_name = $1`,
 			login,
-		).Scan(&user.ID, &user.Login)
+		).Scan(&user.ID, &user.Login, &user.Role)
 	})
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, domain.MakeError(lib.StandardError("postgresql.GetUserByLogin", err), domain.ErrUserNotFound)
+		}
 		return models.User{}, translate("postgresql.GetUserByLogin.select", err)
 	}
 
@@ -52,11 +63,17 @@ func (s *PostgresStorage) GetUserByID(ctx context.Context, id int64) (models.Use
 
 	err := retryWrapper(ctx, func() error {
 		return s.Database.QueryRowContext(ctx,
-			`SELECT id, login_name FROM users WHERE id = $1`,
+			`SELECT u.id, u.login_name, COALESCE(r.role, 'user')
+			 FROM users u
+			 LEFT JOIN user_roles r ON r.user_id = u.id
+			 WHERE u.id = $1`,
 			id,
-		).Scan(&user.ID, &user.Login)
+		).Scan(&user.ID, &user.Login, &user.Role)
 	})
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, domain.MakeError(lib.StandardError("postgresql.GetUserByID", err), domain.ErrUserNotFound)
+		}
 		return models.User{}, translate("postgresql.GetUserByLogin.select", err)
 	}
 
@@ -115,6 +132,9 @@ func (s *PostgresStorage) ValidateUser(ctx context.Context, u models.User) error
 			u.Login, u.Password,
 		).Scan(&ok)
 	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.MakeError(fmt.Errorf("postgresql.ValidateUser user not found"), domain.ErrInvalidCredentials)
+	}
 	if err != nil {
 		return translate("postgresql.ValidateUser.select", err)
 	}
@@ -212,14 +232,83 @@ func (s *PostgresStorage) CreateOrder(ctx context.Context, user string, order mo
 	})
 
 	if err != nil {
-		return translate("postgresql.CreateOrder", err)
+		if code, ok := sqlState(err); ok && code == pgerrcode.UniqueViolation {
+			return domain.MakeError(lib.StandardError("postgresql.CreateOrder", err), domain.ErrOrderCreatedByUser)
+		}
+		return translateTx("postgresql.CreateOrder", err)
 	}
 	return nil
 }
 
 func (s *PostgresStorage) FetchNewOrders(ctx context.Context, limit int) ([]models.Order, error) {
+	var orders []models.Order
+
+	err := retryWrapper(ctx, func() error {
+		tx, err := s.Database.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		orders, err = claimNewOrders(ctx, tx, limit)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return []models.Order{}, translate("postgresql.FetchNewOrders", err)
+	}
+	return orders, nil
+}
+
+// claimNewOrders переводит до limit самых старых заказов из NEW в PROCESSING внутри tx.
+func claimNewOrders(ctx context.Context, tx *sql.Tx, limit int) ([]models.Order, error) {
 	orders := make([]models.Order, 0, limit)
 
+	rows, err := tx.QueryContext(ctx,
+		`WITH ts AS (SELECT now() AS ts)
+		 UPDATE user_orders u
+		 SET status = 'PROCESSING',
+		 	processing_started_at = ts.ts,
+		 	next_attempt_at = ts.ts + $2 * interval '1 second'
+		 FROM ts
+		 WHERE u.id IN (
+		 	SELECT id
+		 	FROM user_orders
+		 	WHERE status = 'NEW'
+		 	ORDER BY created_at ASC
+		 	FOR UPDATE SKIP LOCKED
+		 	LIMIT $1
+		 )
+		 RETURNING u.order_number, u.user_id, u.points_awarded, u.created_at, u.attempts;`,
+		limit, processingLease.Seconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o models.Order
+		err := rows.Scan(&o.Number, &o.UserID, &o.Accrual, &o.UploadedAt, &o.Attempts)
+		if err != nil {
+			return nil, err
+		}
+		o.Status = "PROCESSING"
+		orders = append(orders, o)
+	}
+
+	return orders, rows.Err()
+}
+
+// ClaimOrder переводит заказ NEW в обработку. Заказ уже в обработке возвращается как есть.
+func (s *PostgresStorage) ClaimOrder(ctx context.Context, orderNumber string) (models.Order, error) {
+	const op = "postgresql.ClaimOrder"
+	var o models.Order
+
 	err := retryWrapper(ctx, func() error {
 		tx, err := s.Database.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
 		if err != nil {
@@ -227,50 +316,45 @@ func (s *PostgresStorage) FetchNewOrders(ctx context.Context, limit int) ([]mode
 		}
 		defer func() { _ = tx.Rollback() }()
 
-		rows, err := tx.QueryContext(ctx,
-			`WITH ts AS (SELECT now() AS ts)
-			 UPDATE user_orders u
-			 SET status = 'PROCESSING',
-			 	processing_started_at = ts.ts
-			 FROM ts
-			 WHERE u.id IN (
-			 	SELECT id
-			 	FROM user_orders
-			 	WHERE status = 'NEW'
-			 	ORDER BY created_at ASC
-			 	FOR UPDATE SKIP LOCKED
-			 	LIMIT $1
-			 )
-			 RETURNING u.order_number, u.user_id, u.points_awarded, u.created_at;`,
-			limit,
-		)
+		o = models.Order{Number: orderNumber}
+		err = tx.QueryRowContext(ctx, `
+		SELECT user_id, status, points_awarded, created_at, attempts
+		FROM user_orders
+		WHERE order_number = $1
+		FOR UPDATE;
+		`, orderNumber).Scan(&o.UserID, &o.Status, &o.Accrual, &o.UploadedAt, &o.Attempts)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.MakeError(fmt.Errorf("%s order %q not found", op, orderNumber), domain.ErrOrderNotFound)
+		}
 		if err != nil {
 			return err
 		}
-		defer rows.Close()
 
-		for rows.Next() {
-			var o models.Order
-			err := rows.Scan(&o.Number, &o.UserID, &o.Accrual, &o.UploadedAt)
+		switch o.Status {
+		case "NEW":
+			_, err = tx.ExecContext(ctx, `
+			UPDATE user_orders
+			SET status = 'PROCESSING',
+				processing_started_at = now(),
+				next_attempt_at = now() + $2 * interval '1 second'
+			WHERE order_number = $1;
+			`, orderNumber, processingLease.Seconds())
 			if err != nil {
 				return err
 			}
 			o.Status = "PROCESSING"
-			orders = append(orders, o)
-		}
-
-		err = rows.Err()
-		if err != nil {
-			return err
+		case "PROCESSING":
+		default:
+			return domain.MakeError(fmt.Errorf("%s order %q already %s", op, orderNumber, o.Status), domain.ErrOrderFinalized)
 		}
 
 		return tx.Commit()
 	})
-
 	if err != nil {
-		return []models.Order{}, translate("postgresql.FetchNewOrders", err)
+		return models.Order{}, translateTx(op, err)
 	}
-	return orders, nil
+
+	return o, nil
 }
 
 func (s *PostgresStorage) FetchProccesingOrders(ctx context.Context, limit int) ([]models.Order, error) {
@@ -286,24 +370,23 @@ func (s *PostgresStorage) FetchProccesingOrders(ctx context.Context, limit int)
 		rows, err := tx.QueryContext(ctx,
 			`WITH ts AS (SELECT now() AS ts)
 			 UPDATE user_orders u
-			 SET processing_started_at = ts.ts
+			 SET processing_started_at = ts.ts,
+			 	next_attempt_at = ts.ts + $2 * interval '1 second'
 			 FROM ts
 			 WHERE u.id IN (
 			 	SELECT id
-			 	FROM user_orders
+			 	FROM user_orders, ts
 			 	WHERE status = 'PROCESSING'
-			 	AND (
-			 		processing_started_at IS NULL OR 
-					processing_started_at < ts.ts - interval '5 minutes'
-			 	)
+			 	AND dead_lettered_at IS NULL
+			 	AND COALESCE(next_attempt_at, processing_started_at + $2 * interval '1 second', ts.ts) <= ts.ts
 			 	ORDER BY
-					processing_started_at NULLS FIRST,
+					COALESCE(next_attempt_at, processing_started_at) NULLS FIRST,
 					created_at
-			 	FOR UPDATE SKIP LOCKED
+			 	FOR UPDATE OF user_orders SKIP LOCKED
 			 	LIMIT $1
 			 )
-			 RETURNING u.order_number, u.user_id, u.points_awarded, u.created_at;`,
-			limit,
+			 RETURNING u.order_number, u.user_id, u.points_awarded, u.created_at, u.attempts;`,
+			limit, processingLease.Seconds(),
 		)
 		if err != nil {
 			return err
@@ -312,11 +395,12 @@ func (s *PostgresStorage) FetchProccesingOrders(ctx context.Context, limit int)
 
 		for rows.Next() {
 			var o models.Order
-			err := rows.Scan(&o.Number, &o.UserID, &o.Accrual, &o.UploadedAt)
+			err := rows.Scan(&o.Number, &o.UserID, &o.Accrual, &o.UploadedAt, &o.Attempts)
 			if err != nil {
 				return err
 			}
 
+			o.Status = "PROCESSING"
 			orders = append(orders, o)
 		}
 
@@ -334,7 +418,9 @@ func (s *PostgresStorage) FetchProccesingOrders(ctx context.Context, limit int)
 	return orders, nil
 }
 
-func (s *PostgresStorage) UpdateOrderProcessed(ctx context.Context, orderNumber string, points float64) error {
+// FinalizeOrder в одной транзакции переводит заказ в PROCESSED, добавляет запись
+// начисления в журнал и обновляет кэш баланса. Повторный вызов ничего не меняет.
+func (s *PostgresStorage) FinalizeOrder(ctx context.Context, order models.Order, points money.Amount) error {
 	err := retryWrapper(ctx, func() error {
 		tx, err := s.Database.BeginTx(ctx, &sql.TxOptions{
 			Isolation: sql.LevelReadCommitted,
@@ -344,27 +430,56 @@ func (s *PostgresStorage) UpdateOrderProcessed(ctx context.Context, orderNumber
 		}
 		defer func() { _ = tx.Rollback() }()
 
-		result, err := tx.ExecContext(ctx, `
+		var orderID int64
+		err = tx.QueryRowContext(ctx, `
 		UPDATE user_orders
 		SET status = 'PROCESSED',
-		    points_awarded = $2
-		WHERE order_number = $1 AND status = 'PROCESSING'`, orderNumber, points)
+		    points_awarded = $3,
+		    dead_lettered_at = NULL
+		WHERE user_id = $1 AND order_number = $2 AND status = 'PROCESSING'
+		RETURNING id;
+		`, order.UserID, order.Number, points).Scan(&orderID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := lockBalance(ctx, tx, order.UserID); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, `
+		INSERT INTO user_balance_entries (user_id, entry_type, amount_points, order_id)
+		VALUES ($1, 'accrual', $2, $3)
+		ON CONFLICT (order_id, entry_type) DO NOTHING;
+		`, order.UserID, points, orderID)
 		if err != nil {
 			return err
 		}
 
 		affected, err := result.RowsAffected()
 		if err != nil {
-			return domain.MakeError(fmt.Errorf("postgresql.CreateOrder Check Rows"), domain.ErrInternal)
+			return domain.MakeError(fmt.Errorf("postgresql.FinalizeOrder Check Rows"), domain.ErrInternal)
 		}
-		if affected == 0 {
-			return nil
+
+		if affected == 1 {
+			_, err = tx.ExecContext(ctx, `
+			UPDATE user_point_balances
+			SET balance    = balance + $2,
+				updated_at = now()
+			WHERE user_id = $1;
+			`, order.UserID, points)
+			if err != nil {
+				return err
+			}
 		}
 
 		return tx.Commit()
 	})
 	if err != nil {
-		return translate("postgresql.UpdateOrderProcessed", err)
+		return translate("postgresql.FinalizeOrder", err)
 	}
 	return nil
 }
@@ -381,7 +496,8 @@ func (s *PostgresStorage) UpdateOrderInvalid(ctx context.Context, orderNumber st
 
 		result, err := tx.ExecContext(ctx, `
 		UPDATE user_orders
-		SET status = 'INVALID'
+		SET status = 'INVALID',
+			dead_lettered_at = NULL
 		WHERE order_number = $1 AND status = 'PROCESSING'`, orderNumber)
 		if err != nil {
 			return err
@@ -445,50 +561,6 @@ func (s *PostgresStorage) GetOrders(ctx context.Context, user string) ([]models.
 	return orders, nil
 }
 
-func (s *PostgresStorage) UpdateBalanceEntries(ctx context.Context, order models.Order) error {
-	err := retryWrapper(ctx, func() error {
-		tx, err := s.Database.BeginTx(ctx, &sql.TxOptions{
-			Isolation: sql.LevelReadCommitted,
-		})
-		if err != nil {
-			return err
-		}
-		defer func() { _ = tx.Rollback() }()
-
-		result, err := tx.ExecContext(ctx, `
-		INSERT INTO user_balance_entries (user_id, entry_type, amount_points, order_id)
-		SELECT uo.user_id, 'accrual', uo.points_awarded, uo.id
-		FROM user_orders uo
-		WHERE uo.status = 'PROCESSED'
-		  AND uo.user_id = $1
-		  AND uo.order_number = $2
-		ON CONFLICT (order_id, entry_type) DO NOTHING;
-		`, order.UserID, order.Number)
-		if err != nil {
-			return err
-		}
-
-		affected, err := result.RowsAffected()
-		if err != nil {
-			return domain.MakeError(fmt.Errorf("postgresql.CreateOrder Check Rows"), domain.ErrInternal)
-		}
-		if affected == 0 {
-			return tx.Rollback()
-		}
-
-		return tx.Commit()
-	})
-	if err != nil {
-		return translate("postgresql.UpdateBalanceEntries", err)
-	}
-
-	if err = s.UpdateBalance(ctx, order.UserID); err != nil {
-		return err
-	}
-
-	return nil
-}
-
 func (s *PostgresStorage) UpdateMissingBalanceEntries(ctx context.Context) error {
 	var users []uint64
 
@@ -560,17 +632,26 @@ func (s *PostgresStorage) UpdateBalance(ctx context.Context, user uint64) error
 		}
 		defer func() { _ = tx.Rollback() }()
 
-		result, err := tx.ExecContext(ctx, `
+		// блокировка строки кэша, чтобы пересчёт не затёр параллельное списание
+		if _, err := lockBalance(ctx, tx, user); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
 		INSERT INTO user_point_balances (user_id, balance, withdrawal, updated_at)
 		SELECT 
-			upb.user_id,
+			u.id,
 			COALESCE(SUM(CASE WHEN upb.entry_type = 'accrual' THEN upb.amount_points ELSE 0 END), 0)
-			- COALESCE(SUM(CASE WHEN upb.entry_type = 'withdrawal' THEN upb.amount_points ELSE 0 END), 0) AS balance,
-			COALESCE(SUM(CASE WHEN upb.entry_type = 'withdrawal' THEN upb.amount_points ELSE 0 END), 0) AS withdrawal,
+			- COALESCE(SUM(CASE WHEN upb.entry_type = 'withdrawal' THEN upb.amount_points ELSE 0 END), 0)
+			+ COALESCE(SUM(CASE WHEN upb.entry_type = 'adjustment' THEN upb.amount_points ELSE 0 END), 0) AS balance,
+			COALESCE(SUM(CASE WHEN upb.entry_type = 'withdrawal' THEN upb.amount_points ELSE 0 END), 0)
+			- COALESCE(SUM(CASE WHEN upb.entry_type = 'adjustment' AND orig.entry_type = 'withdrawal' THEN upb.amount_points ELSE 0 END), 0) AS withdrawal,
 			now()
-		FROM user_balance_entries AS upb
-		WHERE upb.user_id = $1
-		GROUP BY upb.user_id
+		FROM users AS u
+		LEFT JOIN user_balance_entries AS upb ON upb.user_id = u.id
+		LEFT JOIN user_balance_entries AS orig ON orig.id = upb.reverses_entry_id
+		WHERE u.id = $1
+		GROUP BY u.id
 		ON CONFLICT (user_id) DO UPDATE
 		SET balance   = EXCLUDED.balance,
 			withdrawal = EXCLUDED.withdrawal,
@@ -580,14 +661,6 @@ func (s *PostgresStorage) UpdateBalance(ctx context.Context, user uint64) error
 			return err
 		}
 
-		affected, err := result.RowsAffected()
-		if err != nil {
-			return domain.MakeError(fmt.Errorf("postgresql.CreateOrder Check Rows"), domain.ErrInternal)
-		}
-		if affected == 0 {
-			return nil
-		}
-
 		return tx.Commit()
 	})
 	if err != nil {
@@ -607,31 +680,41 @@ func (s *PostgresStorage) UpdateWithdrawlEntries(ctx context.Context, userID uin
 		}
 		defer func() { _ = tx.Rollback() }()
 
-		result, err := tx.ExecContext(ctx, `
+		current, err := lockBalance(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if current < withdraw.Sum {
+			return domain.MakeError(fmt.Errorf("postgresql.UpdateWithdrawlEntries balance is lower than the amount indicated"), domain.ErrPaymentRequired)
+		}
+
+		_, err = tx.ExecContext(ctx, `
 		INSERT INTO user_balance_entries (user_id, entry_type, amount_points, withdrawal_ref)
-		VALUES ($1, 'withdrawal', $2, $3)
-		ON CONFLICT (order_id, entry_type) DO NOTHING;
+		VALUES ($1, 'withdrawal', $2, $3);
 		`, userID, withdraw.Sum, withdraw.Order)
 		if err != nil {
 			return err
 		}
 
-		affected, err := result.RowsAffected()
+		_, err = tx.ExecContext(ctx, `
+		UPDATE user_point_balances
+		SET balance    = balance - $2,
+			withdrawal = withdrawal + $2,
+			updated_at = now()
+		WHERE user_id = $1;
+		`, userID, withdraw.Sum)
 		if err != nil {
-			return domain.MakeError(fmt.Errorf("postgresql.UpdateWithdrawlEntries Check Rows"), domain.ErrInternal)
-		}
-		if affected == 0 {
-			return tx.Rollback()
+			return err
 		}
 
 		return tx.Commit()
 	})
 	if err != nil {
-		return translate("postgresql.UpdateWithdrawlEntries", err)
-	}
-
-	if err = s.UpdateBalance(ctx, userID); err != nil {
-		return err
+		if code, ok := sqlState(err); ok && code == pgerrcode.UniqueViolation {
+			return domain.MakeError(lib.StandardError("postgresql.UpdateWithdrawlEntries", err), domain.ErrOrderAlreadyExists)
+		}
+		return translateTx("postgresql.UpdateWithdrawlEntries", err)
 	}
 
 	return nil
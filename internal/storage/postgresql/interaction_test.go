@@ -0,0 +1,384 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStorage подключается к базе из TEST_DATABASE_URI и применяет миграции.
+// Без переменной окружения тест пропускается.
+func newTestStorage(t *testing.T) *PostgresStorage {
+	t.Helper()
+
+	dsn, ok := os.LookupEnv("TEST_DATABASE_URI")
+	if !ok || dsn == "" {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+
+	// миграции ищутся относительно корня репозитория
+	t.Chdir("../../..")
+
+	s, err := NewPostgresStorage(u)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Database.Close() })
+
+	return s
+}
+
+func newTestUser(t *testing.T, s *PostgresStorage, points money.Amount) models.User {
+	t.Helper()
+	ctx := context.Background()
+
+	login := fmt.Sprintf("user_%d", time.Now().UnixNano())
+	require.NoError(t, s.RegisterUser(ctx, models.User{Login: login, Password: "password"}))
+
+	user, err := s.GetUserByLogin(ctx, login)
+	require.NoError(t, err)
+
+	_, err = s.Database.ExecContext(ctx, `
+		INSERT INTO user_balance_entries (user_id, entry_type, amount_points)
+		VALUES ($1, 'accrual', $2)`, user.ID, points)
+	require.NoError(t, err)
+	require.NoError(t, s.UpdateBalance(ctx, user.ID))
+
+	return user
+}
+
+func TestUpdateWithdrawlEntries_Concurrent(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	const workers = 20
+	sum := money.FromInt(10)
+	user := newTestUser(t, s, money.FromInt(55))
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		ok      int
+		payment int
+		other   []error
+	)
+
+	for i := range workers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			err := s.UpdateWithdrawlEntries(ctx, user.ID, models.Withdrawal{
+				Order: fmt.Sprintf("%d-%d", user.ID, i),
+				Sum:   sum,
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				ok++
+			case errors.Is(err, domain.ErrPaymentRequired):
+				payment++
+			default:
+				other = append(other, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Empty(t, other)
+	require.Equal(t, 5, ok)
+	require.Equal(t, workers-5, payment)
+
+	balance, err := s.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, money.FromInt(5), balance.Current)
+	require.Equal(t, money.FromInt(50), balance.Withdrawn)
+}
+
+func TestUpdateWithdrawlEntries_NotEnoughBalance(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	user := newTestUser(t, s, money.FromInt(5))
+
+	err := s.UpdateWithdrawlEntries(ctx, user.ID, models.Withdrawal{
+		Order: fmt.Sprintf("%d-single", user.ID),
+		Sum:   money.FromInt(10),
+	})
+	require.ErrorIs(t, err, domain.ErrPaymentRequired)
+}
+
+func TestReverseBalanceEntry_Withdrawal(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	user := newTestUser(t, s, money.FromInt(20))
+
+	order := fmt.Sprintf("%d-reverse", user.ID)
+	require.NoError(t, s.UpdateWithdrawlEntries(ctx, user.ID, models.Withdrawal{Order: order, Sum: money.FromInt(15)}))
+
+	var id int64
+	err := s.Database.QueryRowContext(ctx,
+		`SELECT id FROM user_balance_entries WHERE withdrawal_ref = $1`, order).Scan(&id)
+	require.NoError(t, err)
+
+	entry, err := s.ReverseBalanceEntry(ctx, models.Reversal{EntryID: id, Reason: "order cancelled"})
+	require.NoError(t, err)
+	require.Equal(t, models.EntryAdjustment, entry.Type)
+	require.Equal(t, money.FromInt(15), entry.Amount)
+	require.Equal(t, id, entry.ReversesID)
+
+	_, err = s.ReverseBalanceEntry(ctx, models.Reversal{EntryID: id, Reason: "twice"})
+	require.ErrorIs(t, err, domain.ErrEntryAlreadyReversed)
+
+	balance, err := s.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, money.FromInt(20), balance.Current)
+	require.Equal(t, money.Amount(0), balance.Withdrawn)
+
+	require.NoError(t, s.UpdateBalance(ctx, user.ID))
+	recomputed, err := s.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, balance, recomputed)
+}
+
+func TestAdjustBalance_Debit(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	user := newTestUser(t, s, money.FromInt(5))
+
+	_, err := s.AdjustBalance(ctx, models.Adjustment{UserID: user.ID, Amount: money.FromInt(-6), Reason: "too much"})
+	require.ErrorIs(t, err, domain.ErrPaymentRequired)
+
+	entry, err := s.AdjustBalance(ctx, models.Adjustment{UserID: user.ID, Amount: money.FromCents(-250), Reason: "fix"})
+	require.NoError(t, err)
+	require.Equal(t, money.FromCents(-250), entry.Amount)
+
+	balance, err := s.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, money.FromCents(250), balance.Current)
+}
+
+func TestSetUserRole(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	user := newTestUser(t, s, 0)
+	require.Equal(t, models.RoleUser, user.Role)
+
+	require.NoError(t, s.SetUserRole(ctx, user.Login, models.RoleAdmin))
+	got, err := s.GetUserByID(ctx, int64(user.ID))
+	require.NoError(t, err)
+	require.Equal(t, models.RoleAdmin, got.Role)
+
+	require.NoError(t, s.SetUserRole(ctx, user.Login, models.RoleUser))
+	got, err = s.GetUserByLogin(ctx, user.Login)
+	require.NoError(t, err)
+	require.Equal(t, models.RoleUser, got.Role)
+
+	require.ErrorIs(t, s.SetUserRole(ctx, user.Login+"-missing", models.RoleAdmin), domain.ErrUserNotFound)
+}
+
+func TestResetAndInvalidateOrder(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	user := newTestUser(t, s, 0)
+	number := fmt.Sprintf("%d", time.Now().UnixNano())
+	require.NoError(t, s.CreateOrder(ctx, user.Login, models.Order{Number: number}))
+
+	require.NoError(t, s.InvalidateOrder(ctx, number))
+	require.NoError(t, s.ResetOrder(ctx, number))
+
+	orders, err := s.GetOrders(ctx, user.Login)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	require.Equal(t, "NEW", orders[0].Status)
+
+	require.ErrorIs(t, s.ResetOrder(ctx, number+"0"), domain.ErrOrderNotFound)
+}
+
+func TestGetOrdersPage(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	user := newTestUser(t, s, 0)
+	base := time.Now().UnixNano()
+	for i := range 5 {
+		require.NoError(t, s.CreateOrder(ctx, user.Login, models.Order{Number: fmt.Sprintf("%d%d", base, i)}))
+	}
+	require.NoError(t, s.InvalidateOrder(ctx, fmt.Sprintf("%d%d", base, 0)))
+
+	all, err := s.GetOrders(ctx, user.Login)
+	require.NoError(t, err)
+
+	var (
+		got   []models.Order
+		after *models.Cursor
+	)
+	for {
+		page, err := s.GetOrdersPage(ctx, user.Login, models.OrderFilter{Limit: 2, After: after})
+		require.NoError(t, err)
+		got = append(got, page.Orders...)
+		if page.Next == nil {
+			break
+		}
+		after = page.Next
+	}
+	require.Equal(t, all, got)
+
+	page, err := s.GetOrdersPage(ctx, user.Login, models.OrderFilter{Limit: 10, Statuses: []string{"INVALID"}})
+	require.NoError(t, err)
+	require.Len(t, page.Orders, 1)
+	require.Nil(t, page.Next)
+
+	future := time.Now().Add(time.Hour)
+	page, err = s.GetOrdersPage(ctx, user.Login, models.OrderFilter{Limit: 10, From: &future})
+	require.NoError(t, err)
+	require.Empty(t, page.Orders)
+}
+
+func TestGetLedgerPage(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	user := newTestUser(t, s, money.FromInt(30))
+	require.NoError(t, s.UpdateWithdrawlEntries(ctx, user.ID, models.Withdrawal{Order: fmt.Sprintf("%d-ledger", user.ID), Sum: money.FromInt(10)}))
+	_, err := s.AdjustBalance(ctx, models.Adjustment{UserID: user.ID, Amount: money.FromCents(250), Reason: "bonus"})
+	require.NoError(t, err)
+
+	var (
+		got   []models.LedgerEntry
+		after *models.Cursor
+	)
+	for {
+		page, err := s.GetLedgerPage(ctx, user.ID, models.LedgerFilter{Limit: 2, After: after})
+		require.NoError(t, err)
+		got = append(got, page.Entries...)
+		if page.Next == nil {
+			break
+		}
+		after = page.Next
+	}
+
+	require.Len(t, got, 3)
+	require.Equal(t, money.FromCents(2250), got[0].RunningBalance)
+	require.Equal(t, money.FromInt(20), got[1].RunningBalance)
+	require.Equal(t, money.FromInt(-10), got[1].Amount)
+	require.Equal(t, money.FromInt(30), got[2].RunningBalance)
+
+	future := time.Now().Add(time.Hour)
+	page, err := s.GetLedgerPage(ctx, user.ID, models.LedgerFilter{Limit: 10, From: &future})
+	require.NoError(t, err)
+	require.Empty(t, page.Entries)
+}
+
+func TestCheckBalances_Repair(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	user := newTestUser(t, s, money.FromInt(10))
+
+	_, err := s.Database.ExecContext(ctx,
+		`UPDATE user_point_balances SET balance = 3 WHERE user_id = $1`, user.ID)
+	require.NoError(t, err)
+
+	checks, err := s.CheckBalances(ctx, user.ID-1, 1)
+	require.NoError(t, err)
+	require.Len(t, checks, 1)
+	require.Equal(t, user.ID, checks[0].UserID)
+	require.False(t, checks[0].Consistent())
+	require.Equal(t, money.FromInt(10), checks[0].LedgerBalance)
+
+	require.NoError(t, s.UpdateBalance(ctx, user.ID))
+
+	checks, err = s.CheckBalances(ctx, user.ID-1, 1)
+	require.NoError(t, err)
+	require.True(t, checks[0].Consistent())
+}
+
+func TestFinalizeOrder(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	user := newTestUser(t, s, money.FromInt(1))
+	order := models.Order{UserID: user.ID, Number: fmt.Sprintf("%d", time.Now().UnixNano())}
+	require.NoError(t, s.CreateOrder(ctx, user.Login, order))
+
+	_, err := s.Database.ExecContext(ctx,
+		`UPDATE user_orders SET status = 'PROCESSING' WHERE order_number = $1`, order.Number)
+	require.NoError(t, err)
+
+	require.NoError(t, s.FinalizeOrder(ctx, order, money.FromCents(1050)))
+	require.NoError(t, s.FinalizeOrder(ctx, order, money.FromCents(1050)))
+
+	balance, err := s.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, money.FromCents(1150), balance.Current)
+
+	entries, err := s.GetBalanceEntries(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, order.Number, entries[0].Order)
+}
+
+func TestReconcileReport_RoundTrip(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	_, err := s.Database.ExecContext(ctx, `DELETE FROM reconcile_report`)
+	require.NoError(t, err)
+
+	_, err = s.GetReconcileReport(ctx)
+	require.True(t, errors.Is(err, domain.ErrNoContent))
+
+	first := models.ReconcileReport{StartedAt: time.Now().UTC().Truncate(time.Second), Checked: 3, Mismatches: 1}
+	require.NoError(t, s.SaveReconcileReport(ctx, first))
+
+	second := first
+	second.Checked = 5
+	second.Samples = []models.BalanceCheck{{UserID: 7, CachedBalance: money.FromInt(1)}}
+	require.NoError(t, s.SaveReconcileReport(ctx, second))
+
+	got, err := s.GetReconcileReport(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 5, got.Checked)
+	require.True(t, second.StartedAt.Equal(got.StartedAt))
+	require.Equal(t, second.Samples, got.Samples)
+}
+
+func TestReserveIdempotencyKey_PurgesOtherUsers(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	idle := newTestUser(t, s, 0)
+	active := newTestUser(t, s, 0)
+
+	_, reserved, err := s.ReserveIdempotencyKey(ctx, idle.ID, "expired", "hash", -time.Second)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	_, reserved, err = s.ReserveIdempotencyKey(ctx, active.ID, "fresh", "hash", time.Hour)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	var left int
+	err = s.Database.QueryRowContext(ctx,
+		`SELECT count(*) FROM idempotency_keys WHERE user_id = $1`, idle.ID).Scan(&left)
+	require.NoError(t, err)
+	require.Zero(t, left)
+}
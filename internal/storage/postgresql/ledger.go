@@ -0,0 +1,62 @@
+package postgresql
+
+import (
+	"context"
+	"yandex-diplom/internal/models"
+)
+
+func (s *PostgresStorage) GetLedgerPage(ctx context.Context, userID uint64, filter models.LedgerFilter) (models.LedgerPage, error) {
+	page := models.LedgerPage{Entries: make([]models.LedgerEntry, 0, filter.Limit)}
+	afterAt, afterID := cursorArgs(filter.After)
+
+	err := retryWrapper(ctx, func() error {
+		page = models.LedgerPage{Entries: page.Entries[:0]}
+
+		// накопительный итог считается по всему журналу, а фильтры применяются после
+		rows, err := s.Database.QueryContext(ctx, `
+			WITH ledger AS (
+				SELECT `+balanceEntryColumns+`,
+					SUM(CASE WHEN e.entry_type = 'withdrawal' THEN -e.amount_points ELSE e.amount_points END)
+						OVER (ORDER BY e.posted_at, e.id) AS running_balance
+				FROM user_balance_entries e
+				LEFT JOIN user_orders uo ON uo.id = e.order_id
+				WHERE e.user_id = $1
+			)
+			SELECT * FROM ledger l
+			WHERE ($2::timestamptz IS NULL OR l.posted_at >= $2)
+			  AND ($3::timestamptz IS NULL OR l.posted_at < $3)
+			  AND ($4::timestamptz IS NULL OR (l.posted_at, l.id) < ($4, $5))
+			ORDER BY l.posted_at DESC, l.id DESC
+			LIMIT $6`,
+			userID, filter.From, filter.To, afterAt, afterID, filter.Limit+1,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var e models.LedgerEntry
+			err = rows.Scan(&e.ID, &e.UserID, &e.Type, &e.Amount, &e.Order, &e.Reference, &e.Reason,
+				&e.OperatorID, &e.ReversesID, &e.PostedAt, &e.RunningBalance)
+			if err != nil {
+				return err
+			}
+
+			if len(page.Entries) == filter.Limit {
+				last := page.Entries[len(page.Entries)-1]
+				page.Next = &models.Cursor{At: *last.PostedAt, ID: last.ID}
+				break
+			}
+
+			page.Entries = append(page.Entries, e)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return models.LedgerPage{Entries: []models.LedgerEntry{}}, translate("postgresql.GetLedgerPage", err)
+	}
+
+	return page, nil
+}
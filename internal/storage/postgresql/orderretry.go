@@ -0,0 +1,115 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"yandex-diplom/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// processingLease на сколько заказ закрепляется за воркером при выдаче.
+// Если воркер не назначил следующую попытку, заказ вернётся в опрос по истечении срока.
+const processingLease = 5 * time.Minute
+
+// ScheduleOrderRetry откладывает следующий опрос заказа до next.
+func (s *PostgresStorage) ScheduleOrderRetry(ctx context.Context, orderNumber string, next time.Time, reason string) error {
+	err := retryWrapper(ctx, func() error {
+		_, err := s.Database.ExecContext(ctx, `
+		UPDATE user_orders
+		SET attempts = attempts + 1,
+			next_attempt_at = $2,
+			last_error = $3
+		WHERE order_number = $1
+			AND status = 'PROCESSING'
+			AND dead_lettered_at IS NULL;
+		`, orderNumber, next, reason)
+		return err
+	})
+	if err != nil {
+		return translate("postgresql.ScheduleOrderRetry", err)
+	}
+	return nil
+}
+
+// DeadLetterOrder снимает заказ с опроса. Заказ остаётся в PROCESSING до решения администратора.
+func (s *PostgresStorage) DeadLetterOrder(ctx context.Context, orderNumber string, reason string) error {
+	err := retryWrapper(ctx, func() error {
+		_, err := s.Database.ExecContext(ctx, `
+		UPDATE user_orders
+		SET attempts = attempts + 1,
+			next_attempt_at = NULL,
+			last_error = $2,
+			dead_lettered_at = now()
+		WHERE order_number = $1
+			AND status = 'PROCESSING'
+			AND dead_lettered_at IS NULL;
+		`, orderNumber, reason)
+		return err
+	})
+	if err != nil {
+		return translate("postgresql.DeadLetterOrder", err)
+	}
+	return nil
+}
+
+// ReleaseOrders возвращает в NEW заказы, взятые в обработку, но так и не опрошенные.
+func (s *PostgresStorage) ReleaseOrders(ctx context.Context, orderNumbers []string) error {
+	err := retryWrapper(ctx, func() error {
+		_, err := s.Database.ExecContext(ctx, `
+		UPDATE user_orders
+		SET status = 'NEW',
+			processing_started_at = NULL,
+			next_attempt_at = NULL
+		WHERE order_number = ANY($1)
+			AND status = 'PROCESSING'
+			AND dead_lettered_at IS NULL;
+		`, pq.Array(orderNumbers))
+		return err
+	})
+	if err != nil {
+		return translate("postgresql.ReleaseOrders", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetDeadLetterOrders(ctx context.Context, limit int) ([]models.DeadLetterOrder, error) {
+	orders := make([]models.DeadLetterOrder, 0)
+
+	err := retryWrapper(ctx, func() error {
+		orders = orders[:0]
+
+		rows, err := s.Database.QueryContext(ctx, `
+		SELECT o.order_number, o.user_id, u.login_name, o.attempts, o.last_error, o.created_at, o.dead_lettered_at
+		FROM user_orders o
+		JOIN users u ON u.id = o.user_id
+		WHERE o.dead_lettered_at IS NOT NULL AND o.status = 'PROCESSING'
+		ORDER BY o.dead_lettered_at DESC, o.id DESC
+		LIMIT $1;
+		`, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				o         models.DeadLetterOrder
+				lastError sql.NullString
+			)
+			if err := rows.Scan(&o.Number, &o.UserID, &o.Login, &o.Attempts, &lastError, &o.UploadedAt, &o.DeadLetteredAt); err != nil {
+				return err
+			}
+			o.LastError = lastError.String
+			orders = append(orders, o)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return []models.DeadLetterOrder{}, translate("postgresql.GetDeadLetterOrders", err)
+	}
+
+	return orders, nil
+}
@@ -0,0 +1,75 @@
+package postgresql
+
+import (
+	"context"
+	"time"
+	"yandex-diplom/internal/models"
+
+	"github.com/lib/pq"
+)
+
+func cursorArgs(c *models.Cursor) (*time.Time, int64) {
+	if c == nil {
+		return nil, 0
+	}
+	return &c.At, c.ID
+}
+
+func (s *PostgresStorage) GetOrdersPage(ctx context.Context, user string, filter models.OrderFilter) (models.OrderPage, error) {
+	page := models.OrderPage{Orders: make([]models.Order, 0, filter.Limit)}
+
+	var statuses any
+	if len(filter.Statuses) > 0 {
+		statuses = pq.Array(filter.Statuses)
+	}
+	afterAt, afterID := cursorArgs(filter.After)
+
+	err := retryWrapper(ctx, func() error {
+		page = models.OrderPage{Orders: page.Orders[:0]}
+
+		rows, err := s.Database.QueryContext(ctx,
+			`
+			SELECT id, order_number, status, points_awarded, created_at
+			FROM public.user_orders
+			WHERE user_id = (SELECT id FROM public.users WHERE login_name = $1)
+			  AND ($2::text[] IS NULL OR status = ANY($2::text[]))
+			  AND ($3::timestamptz IS NULL OR created_at >= $3)
+			  AND ($4::timestamptz IS NULL OR created_at < $4)
+			  AND ($5::timestamptz IS NULL OR (created_at, id) < ($5, $6))
+			ORDER BY created_at DESC, id DESC
+			LIMIT $7`,
+			user, statuses, filter.From, filter.To, afterAt, afterID, filter.Limit+1,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var last models.Cursor
+		for rows.Next() {
+			var (
+				id int64
+				o  models.Order
+			)
+			err = rows.Scan(&id, &o.Number, &o.Status, &o.Accrual, &o.UploadedAt)
+			if err != nil {
+				return err
+			}
+
+			if len(page.Orders) == filter.Limit {
+				page.Next = &last
+				break
+			}
+
+			page.Orders = append(page.Orders, o)
+			last = models.Cursor{At: *o.UploadedAt, ID: id}
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return models.OrderPage{Orders: []models.Order{}}, translate("postgresql.GetOrdersPage", err)
+	}
+
+	return page, nil
+}
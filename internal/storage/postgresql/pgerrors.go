@@ -129,3 +129,12 @@ func translate(op string, err error) error {
 	}
 	return domain.MakeError(lib.StandardError(op, err), domain.ErrInternal)
 }
+
+// translateTx пропускает доменные ошибки, возвращённые изнутри транзакции, как есть.
+func translateTx(op string, err error) error {
+	var derr domain.Error
+	if errors.As(err, &derr) {
+		return domain.Wrap(op, derr)
+	}
+	return translate(op, err)
+}
@@ -0,0 +1,231 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// EnqueueTasks ставит задания в очередь. Задание с занятым ключом пропускается.
+func (s *PostgresStorage) EnqueueTasks(ctx context.Context, tasks ...models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	err := retryWrapper(ctx, func() error {
+		tx, err := s.Database.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		if err := insertTasks(ctx, tx, tasks); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return translate("postgresql.EnqueueTasks", err)
+	}
+	return nil
+}
+
+// EnqueueNewOrders переводит до limit заказов из NEW в PROCESSING и ставит на них
+// задания в той же транзакции. Если task или вставка вернули ошибку, заказы остаются в NEW.
+func (s *PostgresStorage) EnqueueNewOrders(ctx context.Context, limit int, task func(models.Order) (models.Task, error)) ([]models.Order, error) {
+	var orders []models.Order
+
+	err := retryWrapper(ctx, func() error {
+		tx, err := s.Database.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		orders, err = claimNewOrders(ctx, tx, limit)
+		if err != nil {
+			return err
+		}
+
+		tasks := make([]models.Task, 0, len(orders))
+		for _, o := range orders {
+			t, err := task(o)
+			if err != nil {
+				return domain.MakeError(fmt.Errorf("postgresql.EnqueueNewOrders task for order %q: %w", o.Number, err), domain.ErrInternal)
+			}
+			tasks = append(tasks, t)
+		}
+
+		if err := insertTasks(ctx, tx, tasks); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return []models.Order{}, translateTx("postgresql.EnqueueNewOrders", err)
+	}
+
+	return orders, nil
+}
+
+// insertTasks вставляет задания внутри tx, задания с занятым ключом пропускаются.
+func insertTasks(ctx context.Context, tx *sql.Tx, tasks []models.Task) error {
+	for _, t := range tasks {
+		payload := t.Payload
+		if len(payload) == 0 {
+			payload = []byte("{}")
+		}
+
+		_, err := tx.ExecContext(ctx, `
+		INSERT INTO job_queue (kind, dedup_key, payload)
+		VALUES ($1, NULLIF($2, ''), $3)
+		ON CONFLICT (dedup_key) DO NOTHING;
+		`, t.Kind, t.Key, string(payload))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LeaseTasks выдаёт до limit доступных заданий указанных видов и скрывает их
+// от других воркеров на visibility. Каждая выдача увеличивает attempts.
+func (s *PostgresStorage) LeaseTasks(ctx context.Context, kinds []string, limit int, visibility time.Duration) ([]models.Task, error) {
+	tasks := make([]models.Task, 0, limit)
+
+	err := retryWrapper(ctx, func() error {
+		tasks = tasks[:0]
+
+		rows, err := s.Database.QueryContext(ctx, `
+		WITH ts AS (SELECT now() AS ts)
+		UPDATE job_queue q
+		SET attempts = q.attempts + 1,
+			leased_until = ts.ts + $3 * interval '1 second'
+		FROM ts
+		WHERE q.id IN (
+			SELECT id
+			FROM job_queue, ts
+			WHERE kind = ANY($1)
+				AND available_at <= ts.ts
+				AND (leased_until IS NULL OR leased_until <= ts.ts)
+			ORDER BY available_at, id
+			FOR UPDATE OF job_queue SKIP LOCKED
+			LIMIT $2
+		)
+		RETURNING q.id, q.kind, COALESCE(q.dedup_key, ''), q.payload, q.attempts, COALESCE(q.last_error, '');
+		`, pq.Array(kinds), limit, visibility.Seconds())
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var t models.Task
+			if err := rows.Scan(&t.ID, &t.Kind, &t.Key, &t.Payload, &t.Attempts, &t.LastError); err != nil {
+				return err
+			}
+			tasks = append(tasks, t)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return []models.Task{}, translate("postgresql.LeaseTasks", err)
+	}
+
+	return tasks, nil
+}
+
+// AckTask удаляет выполненное задание. Если задание уже перевыдано другому
+// воркеру после истечения аренды, ничего не делает.
+func (s *PostgresStorage) AckTask(ctx context.Context, task models.Task) error {
+	err := retryWrapper(ctx, func() error {
+		_, err := s.Database.ExecContext(ctx, `
+		DELETE FROM job_queue WHERE id = $1 AND attempts = $2;
+		`, task.ID, task.Attempts)
+		return err
+	})
+	if err != nil {
+		return translate("postgresql.AckTask", err)
+	}
+	return nil
+}
+
+// NackTask возвращает задание в очередь не раньше retryAt.
+func (s *PostgresStorage) NackTask(ctx context.Context, task models.Task, retryAt time.Time, reason string) error {
+	err := retryWrapper(ctx, func() error {
+		_, err := s.Database.ExecContext(ctx, `
+		UPDATE job_queue
+		SET leased_until = NULL,
+			available_at = $3,
+			last_error = $4
+		WHERE id = $1 AND attempts = $2;
+		`, task.ID, task.Attempts, retryAt, reason)
+		return err
+	})
+	if err != nil {
+		return translate("postgresql.NackTask", err)
+	}
+	return nil
+}
+
+// CancelTasks удаляет задания с ключами keys, которые сейчас никому не выданы.
+func (s *PostgresStorage) CancelTasks(ctx context.Context, keys []string) ([]string, error) {
+	cancelled := make([]string, 0, len(keys))
+
+	err := retryWrapper(ctx, func() error {
+		cancelled = cancelled[:0]
+
+		rows, err := s.Database.QueryContext(ctx, `
+		DELETE FROM job_queue
+		WHERE dedup_key = ANY($1)
+			AND (leased_until IS NULL OR leased_until <= now())
+		RETURNING dedup_key;
+		`, pq.Array(keys))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				return err
+			}
+			cancelled = append(cancelled, key)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return []string{}, translate("postgresql.CancelTasks", err)
+	}
+
+	return cancelled, nil
+}
+
+func (s *PostgresStorage) CountTasks(ctx context.Context, kinds []string) (int, error) {
+	var count int
+
+	err := retryWrapper(ctx, func() error {
+		return s.Database.QueryRowContext(ctx, `
+		SELECT count(*)
+		FROM job_queue
+		WHERE kind = ANY($1)
+			AND available_at <= now()
+			AND (leased_until IS NULL OR leased_until <= now());
+		`, pq.Array(kinds)).Scan(&count)
+	})
+	if err != nil {
+		return 0, translate("postgresql.CountTasks", err)
+	}
+
+	return count, nil
+}
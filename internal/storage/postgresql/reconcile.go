@@ -0,0 +1,110 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+)
+
+// CheckBalances сравнивает кэш баланса с журналом для пачки пользователей с id > afterUserID.
+// Кэш и журнал читаются одним запросом, поэтому параллельные списания не дают ложных расхождений.
+func (s *PostgresStorage) CheckBalances(ctx context.Context, afterUserID uint64, limit int) ([]models.BalanceCheck, error) {
+	checks := make([]models.BalanceCheck, 0, limit)
+
+	err := retryWrapper(ctx, func() error {
+		checks = checks[:0]
+
+		rows, err := s.Database.QueryContext(ctx, `
+			WITH batch AS (
+				SELECT id FROM users WHERE id > $1 ORDER BY id LIMIT $2
+			), ledger AS (
+				SELECT e.user_id,
+					SUM(CASE WHEN e.entry_type = 'withdrawal' THEN -e.amount_points ELSE e.amount_points END) AS balance,
+					COALESCE(SUM(CASE WHEN e.entry_type = 'withdrawal' THEN e.amount_points ELSE 0 END), 0)
+					- COALESCE(SUM(CASE WHEN e.entry_type = 'adjustment' AND orig.entry_type = 'withdrawal' THEN e.amount_points ELSE 0 END), 0) AS withdrawn
+				FROM user_balance_entries e
+				LEFT JOIN user_balance_entries orig ON orig.id = e.reverses_entry_id
+				WHERE e.user_id IN (SELECT id FROM batch)
+				GROUP BY e.user_id
+			)
+			SELECT b.id,
+				COALESCE(p.balance, 0), COALESCE(l.balance, 0),
+				COALESCE(p.withdrawal, 0), COALESCE(l.withdrawn, 0)
+			FROM batch b
+			LEFT JOIN user_point_balances p ON p.user_id = b.id
+			LEFT JOIN ledger l ON l.user_id = b.id
+			ORDER BY b.id`,
+			afterUserID, limit,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var c models.BalanceCheck
+			err = rows.Scan(&c.UserID, &c.CachedBalance, &c.LedgerBalance, &c.CachedWithdrawn, &c.LedgerWithdrawn)
+			if err != nil {
+				return err
+			}
+			checks = append(checks, c)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return []models.BalanceCheck{}, translate("postgresql.CheckBalances", err)
+	}
+
+	return checks, nil
+}
+
+// SaveReconcileReport перезаписывает отчёт последнего прогона сверки, таблица хранит одну строку.
+func (s *PostgresStorage) SaveReconcileReport(ctx context.Context, report models.ReconcileReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return domain.MakeError(fmt.Errorf("postgresql.SaveReconcileReport marshal: %w", err), domain.ErrInternal)
+	}
+
+	err = retryWrapper(ctx, func() error {
+		_, err := s.Database.ExecContext(ctx, `
+		INSERT INTO reconcile_report (id, report)
+		VALUES (TRUE, $1)
+		ON CONFLICT (id) DO UPDATE
+		SET report   = EXCLUDED.report,
+			saved_at = now();
+		`, data)
+		return err
+	})
+	if err != nil {
+		return translate("postgresql.SaveReconcileReport", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetReconcileReport(ctx context.Context) (models.ReconcileReport, error) {
+	var data []byte
+
+	err := retryWrapper(ctx, func() error {
+		err := s.Database.QueryRowContext(ctx, `
+		SELECT report FROM reconcile_report WHERE id;
+		`).Scan(&data)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.MakeError(fmt.Errorf("postgresql.GetReconcileReport no report yet"), domain.ErrNoContent)
+		}
+		return err
+	})
+	if err != nil {
+		return models.ReconcileReport{}, translateTx("postgresql.GetReconcileReport", err)
+	}
+
+	var report models.ReconcileReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return models.ReconcileReport{}, domain.MakeError(fmt.Errorf("postgresql.GetReconcileReport unmarshal: %w", err), domain.ErrInternal)
+	}
+	return report, nil
+}
@@ -0,0 +1,184 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/models"
+)
+
+// CreateSession сохраняет сессию и хэш её первого refresh-токена.
+func (s *PostgresStorage) CreateSession(ctx context.Context, session models.Session, refreshHash string) error {
+	err := retryWrapper(ctx, func() error {
+		tx, err := s.Database.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_sessions (id, user_id, expires_at)
+		VALUES ($1, $2, $3);
+		`, session.ID, session.UserID, session.ExpiresAt)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO session_refresh_tokens (token_hash, session_id)
+		VALUES ($1, $2);
+		`, refreshHash, session.ID)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return translate("postgresql.CreateSession", err)
+	}
+	return nil
+}
+
+// RotateRefreshToken помечает токен oldHash использованным, добавляет newHash и
+// продлевает сессию до expiresAt. Повторное использование токена отзывает сессию:
+// тогда вместе с ErrInvalidToken возвращается отозванная сессия.
+func (s *PostgresStorage) RotateRefreshToken(ctx context.Context, oldHash string, newHash string, expiresAt time.Time) (models.Session, error) {
+	const op = "postgresql.RotateRefreshToken"
+	var session models.Session
+
+	err := retryWrapper(ctx, func() error {
+		session = models.Session{}
+
+		tx, err := s.Database.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		var (
+			usedAt  sql.NullTime
+			expired bool
+			current models.Session
+		)
+		err = tx.QueryRowContext(ctx, `
+		SELECT s.id, s.user_id, s.created_at, s.expires_at, s.revoked_at, t.used_at, s.expires_at <= now()
+		FROM session_refresh_tokens t
+		JOIN user_sessions s ON s.id = t.session_id
+		WHERE t.token_hash = $1
+		FOR UPDATE;
+		`, oldHash).Scan(&current.ID, &current.UserID, &current.CreatedAt, &current.ExpiresAt, &current.RevokedAt, &usedAt, &expired)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.MakeError(fmt.Errorf("%s unknown refresh token", op), domain.ErrInvalidToken)
+		}
+		if err != nil {
+			return err
+		}
+
+		if current.RevokedAt != nil || expired {
+			return domain.MakeError(fmt.Errorf("%s session %s is closed", op, current.ID), domain.ErrInvalidToken)
+		}
+
+		if usedAt.Valid {
+			err = tx.QueryRowContext(ctx, `
+			UPDATE user_sessions SET revoked_at = now()
+			WHERE id = $1
+			RETURNING revoked_at;
+			`, current.ID).Scan(&current.RevokedAt)
+			if err != nil {
+				return err
+			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			session = current
+			return domain.MakeError(fmt.Errorf("%s refresh token reused, session %s revoked", op, current.ID), domain.ErrInvalidToken)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		UPDATE session_refresh_tokens SET used_at = now()
+		WHERE token_hash = $1;
+		`, oldHash)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO session_refresh_tokens (token_hash, session_id)
+		VALUES ($1, $2);
+		`, newHash, current.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		UPDATE user_sessions SET expires_at = $2
+		WHERE id = $1;
+		`, current.ID, expiresAt)
+		if err != nil {
+			return err
+		}
+		current.ExpiresAt = expiresAt
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		session = current
+		return nil
+	})
+	if err != nil {
+		return session, translateTx(op, err)
+	}
+
+	return session, nil
+}
+
+// RevokeSession отзывает сессию. Повторный отзыв ничего не меняет.
+func (s *PostgresStorage) RevokeSession(ctx context.Context, sessionID string) error {
+	err := retryWrapper(ctx, func() error {
+		_, err := s.Database.ExecContext(ctx, `
+		UPDATE user_sessions SET revoked_at = now()
+		WHERE id = $1 AND revoked_at IS NULL;
+		`, sessionID)
+		return err
+	})
+	if err != nil {
+		return translate("postgresql.RevokeSession", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) ListRevokedSessions(ctx context.Context, since time.Time) ([]string, error) {
+	ids := make([]string, 0)
+
+	err := retryWrapper(ctx, func() error {
+		ids = ids[:0]
+
+		rows, err := s.Database.QueryContext(ctx, `
+		SELECT id FROM user_sessions
+		WHERE revoked_at >= $1;
+		`, since)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return []string{}, translate("postgresql.ListRevokedSessions", err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,66 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+)
+
+// StreamBalanceEntries передаёт в opening баланс на начало from, затем отдаёт
+// записи журнала за [from, to) по одной, не загружая их в память. Баланс и записи
+// читаются в одном снимке, поэтому проведённая параллельно запись не разъедется
+// с итогом. Без ретраев: часть строк к моменту ошибки уже могла уйти клиенту.
+func (s *PostgresStorage) StreamBalanceEntries(ctx context.Context, userID uint64, from, to time.Time, opening func(money.Amount) error, fn func(models.BalanceEntry) error) error {
+	tx, err := s.Database.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return translate("postgresql.StreamBalanceEntries", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var balance money.Amount
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(CASE WHEN entry_type = 'withdrawal' THEN -amount_points ELSE amount_points END), 0)
+		FROM user_balance_entries
+		WHERE user_id = $1 AND posted_at < $2;
+		`, userID, from).Scan(&balance)
+	if err != nil {
+		return translate("postgresql.StreamBalanceEntries", err)
+	}
+	if err := opening(balance); err != nil {
+		return translateTx("postgresql.StreamBalanceEntries", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+balanceEntryColumns+`
+		FROM user_balance_entries e
+		LEFT JOIN user_orders uo ON uo.id = e.order_id
+		WHERE e.user_id = $1 AND e.posted_at >= $2 AND e.posted_at < $3
+		ORDER BY e.posted_at, e.id`,
+		userID, from, to,
+	)
+	if err != nil {
+		return translate("postgresql.StreamBalanceEntries", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanBalanceEntry(rows)
+		if err != nil {
+			return translate("postgresql.StreamBalanceEntries", err)
+		}
+		if err := fn(e); err != nil {
+			return translateTx("postgresql.StreamBalanceEntries", err)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return translate("postgresql.StreamBalanceEntries", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,62 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testAPIKeys(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+	other := newUser(t, repo)
+
+	hash := "hash_" + unique()
+	created, err := repo.CreateAPIKey(ctx, models.APIKey{
+		UserID:    user.ID,
+		Name:      "shop",
+		Prefix:    "gm_abc",
+		Scopes:    []string{models.ScopeOrdersRead, models.ScopeOrdersWrite},
+		CreatedBy: user.ID,
+	}, hash)
+	require.NoError(t, err)
+	require.NotZero(t, created.ID)
+	require.False(t, created.CreatedAt.IsZero())
+
+	used, err := repo.UseAPIKey(ctx, hash)
+	require.NoError(t, err)
+	require.Equal(t, created.ID, used.ID)
+	require.Equal(t, user.ID, used.UserID)
+	require.Equal(t, []string{models.ScopeOrdersRead, models.ScopeOrdersWrite}, used.Scopes)
+	require.NotNil(t, used.LastUsedAt)
+
+	_, err = repo.UseAPIKey(ctx, "hash_"+unique())
+	require.ErrorIs(t, err, domain.ErrInvalidToken)
+
+	keys, err := repo.ListAPIKeys(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.Equal(t, "shop", keys[0].Name)
+	require.Equal(t, "gm_abc", keys[0].Prefix)
+
+	keys, err = repo.ListAPIKeys(ctx, other.ID)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	// чужой ключ не отзывается
+	require.ErrorIs(t, repo.RevokeAPIKey(ctx, other.ID, created.ID), domain.ErrAPIKeyNotFound)
+
+	require.NoError(t, repo.RevokeAPIKey(ctx, user.ID, created.ID))
+	require.NoError(t, repo.RevokeAPIKey(ctx, user.ID, created.ID))
+
+	_, err = repo.UseAPIKey(ctx, hash)
+	require.ErrorIs(t, err, domain.ErrInvalidToken)
+
+	keys, err = repo.ListAPIKeys(ctx, user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, keys[0].RevokedAt)
+}
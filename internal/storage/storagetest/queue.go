@@ -0,0 +1,277 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/job"
+	"yandex-diplom/internal/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+// QueueFactory возвращает очередь для одного подтеста.
+type QueueFactory func(t *testing.T) job.Queue
+
+// RunQueue прогоняет контракт job.Queue. Каждый подтест работает со своим
+// видом заданий, поэтому чужие записи в общей базе не мешают.
+func RunQueue(t *testing.T, newQueue QueueFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, q job.Queue)
+	}{
+		{"Dedup", testQueueDedup},
+		{"LeaseConcurrent", testQueueLeaseConcurrent},
+		{"AckNack", testQueueAckNack},
+		{"Visibility", testQueueVisibility},
+		{"EnqueueNewOrders", testQueueEnqueueNewOrders},
+		{"Cancel", testQueueCancel},
+		{"Count", testQueueCount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newQueue(t))
+		})
+	}
+}
+
+func testQueueDedup(t *testing.T, q job.Queue) {
+	ctx := context.Background()
+	kind := "kind_" + unique()
+
+	require.NoError(t, q.EnqueueTasks(ctx,
+		models.Task{Kind: kind, Key: kind + ":a", Payload: []byte(`{"n":1}`)},
+		models.Task{Kind: kind, Key: kind + ":a", Payload: []byte(`{"n":2}`)},
+		models.Task{Kind: kind},
+		models.Task{Kind: kind},
+	))
+
+	tasks, err := q.LeaseTasks(ctx, []string{kind}, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, tasks, 3)
+	require.JSONEq(t, `{"n":1}`, string(tasks[0].Payload))
+	require.Equal(t, 1, tasks[0].Attempts)
+
+	// пока задание в очереди, ключ занят
+	require.NoError(t, q.EnqueueTasks(ctx, models.Task{Kind: kind, Key: kind + ":a"}))
+	require.NoError(t, q.AckTask(ctx, tasks[0]))
+
+	// после подтверждения ключ освобождается
+	require.NoError(t, q.EnqueueTasks(ctx, models.Task{Kind: kind, Key: kind + ":a"}))
+	tasks, err = q.LeaseTasks(ctx, []string{kind}, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, kind+":a", tasks[0].Key)
+}
+
+func testQueueLeaseConcurrent(t *testing.T, q job.Queue) {
+	ctx := context.Background()
+	kind := "kind_" + unique()
+
+	const total = 40
+	tasks := make([]models.Task, total)
+	for i := range tasks {
+		tasks[i] = models.Task{Kind: kind}
+	}
+	require.NoError(t, q.EnqueueTasks(ctx, tasks...))
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[int64]int)
+		wg   sync.WaitGroup
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				leased, err := q.LeaseTasks(ctx, []string{kind}, 3, time.Minute)
+				if err != nil || len(leased) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, task := range leased {
+					seen[task.ID]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, seen, total)
+	for id, n := range seen {
+		require.Equal(t, 1, n, "task %d leased twice", id)
+	}
+}
+
+func testQueueAckNack(t *testing.T, q job.Queue) {
+	ctx := context.Background()
+	kind := "kind_" + unique()
+
+	require.NoError(t, q.EnqueueTasks(ctx, models.Task{Kind: kind, Key: kind}))
+	tasks, err := q.LeaseTasks(ctx, []string{kind}, 1, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	// отложенное задание не выдаётся до retryAt
+	require.NoError(t, q.NackTask(ctx, tasks[0], time.Now().Add(time.Hour), "later"))
+	later, err := q.LeaseTasks(ctx, []string{kind}, 1, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, later)
+
+	require.NoError(t, q.NackTask(ctx, tasks[0], time.Now().Add(-time.Second), "boom"))
+	retried, err := q.LeaseTasks(ctx, []string{kind}, 1, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, retried, 1)
+	require.Equal(t, 2, retried[0].Attempts)
+	require.Equal(t, "boom", retried[0].LastError)
+
+	require.NoError(t, q.AckTask(ctx, retried[0]))
+	require.NoError(t, q.NackTask(ctx, retried[0], time.Now().Add(-time.Second), "gone"))
+	empty, err := q.LeaseTasks(ctx, []string{kind}, 1, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, empty)
+}
+
+func testQueueVisibility(t *testing.T, q job.Queue) {
+	ctx := context.Background()
+	kind := "kind_" + unique()
+
+	require.NoError(t, q.EnqueueTasks(ctx, models.Task{Kind: kind}))
+	first, err := q.LeaseTasks(ctx, []string{kind}, 1, time.Second)
+	require.NoError(t, err)
+	require.Len(t, first, 1)
+
+	hidden, err := q.LeaseTasks(ctx, []string{kind}, 1, time.Second)
+	require.NoError(t, err)
+	require.Empty(t, hidden)
+
+	// после истечения аренды задание выдаётся снова, а подтверждение прежнего держателя игнорируется
+	var second []models.Task
+	require.Eventually(t, func() bool {
+		second, err = q.LeaseTasks(ctx, []string{kind}, 1, time.Minute)
+		require.NoError(t, err)
+		return len(second) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Equal(t, first[0].ID, second[0].ID)
+
+	require.NoError(t, q.AckTask(ctx, first[0]))
+	require.NoError(t, q.NackTask(ctx, second[0], time.Now().Add(-time.Second), "retry"))
+	again, err := q.LeaseTasks(ctx, []string{kind}, 1, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, again, 1)
+	require.NoError(t, q.AckTask(ctx, again[0]))
+}
+
+// testQueueEnqueueNewOrders заказ уходит в PROCESSING только вместе с заданием:
+// если задание не поставлено, заказ остаётся в NEW.
+func testQueueEnqueueNewOrders(t *testing.T, q job.Queue) {
+	repo, ok := q.(gophermart.Reposiroty)
+	if !ok {
+		t.Skip("queue is not backed by a repository")
+	}
+
+	ctx := context.Background()
+	kind := "kind_" + unique()
+	user := newUser(t, repo)
+	number := unique()
+	require.NoError(t, repo.CreateOrder(ctx, user.Login, models.Order{Number: number}))
+
+	// лимит с запасом, чтобы заказ попал в пачку и при чужих NEW в общей базе
+	const limit = 10000
+	status := func() string {
+		orders, err := repo.GetOrders(ctx, user.Login)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		return orders[0].Status
+	}
+
+	fail := errors.New("enqueue failed")
+	seen := false
+	_, err := q.EnqueueNewOrders(ctx, limit, func(o models.Order) (models.Task, error) {
+		if o.Number == number {
+			seen = true
+			return models.Task{}, fail
+		}
+		return models.Task{Kind: kind, Key: kind + ":" + o.Number}, nil
+	})
+	require.Error(t, err)
+	require.True(t, seen)
+	require.Equal(t, models.OrderNew, status())
+
+	tasks, err := q.LeaseTasks(ctx, []string{kind}, limit, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, tasks)
+
+	orders, err := q.EnqueueNewOrders(ctx, limit, func(o models.Order) (models.Task, error) {
+		return models.Task{Kind: kind, Key: kind + ":" + o.Number}, nil
+	})
+	require.NoError(t, err)
+	claimed := false
+	for _, o := range orders {
+		if o.Number == number {
+			claimed = true
+			require.Equal(t, models.OrderProcessing, o.Status)
+			require.Equal(t, user.ID, o.UserID)
+		}
+	}
+	require.True(t, claimed)
+	require.Equal(t, models.OrderProcessing, status())
+
+	tasks, err = q.LeaseTasks(ctx, []string{kind}, limit, time.Minute)
+	require.NoError(t, err)
+	keys := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		keys = append(keys, task.Key)
+	}
+	require.Contains(t, keys, kind+":"+number)
+}
+
+func testQueueCancel(t *testing.T, q job.Queue) {
+	ctx := context.Background()
+	kind := "kind_" + unique()
+
+	require.NoError(t, q.EnqueueTasks(ctx,
+		models.Task{Kind: kind, Key: kind + ":leased"},
+		models.Task{Kind: kind, Key: kind + ":pending"},
+	))
+	leased, err := q.LeaseTasks(ctx, []string{kind}, 1, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, leased, 1)
+	require.Equal(t, kind+":leased", leased[0].Key)
+
+	// выданное задание не отменяется
+	cancelled, err := q.CancelTasks(ctx, []string{kind + ":leased", kind + ":pending", kind + ":missing"})
+	require.NoError(t, err)
+	require.Equal(t, []string{kind + ":pending"}, cancelled)
+
+	rest, err := q.LeaseTasks(ctx, []string{kind}, 10, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+	require.NoError(t, q.AckTask(ctx, leased[0]))
+}
+
+func testQueueCount(t *testing.T, q job.Queue) {
+	ctx := context.Background()
+	kind := "kind_" + unique()
+
+	require.NoError(t, q.EnqueueTasks(ctx, models.Task{Kind: kind}, models.Task{Kind: kind}, models.Task{Kind: kind}))
+	count, err := q.CountTasks(ctx, []string{kind})
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	// выданные и отложенные задания не ждут воркера
+	leased, err := q.LeaseTasks(ctx, []string{kind}, 2, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, leased, 2)
+	require.NoError(t, q.NackTask(ctx, leased[0], time.Now().Add(time.Hour), "later"))
+
+	count, err = q.CountTasks(ctx, []string{kind})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
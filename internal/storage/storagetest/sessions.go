@@ -0,0 +1,88 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newSession(t *testing.T, repo gophermart.Reposiroty, user models.User, refreshHash string) models.Session {
+	t.Helper()
+
+	session := models.Session{ID: "sid_" + unique(), UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, repo.CreateSession(context.Background(), session, refreshHash))
+	return session
+}
+
+func testSessions(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+	since := time.Now().Add(-time.Minute)
+
+	first, second := "hash_"+unique(), "hash_"+unique()
+	session := newSession(t, repo, user, first)
+
+	expires := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	got, err := repo.RotateRefreshToken(ctx, first, second, expires)
+	require.NoError(t, err)
+	require.Equal(t, session.ID, got.ID)
+	require.Equal(t, user.ID, got.UserID)
+	require.True(t, got.ExpiresAt.Equal(expires))
+	require.Nil(t, got.RevokedAt)
+
+	_, err = repo.RotateRefreshToken(ctx, "hash_"+unique(), "hash_"+unique(), expires)
+	require.ErrorIs(t, err, domain.ErrInvalidToken)
+
+	require.NoError(t, repo.RevokeSession(ctx, session.ID))
+	require.NoError(t, repo.RevokeSession(ctx, session.ID))
+
+	// отозванная сессия больше не обновляется
+	_, err = repo.RotateRefreshToken(ctx, second, "hash_"+unique(), expires)
+	require.ErrorIs(t, err, domain.ErrInvalidToken)
+
+	revoked, err := repo.ListRevokedSessions(ctx, since)
+	require.NoError(t, err)
+	require.Contains(t, revoked, session.ID)
+
+	revoked, err = repo.ListRevokedSessions(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.NotContains(t, revoked, session.ID)
+}
+
+func testRefreshTokenReuse(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+	since := time.Now().Add(-time.Minute)
+
+	first, second := "hash_"+unique(), "hash_"+unique()
+	session := newSession(t, repo, user, first)
+	expires := time.Now().Add(time.Hour)
+
+	_, err := repo.RotateRefreshToken(ctx, first, second, expires)
+	require.NoError(t, err)
+
+	// повторное предъявление уже обменянного токена отзывает сессию целиком
+	got, err := repo.RotateRefreshToken(ctx, first, "hash_"+unique(), expires)
+	require.ErrorIs(t, err, domain.ErrInvalidToken)
+	require.Equal(t, session.ID, got.ID)
+	require.NotNil(t, got.RevokedAt)
+
+	_, err = repo.RotateRefreshToken(ctx, second, "hash_"+unique(), expires)
+	require.ErrorIs(t, err, domain.ErrInvalidToken)
+
+	revoked, err := repo.ListRevokedSessions(ctx, since)
+	require.NoError(t, err)
+	require.Contains(t, revoked, session.ID)
+
+	// истёкшая сессия не обновляется
+	expired := models.Session{ID: "sid_" + unique(), UserID: user.ID, ExpiresAt: time.Now().Add(-time.Second)}
+	stale := "hash_" + unique()
+	require.NoError(t, repo.CreateSession(ctx, expired, stale))
+	_, err = repo.RotateRefreshToken(ctx, stale, "hash_"+unique(), expires)
+	require.ErrorIs(t, err, domain.ErrInvalidToken)
+}
@@ -0,0 +1,741 @@
+// Package storagetest набор контрактных тестов для реализаций gophermart.Reposiroty.
+// Тесты не рассчитывают на пустое хранилище: логины и номера заказов уникальны
+// для каждого запуска, поэтому набор можно гонять на общей тестовой базе.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Factory возвращает хранилище для одного подтеста.
+type Factory func(t *testing.T) gophermart.Reposiroty
+
+// Run прогоняет контракт Reposiroty против хранилищ из newRepo.
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo gophermart.Reposiroty)
+	}{
+		{"Users", testUsers},
+		{"Roles", testRoles},
+		{"CheckOrder", testCheckOrder},
+		{"OrdersList", testOrdersList},
+		{"FetchNewOrdersConcurrent", testFetchNewOrdersConcurrent},
+		{"FetchProccesingOrders", testFetchProccesingOrders},
+		{"FinalizeOrder", testFinalizeOrder},
+		{"OrderStatusChanges", testOrderStatusChanges},
+		{"OrderRetries", testOrderRetries},
+		{"DeadLetterFinalized", testDeadLetterFinalized},
+		{"ReleaseOrders", testReleaseOrders},
+		{"ClaimOrder", testClaimOrder},
+		{"WithdrawalConcurrent", testWithdrawalConcurrent},
+		{"AdjustAndReverse", testAdjustAndReverse},
+		{"Ledger", testLedger},
+		{"Reconcile", testReconcile},
+		{"Idempotency", testIdempotency},
+		{"Sessions", testSessions},
+		{"RefreshTokenReuse", testRefreshTokenReuse},
+		{"APIKeys", testAPIKeys},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+var seq atomic.Int64
+
+// unique строка из цифр, не повторяющаяся между запусками.
+func unique() string {
+	return fmt.Sprintf("%d%04d", time.Now().UnixNano(), seq.Add(1)%10000)
+}
+
+func newUser(t *testing.T, repo gophermart.Reposiroty) models.User {
+	t.Helper()
+	ctx := context.Background()
+
+	login := "user_" + unique()
+	require.NoError(t, repo.RegisterUser(ctx, models.User{Login: login, Password: "password"}))
+
+	user, err := repo.GetUserByLogin(ctx, login)
+	require.NoError(t, err)
+	return user
+}
+
+func seed(t *testing.T, repo gophermart.Reposiroty, user models.User, amount money.Amount) {
+	t.Helper()
+
+	_, err := repo.AdjustBalance(context.Background(), models.Adjustment{UserID: user.ID, Amount: amount, Reason: "seed"})
+	require.NoError(t, err)
+}
+
+// claim забирает заказ number в обработку, попутно забирая чужие NEW заказы из общего хранилища.
+func claim(t *testing.T, repo gophermart.Reposiroty, number string) models.Order {
+	t.Helper()
+
+	for {
+		orders, err := repo.FetchNewOrders(context.Background(), 100)
+		require.NoError(t, err)
+		require.NotEmpty(t, orders, "order %s was not fetched", number)
+
+		for _, o := range orders {
+			if o.Number == number {
+				return o
+			}
+		}
+	}
+}
+
+// fetchProcessing забирает повторно опрашиваемые заказы, пока не встретит number.
+func fetchProcessing(t *testing.T, repo gophermart.Reposiroty, number string) (models.Order, bool) {
+	t.Helper()
+
+	for {
+		orders, err := repo.FetchProccesingOrders(context.Background(), 100)
+		require.NoError(t, err)
+		if len(orders) == 0 {
+			return models.Order{}, false
+		}
+
+		for _, o := range orders {
+			if o.Number == number {
+				return o, true
+			}
+		}
+	}
+}
+
+func testUsers(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+
+	err := repo.RegisterUser(ctx, models.User{Login: user.Login, Password: "other"})
+	require.ErrorIs(t, err, domain.ErrLoginAlreadyTaken)
+
+	exist, err := repo.CheckUser(ctx, user.Login)
+	require.NoError(t, err)
+	require.True(t, exist)
+
+	exist, err = repo.CheckUser(ctx, user.Login+"_missing")
+	require.NoError(t, err)
+	require.False(t, exist)
+
+	byID, err := repo.GetUserByID(ctx, int64(user.ID))
+	require.NoError(t, err)
+	require.Equal(t, user, byID)
+	require.Equal(t, models.RoleUser, user.Role)
+
+	_, err = repo.GetUserByLogin(ctx, user.Login+"_missing")
+	require.ErrorIs(t, err, domain.ErrUserNotFound)
+
+	require.NoError(t, repo.ValidateUser(ctx, models.User{Login: user.Login, Password: "password"}))
+	require.ErrorIs(t, repo.ValidateUser(ctx, models.User{Login: user.Login, Password: "wrong"}), domain.ErrInvalidCredentials)
+	require.ErrorIs(t, repo.ValidateUser(ctx, models.User{Login: user.Login + "_missing", Password: "password"}), domain.ErrInvalidCredentials)
+}
+
+func testRoles(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+
+	require.NoError(t, repo.SetUserRole(ctx, user.Login, models.RoleAdmin))
+	got, err := repo.GetUserByID(ctx, int64(user.ID))
+	require.NoError(t, err)
+	require.Equal(t, models.RoleAdmin, got.Role)
+
+	require.NoError(t, repo.SetUserRole(ctx, user.Login, models.RoleUser))
+	got, err = repo.GetUserByLogin(ctx, user.Login)
+	require.NoError(t, err)
+	require.Equal(t, models.RoleUser, got.Role)
+
+	require.ErrorIs(t, repo.SetUserRole(ctx, user.Login+"_missing", models.RoleAdmin), domain.ErrUserNotFound)
+}
+
+func testCheckOrder(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	owner := newUser(t, repo)
+	other := newUser(t, repo)
+	order := models.Order{Number: unique()}
+
+	require.NoError(t, repo.CheckOrder(ctx, owner.Login, order))
+	require.NoError(t, repo.CreateOrder(ctx, owner.Login, order))
+
+	require.ErrorIs(t, repo.CheckOrder(ctx, owner.Login, order), domain.ErrOrderCreatedByUser)
+	require.ErrorIs(t, repo.CheckOrder(ctx, other.Login, order), domain.ErrOrderCreatedByOtherUser)
+	require.ErrorIs(t, repo.CreateOrder(ctx, owner.Login, order), domain.ErrOrderCreatedByUser)
+
+	err := repo.CreateOrder(ctx, owner.Login+"_missing", models.Order{Number: unique()})
+	require.ErrorIs(t, err, domain.ErrUserNotFound)
+}
+
+func testOrdersList(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+
+	empty, err := repo.GetOrders(ctx, user.Login)
+	require.NoError(t, err)
+	require.Empty(t, empty)
+
+	numbers := make([]string, 5)
+	for i := range numbers {
+		numbers[i] = unique()
+		require.NoError(t, repo.CreateOrder(ctx, user.Login, models.Order{Number: numbers[i]}))
+	}
+	require.NoError(t, repo.InvalidateOrder(ctx, numbers[0]))
+
+	all, err := repo.GetOrders(ctx, user.Login)
+	require.NoError(t, err)
+	require.Len(t, all, 5)
+	require.Equal(t, numbers[4], all[0].Number)
+	require.Equal(t, models.OrderNew, all[0].Status)
+	require.Equal(t, models.OrderInvalid, all[4].Status)
+
+	var (
+		paged []models.Order
+		after *models.Cursor
+	)
+	for {
+		page, err := repo.GetOrdersPage(ctx, user.Login, models.OrderFilter{Limit: 2, After: after})
+		require.NoError(t, err)
+		paged = append(paged, page.Orders...)
+		if page.Next == nil {
+			break
+		}
+		after = page.Next
+	}
+	require.Equal(t, all, paged)
+
+	page, err := repo.GetOrdersPage(ctx, user.Login, models.OrderFilter{Limit: 10, Statuses: []string{models.OrderInvalid}})
+	require.NoError(t, err)
+	require.Len(t, page.Orders, 1)
+	require.Nil(t, page.Next)
+
+	future := time.Now().Add(time.Hour)
+	page, err = repo.GetOrdersPage(ctx, user.Login, models.OrderFilter{Limit: 10, From: &future})
+	require.NoError(t, err)
+	require.Empty(t, page.Orders)
+}
+
+func testFetchNewOrdersConcurrent(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+
+	const total = 30
+	ours := make(map[string]int, total)
+	for range total {
+		number := unique()
+		ours[number] = 0
+		require.NoError(t, repo.CreateOrder(ctx, user.Login, models.Order{Number: number}))
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				orders, err := repo.FetchNewOrders(ctx, 3)
+				if err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+					return
+				}
+				if len(orders) == 0 {
+					return
+				}
+
+				mu.Lock()
+				for _, o := range orders {
+					if _, ok := ours[o.Number]; ok {
+						ours[o.Number]++
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Empty(t, errs)
+	for number, seen := range ours {
+		require.Equal(t, 1, seen, "order %s fetched %d times", number, seen)
+	}
+
+	orders, err := repo.GetOrders(ctx, user.Login)
+	require.NoError(t, err)
+	for _, o := range orders {
+		require.Equal(t, models.OrderProcessing, o.Status)
+	}
+}
+
+func testFetchProccesingOrders(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+
+	number := unique()
+	require.NoError(t, repo.CreateOrder(ctx, user.Login, models.Order{Number: number}))
+	claim(t, repo, number)
+
+	// только что взятый заказ не отдаётся повторно до истечения таймаута обработки
+	orders, err := repo.FetchProccesingOrders(ctx, 100)
+	require.NoError(t, err)
+	for _, o := range orders {
+		require.NotEqual(t, number, o.Number)
+	}
+}
+
+func testFinalizeOrder(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+	seed(t, repo, user, money.FromInt(1))
+
+	number := unique()
+	require.NoError(t, repo.CreateOrder(ctx, user.Login, models.Order{Number: number}))
+	order := claim(t, repo, number)
+	require.Equal(t, user.ID, order.UserID)
+
+	require.NoError(t, repo.FinalizeOrder(ctx, order, money.FromCents(1050)))
+	require.NoError(t, repo.FinalizeOrder(ctx, order, money.FromCents(1050)))
+	require.NoError(t, repo.UpdateMissingBalanceEntries(ctx))
+
+	balance, err := repo.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, money.FromCents(1150), balance.Current)
+
+	orders, err := repo.GetOrders(ctx, user.Login)
+	require.NoError(t, err)
+	require.Equal(t, models.OrderProcessed, orders[0].Status)
+	require.Equal(t, money.FromCents(1050), orders[0].Accrual)
+
+	entries, err := repo.GetBalanceEntries(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, models.EntryAccrual, entries[0].Type)
+	require.Equal(t, number, entries[0].Order)
+
+	// NEW заказ нельзя завершить, минуя обработку
+	fresh := unique()
+	require.NoError(t, repo.CreateOrder(ctx, user.Login, models.Order{Number: fresh}))
+	require.NoError(t, repo.FinalizeOrder(ctx, models.Order{UserID: user.ID, Number: fresh}, money.FromInt(5)))
+
+	balance, err = repo.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, money.FromCents(1150), balance.Current)
+}
+
+func testOrderStatusChanges(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+
+	number := unique()
+	require.NoError(t, repo.CreateOrder(ctx, user.Login, models.Order{Number: number}))
+
+	// INVALID выставляется только из PROCESSING
+	require.NoError(t, repo.UpdateOrderInvalid(ctx, number))
+	orders, err := repo.GetOrders(ctx, user.Login)
+	require.NoError(t, err)
+	require.Equal(t, models.OrderNew, orders[0].Status)
+
+	claim(t, repo, number)
+	require.NoError(t, repo.UpdateOrderInvalid(ctx, number))
+	require.NoError(t, repo.ResetOrder(ctx, number))
+
+	orders, err = repo.GetOrders(ctx, user.Login)
+	require.NoError(t, err)
+	require.Equal(t, models.OrderNew, orders[0].Status)
+
+	order := claim(t, repo, number)
+	require.NoError(t, repo.FinalizeOrder(ctx, order, 0))
+	require.ErrorIs(t, repo.ResetOrder(ctx, number), domain.ErrOrderFinalized)
+	require.ErrorIs(t, repo.InvalidateOrder(ctx, number), domain.ErrOrderFinalized)
+	require.ErrorIs(t, repo.ResetOrder(ctx, unique()), domain.ErrOrderNotFound)
+}
+
+func testOrderRetries(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+
+	number := unique()
+	require.NoError(t, repo.CreateOrder(ctx, user.Login, models.Order{Number: number}))
+	require.Zero(t, claim(t, repo, number).Attempts)
+
+	// заказ снова опрашивается, когда наступает next_attempt_at
+	require.NoError(t, repo.ScheduleOrderRetry(ctx, number, time.Now().Add(-time.Second), "accrual status REGISTERED"))
+	order, ok := fetchProcessing(t, repo, number)
+	require.True(t, ok)
+	require.Equal(t, 1, order.Attempts)
+	require.Equal(t, user.ID, order.UserID)
+
+	// выданный заказ закреплён за воркером
+	_, ok = fetchProcessing(t, repo, number)
+	require.False(t, ok)
+
+	require.NoError(t, repo.ScheduleOrderRetry(ctx, number, time.Now().Add(time.Hour), "accrual status PROCESSING"))
+	_, ok = fetchProcessing(t, repo, number)
+	require.False(t, ok)
+
+	require.NoError(t, repo.DeadLetterOrder(ctx, number, "order is not registered in accrual"))
+	require.NoError(t, repo.ScheduleOrderRetry(ctx, number, time.Now().Add(-time.Second), "ignored"))
+	_, ok = fetchProcessing(t, repo, number)
+	require.False(t, ok)
+
+	dead, err := repo.GetDeadLetterOrders(ctx, 1000)
+	require.NoError(t, err)
+	var found *models.DeadLetterOrder
+	for i := range dead {
+		if dead[i].Number == number {
+			found = &dead[i]
+		}
+	}
+	require.NotNil(t, found)
+	require.Equal(t, user.Login, found.Login)
+	require.Equal(t, 3, found.Attempts)
+	require.Equal(t, "order is not registered in accrual", found.LastError)
+	require.NotNil(t, found.DeadLetteredAt)
+
+	orders, err := repo.GetOrders(ctx, user.Login)
+	require.NoError(t, err)
+	require.Equal(t, models.OrderProcessing, orders[0].Status)
+
+	// сброс администратором возвращает заказ в опрос с чистым счётчиком
+	require.NoError(t, repo.ResetOrder(ctx, number))
+	dead, err = repo.GetDeadLetterOrders(ctx, 1000)
+	require.NoError(t, err)
+	for _, o := range dead {
+		require.NotEqual(t, number, o.Number)
+	}
+	require.Zero(t, claim(t, repo, number).Attempts)
+}
+
+// testDeadLetterFinalized заказ из dead letter, завершённый обратным вызовом
+// или признанный недействительным, пропадает из списка.
+func testDeadLetterFinalized(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+
+	processed, invalid := unique(), unique()
+	claimed := make(map[string]models.Order)
+	for _, number := range []string{processed, invalid} {
+		require.NoError(t, repo.CreateOrder(ctx, user.Login, models.Order{Number: number}))
+		claimed[number] = claim(t, repo, number)
+		require.NoError(t, repo.DeadLetterOrder(ctx, number, "accrual unavailable"))
+	}
+
+	require.NoError(t, repo.FinalizeOrder(ctx, claimed[processed], money.FromInt(5)))
+	require.NoError(t, repo.UpdateOrderInvalid(ctx, invalid))
+
+	dead, err := repo.GetDeadLetterOrders(ctx, 1000)
+	require.NoError(t, err)
+	for _, o := range dead {
+		require.NotEqual(t, processed, o.Number)
+		require.NotEqual(t, invalid, o.Number)
+	}
+}
+
+func testReleaseOrders(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+
+	released, dead := unique(), unique()
+	for _, number := range []string{released, dead} {
+		require.NoError(t, repo.CreateOrder(ctx, user.Login, models.Order{Number: number}))
+		claim(t, repo, number)
+	}
+	require.NoError(t, repo.DeadLetterOrder(ctx, dead, "gone"))
+
+	require.NoError(t, repo.ReleaseOrders(ctx, []string{released, dead}))
+
+	statuses := make(map[string]string)
+	orders, err := repo.GetOrders(ctx, user.Login)
+	require.NoError(t, err)
+	for _, o := range orders {
+		statuses[o.Number] = o.Status
+	}
+	require.Equal(t, models.OrderNew, statuses[released])
+	require.Equal(t, models.OrderProcessing, statuses[dead])
+
+	// возвращённый заказ снова выдаётся из NEW
+	claim(t, repo, released)
+}
+
+func testClaimOrder(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+
+	number := unique()
+	require.NoError(t, repo.CreateOrder(ctx, user.Login, models.Order{Number: number}))
+
+	order, err := repo.ClaimOrder(ctx, number)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, order.UserID)
+	require.Equal(t, models.OrderProcessing, order.Status)
+
+	// заказ в обработке отдаётся повторно, в NEW не попадает
+	_, err = repo.ClaimOrder(ctx, number)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.FinalizeOrder(ctx, order, money.FromInt(5)))
+	_, err = repo.ClaimOrder(ctx, number)
+	require.ErrorIs(t, err, domain.ErrOrderFinalized)
+
+	_, err = repo.ClaimOrder(ctx, unique())
+	require.ErrorIs(t, err, domain.ErrOrderNotFound)
+}
+
+func testWithdrawalConcurrent(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+	seed(t, repo, user, money.FromInt(55))
+
+	const workers = 20
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		ok      int
+		payment int
+		other   []error
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.UpdateWithdrawlEntries(ctx, user.ID, models.Withdrawal{Order: unique(), Sum: money.FromInt(10)})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				ok++
+			case errors.Is(err, domain.ErrPaymentRequired):
+				payment++
+			default:
+				other = append(other, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Empty(t, other)
+	require.Equal(t, 5, ok)
+	require.Equal(t, workers-5, payment)
+
+	balance, err := repo.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, money.FromInt(5), balance.Current)
+	require.Equal(t, money.FromInt(50), balance.Withdrawn)
+
+	withdrawals, err := repo.GetWithdrawls(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, withdrawals, 5)
+
+	err = repo.UpdateWithdrawlEntries(ctx, user.ID, models.Withdrawal{Order: withdrawals[0].Order, Sum: money.FromInt(1)})
+	require.ErrorIs(t, err, domain.ErrOrderAlreadyExists)
+}
+
+func testAdjustAndReverse(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+	seed(t, repo, user, money.FromInt(20))
+
+	_, err := repo.AdjustBalance(ctx, models.Adjustment{UserID: user.ID, Amount: money.FromInt(-21), Reason: "too much"})
+	require.ErrorIs(t, err, domain.ErrPaymentRequired)
+
+	ref := unique()
+	require.NoError(t, repo.UpdateWithdrawlEntries(ctx, user.ID, models.Withdrawal{Order: ref, Sum: money.FromInt(15)}))
+
+	entries, err := repo.GetBalanceEntries(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	withdrawal := entries[0]
+	require.Equal(t, models.EntryWithdrawal, withdrawal.Type)
+	require.Equal(t, money.FromInt(-15), withdrawal.Amount)
+	require.Equal(t, ref, withdrawal.Reference)
+
+	reversal, err := repo.ReverseBalanceEntry(ctx, models.Reversal{EntryID: withdrawal.ID, Reason: "cancelled", OperatorID: user.ID})
+	require.NoError(t, err)
+	require.Equal(t, models.EntryAdjustment, reversal.Type)
+	require.Equal(t, money.FromInt(15), reversal.Amount)
+	require.Equal(t, withdrawal.ID, reversal.ReversesID)
+	require.Equal(t, user.ID, reversal.OperatorID)
+
+	_, err = repo.ReverseBalanceEntry(ctx, models.Reversal{EntryID: withdrawal.ID, Reason: "twice"})
+	require.ErrorIs(t, err, domain.ErrEntryAlreadyReversed)
+
+	_, err = repo.ReverseBalanceEntry(ctx, models.Reversal{EntryID: reversal.ID, Reason: "reversal of reversal"})
+	require.ErrorIs(t, err, domain.ErrInvalidPayload)
+
+	_, err = repo.ReverseBalanceEntry(ctx, models.Reversal{EntryID: reversal.ID + 1_000_000, Reason: "missing"})
+	require.ErrorIs(t, err, domain.ErrEntryNotFound)
+
+	balance, err := repo.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, money.FromInt(20), balance.Current)
+	require.Equal(t, money.Amount(0), balance.Withdrawn)
+}
+
+func testLedger(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+	from := time.Now().Add(-time.Hour)
+
+	seed(t, repo, user, money.FromInt(30))
+	require.NoError(t, repo.UpdateWithdrawlEntries(ctx, user.ID, models.Withdrawal{Order: unique(), Sum: money.FromInt(10)}))
+	seed(t, repo, user, money.FromCents(250))
+
+	var (
+		got   []models.LedgerEntry
+		after *models.Cursor
+	)
+	for {
+		page, err := repo.GetLedgerPage(ctx, user.ID, models.LedgerFilter{Limit: 2, After: after})
+		require.NoError(t, err)
+		got = append(got, page.Entries...)
+		if page.Next == nil {
+			break
+		}
+		after = page.Next
+	}
+	require.Len(t, got, 3)
+	require.Equal(t, money.FromCents(2250), got[0].RunningBalance)
+	require.Equal(t, money.FromInt(20), got[1].RunningBalance)
+	require.Equal(t, money.FromInt(30), got[2].RunningBalance)
+
+	future := time.Now().Add(time.Hour)
+	page, err := repo.GetLedgerPage(ctx, user.ID, models.LedgerFilter{Limit: 10, From: &future})
+	require.NoError(t, err)
+	require.Empty(t, page.Entries)
+
+	var (
+		opening  money.Amount
+		streamed []models.BalanceEntry
+	)
+	err = repo.StreamBalanceEntries(ctx, user.ID, from, future, func(a money.Amount) error {
+		opening = a
+		return nil
+	}, func(e models.BalanceEntry) error {
+		streamed = append(streamed, e)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, money.Amount(0), opening)
+	require.Len(t, streamed, 3)
+	require.Equal(t, got[2].ID, streamed[0].ID)
+
+	streamed = nil
+	err = repo.StreamBalanceEntries(ctx, user.ID, future, future.Add(time.Hour), func(a money.Amount) error {
+		opening = a
+		return nil
+	}, func(e models.BalanceEntry) error {
+		streamed = append(streamed, e)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, money.FromCents(2250), opening)
+	require.Empty(t, streamed)
+
+	stop := errors.New("stop")
+	noop := func(money.Amount) error { return nil }
+	err = repo.StreamBalanceEntries(ctx, user.ID, from, future, noop, func(models.BalanceEntry) error { return stop })
+	require.ErrorIs(t, err, stop)
+
+	err = repo.StreamBalanceEntries(ctx, user.ID, from, future, func(money.Amount) error { return stop }, func(models.BalanceEntry) error {
+		t.Error("entries must not be streamed after opening fails")
+		return nil
+	})
+	require.ErrorIs(t, err, stop)
+}
+
+func testReconcile(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+	seed(t, repo, user, money.FromInt(10))
+	require.NoError(t, repo.UpdateWithdrawlEntries(ctx, user.ID, models.Withdrawal{Order: unique(), Sum: money.FromInt(4)}))
+
+	checks, err := repo.CheckBalances(ctx, user.ID-1, 1)
+	require.NoError(t, err)
+	require.Len(t, checks, 1)
+	require.Equal(t, user.ID, checks[0].UserID)
+	require.True(t, checks[0].Consistent())
+	require.Equal(t, money.FromInt(6), checks[0].LedgerBalance)
+	require.Equal(t, money.FromInt(4), checks[0].LedgerWithdrawn)
+
+	require.NoError(t, repo.UpdateBalance(ctx, user.ID))
+	balance, err := repo.GetBalance(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.Balance{Current: money.FromInt(6), Withdrawn: money.FromInt(4)}, balance)
+
+	report := models.ReconcileReport{StartedAt: time.Now().UTC().Truncate(time.Second), Checked: 1, Samples: checks}
+	require.NoError(t, repo.SaveReconcileReport(ctx, report))
+	saved, err := repo.GetReconcileReport(ctx)
+	require.NoError(t, err)
+	require.True(t, report.StartedAt.Equal(saved.StartedAt))
+	require.Equal(t, report.Samples, saved.Samples)
+}
+
+func testIdempotency(t *testing.T, repo gophermart.Reposiroty) {
+	ctx := context.Background()
+	user := newUser(t, repo)
+	key := unique()
+
+	_, reserved, err := repo.ReserveIdempotencyKey(ctx, user.ID, key, "hash", time.Hour)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	existing, reserved, err := repo.ReserveIdempotencyKey(ctx, user.ID, key, "other", time.Hour)
+	require.NoError(t, err)
+	require.False(t, reserved)
+	require.Equal(t, "hash", existing.RequestHash)
+	require.False(t, existing.Completed)
+
+	require.NoError(t, repo.CompleteIdempotencyKey(ctx, user.ID, key, models.IdempotentResponse{
+		StatusCode: 202, ContentType: "text/plain", Body: []byte("ok"),
+	}))
+
+	// завершённый ключ не освобождается
+	require.NoError(t, repo.ReleaseIdempotencyKey(ctx, user.ID, key))
+
+	existing, reserved, err = repo.ReserveIdempotencyKey(ctx, user.ID, key, "hash", time.Hour)
+	require.NoError(t, err)
+	require.False(t, reserved)
+	require.True(t, existing.Completed)
+	require.Equal(t, 202, existing.StatusCode)
+	require.Equal(t, []byte("ok"), existing.Body)
+
+	pending := unique()
+	_, reserved, err = repo.ReserveIdempotencyKey(ctx, user.ID, pending, "hash", time.Hour)
+	require.NoError(t, err)
+	require.True(t, reserved)
+	require.NoError(t, repo.ReleaseIdempotencyKey(ctx, user.ID, pending))
+
+	_, reserved, err = repo.ReserveIdempotencyKey(ctx, user.ID, pending, "hash", time.Hour)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	// истёкший ключ можно занять заново
+	expired := unique()
+	_, reserved, err = repo.ReserveIdempotencyKey(ctx, user.ID, expired, "hash", -time.Second)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	_, reserved, err = repo.ReserveIdempotencyKey(ctx, user.ID, expired, "other", time.Hour)
+	require.NoError(t, err)
+	require.True(t, reserved)
+}
@@ -0,0 +1,236 @@
+package httpx
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"yandex-diplom/internal/auth"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/lib"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func GetUserInfo(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		info, err := svc.GetUserInfo(r.Context(), chi.URLParam(r, "login"))
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		if err := responseJSONUserInfo(w, info); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+	}
+}
+
+func GetUserOrders(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		orders, err := svc.GetOrders(r.Context(), chi.URLParam(r, "login"))
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		writeOrders(w, svc, orders)
+	}
+}
+
+func GetUserLedger(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		entries, err := svc.GetUserLedger(r.Context(), chi.URLParam(r, "login"))
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		if len(entries) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if err := responseJSONBalanceEntries(w, entries); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+	}
+}
+
+func GetDeadLetterOrders(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		limit, _, err := bindPageFromQuery(r.URL.Query())
+		if err != nil {
+			svc.WriteError(w, domain.MakeError(lib.StandardError("httpx.GetDeadLetterOrders", err), domain.ErrInvalidPayload))
+			return
+		}
+
+		orders, err := svc.GetDeadLetterOrders(r.Context(), limit)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		if len(orders) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if err := responseJSONDeadLetterOrders(w, orders); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+	}
+}
+
+func ResetOrder(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+
+		if err := svc.ResetOrder(r.Context(), chi.URLParam(r, "number")); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func InvalidateOrder(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+
+		if err := svc.InvalidateOrder(r.Context(), chi.URLParam(r, "number")); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func AdjustBalance(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		operator := auth.GetUserFromContext(r.Context())
+		if operator == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		adj, err := bindAdjustmentFromJSON(r)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+		adj.OperatorID = operator.ID
+
+		entry, err := svc.AdjustBalance(r.Context(), chi.URLParam(r, "login"), adj)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		if err := responseJSONBalanceEntry(w, entry); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+	}
+}
+
+func ReverseBalanceEntry(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "httpx.ReverseBalanceEntry"
+
+		w.Header().Set("Content-Type", "application/json")
+
+		operator := auth.GetUserFromContext(r.Context())
+		if operator == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			svc.WriteError(w, domain.MakeError(lib.StandardError(op, errors.New("invalid entry id")), domain.ErrInvalidPayload))
+			return
+		}
+
+		rev, err := bindReversalFromJSON(r)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+		rev.EntryID = id
+		rev.OperatorID = operator.ID
+
+		entry, err := svc.ReverseBalanceEntry(r.Context(), rev)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		if err := responseJSONBalanceEntry(w, entry); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+	}
+}
+
+func GetReconcileReport(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		report, err := svc.GetReconcileReport(r.Context())
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		if err := responseJSONReconcileReport(w, report); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+	}
+}
+
+// GetWorkerPoolStats отдаёт пул воркеров того экземпляра, который обработал запрос.
+// За балансировщиком это статистика одного инстанса, а не суммарная.
+func GetWorkerPoolStats(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		stats, ok := svc.GetWorkerPoolStats(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if err := responseJSONWorkerPoolStats(w, stats); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+	}
+}
+
+func GetAccrualStats(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := responseJSONAccrualStats(w, svc.GetAccrualStats(r.Context())); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+	}
+}
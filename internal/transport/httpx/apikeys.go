@@ -0,0 +1,129 @@
+package httpx
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"yandex-diplom/internal/auth"
+	"yandex-diplom/internal/domain"
+	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/lib"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+func CreateAPIKey(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := auth.GetUserFromContext(r.Context())
+		if user == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		req, err := bindAPIKeyRequestFromJSON(r)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		issued, err := svc.CreateAPIKey(r.Context(), *user, req)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		if err := responseJSONIssuedAPIKey(w, issued); err != nil {
+			svc.GetLogger().Warn("failed to write api key response", zap.Error(err))
+		}
+	}
+}
+
+func GetAPIKeys(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := auth.GetUserFromContext(r.Context())
+		if user == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		keys, err := svc.ListAPIKeys(r.Context(), *user)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		if len(keys) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if err := responseJSONAPIKeys(w, keys); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+	}
+}
+
+func RevokeAPIKey(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "httpx.RevokeAPIKey"
+
+		w.Header().Set("Content-Type", "text/plain")
+
+		user := auth.GetUserFromContext(r.Context())
+		if user == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			svc.WriteError(w, domain.MakeError(lib.StandardError(op, errors.New("invalid key id")), domain.ErrInvalidPayload))
+			return
+		}
+
+		if err := svc.RevokeAPIKey(r.Context(), *user, id); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// IssueAPIKey администратор выпускает ключ для пользователя из пути.
+func IssueAPIKey(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		operator := auth.GetUserFromContext(r.Context())
+		if operator == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		req, err := bindAPIKeyRequestFromJSON(r)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+		req.CreatedBy = operator.ID
+
+		issued, err := svc.IssueAPIKey(r.Context(), chi.URLParam(r, "login"), req)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		if err := responseJSONIssuedAPIKey(w, issued); err != nil {
+			svc.GetLogger().Warn("failed to write api key response", zap.Error(err))
+		}
+	}
+}
@@ -3,13 +3,19 @@ package httpx
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
+	"yandex-diplom/internal/auth"
 	"yandex-diplom/internal/domain"
 	"yandex-diplom/internal/lib"
 	"yandex-diplom/internal/luhn"
 	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
 )
 
 func bindUserFromJSON(r *http.Request) (models.User, error) {
@@ -102,3 +108,272 @@ func bindWithdrawlFromJSON(r *http.Request) (models.Withdrawal, error) {
 
 	return w, nil
 }
+
+func bindAdjustmentFromJSON(r *http.Request) (models.Adjustment, error) {
+	const op = "httpx.bindAdjustmentFromJSON"
+
+	r.Body = http.MaxBytesReader(nil, r.Body, 5<<20)
+	defer r.Body.Close()
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	var a models.Adjustment
+	if err := dec.Decode(&a); err != nil {
+		return models.Adjustment{}, domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInvalidPayload,
+		)
+	}
+
+	return a, nil
+}
+
+func bindAPIKeyRequestFromJSON(r *http.Request) (models.APIKeyRequest, error) {
+	const op = "httpx.bindAPIKeyRequestFromJSON"
+
+	r.Body = http.MaxBytesReader(nil, r.Body, 64<<10)
+	defer r.Body.Close()
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	var req models.APIKeyRequest
+	if err := dec.Decode(&req); err != nil {
+		return models.APIKeyRequest{}, domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInvalidPayload,
+		)
+	}
+
+	return req, nil
+}
+
+func bindReversalFromJSON(r *http.Request) (models.Reversal, error) {
+	const op = "httpx.bindReversalFromJSON"
+
+	r.Body = http.MaxBytesReader(nil, r.Body, 5<<20)
+	defer r.Body.Close()
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	var rev models.Reversal
+	if err := dec.Decode(&rev); err != nil {
+		return models.Reversal{}, domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInvalidPayload,
+		)
+	}
+
+	return rev, nil
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// bindRefreshToken берёт refresh-токен из cookie, а без неё — из JSON-тела
+// {"refresh_token": "..."} для клиентов без cookie.
+func bindRefreshToken(r *http.Request) (string, error) {
+	const op = "httpx.bindRefreshToken"
+
+	if cookie, err := r.Cookie(auth.RefreshCookie); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	r.Body = http.MaxBytesReader(nil, r.Body, 64<<10)
+	defer r.Body.Close()
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	var req refreshRequest
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return "", domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInvalidPayload,
+		)
+	}
+
+	return req.RefreshToken, nil
+}
+
+// accrualCallback уведомление системы начислений, поля как в её ответе на GET /api/orders/{number}.
+type accrualCallback struct {
+	Order   string      `json:"order"`
+	Status  string      `json:"status"`
+	Accrual json.Number `json:"accrual,omitempty"`
+}
+
+func bindAccrualCallbackFromJSON(r *http.Request) (models.Order, error) {
+	const op = "httpx.bindAccrualCallbackFromJSON"
+
+	r.Body = http.MaxBytesReader(nil, r.Body, 5<<20)
+	defer r.Body.Close()
+
+	var cb accrualCallback
+	if err := json.NewDecoder(r.Body).Decode(&cb); err != nil {
+		return models.Order{}, domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInvalidPayload,
+		)
+	}
+
+	if !luhn.Valid(cb.Order) {
+		return models.Order{}, domain.MakeError(
+			lib.StandardError(op, errors.New("invalid order number")),
+			domain.ErrInvalidPayload,
+		)
+	}
+
+	switch cb.Status {
+	case "REGISTERED", models.OrderProcessing, models.OrderInvalid, models.OrderProcessed:
+	default:
+		return models.Order{}, domain.MakeError(
+			lib.StandardError(op, fmt.Errorf("unknown status %q", cb.Status)),
+			domain.ErrInvalidPayload,
+		)
+	}
+
+	var accrual money.Amount
+	if cb.Accrual != "" {
+		// как и в ответах на опрос, округляем до копеек, а не отклоняем
+		v, err := money.ParseRound(cb.Accrual.String())
+		if err != nil || v < 0 {
+			return models.Order{}, domain.MakeError(
+				lib.StandardError(op, fmt.Errorf("invalid accrual %q", cb.Accrual)),
+				domain.ErrInvalidPayload,
+			)
+		}
+		accrual = v
+	}
+
+	return models.Order{Number: cb.Order, Status: cb.Status, Accrual: accrual}, nil
+}
+
+// parseQueryTime принимает RFC3339 или дату YYYY-MM-DD. Дата в верхней границе
+// включает весь день, поэтому сдвигается на сутки вперёд.
+func parseQueryTime(value string, upper bool) (*time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+func bindPageFromQuery(q url.Values) (int, *models.Cursor, error) {
+	var (
+		limit  int
+		cursor *models.Cursor
+	)
+
+	if v := q.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, nil, err
+		}
+		limit = l
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		c, err := models.ParseCursor(v)
+		if err != nil {
+			return 0, nil, err
+		}
+		cursor = &c
+	}
+
+	return limit, cursor, nil
+}
+
+func bindOrderFilterFromQuery(r *http.Request) (models.OrderFilter, error) {
+	const op = "httpx.bindOrderFilterFromQuery"
+
+	q := r.URL.Query()
+
+	var (
+		filter models.OrderFilter
+		err    error
+	)
+
+	filter.Limit, filter.After, err = bindPageFromQuery(q)
+	if err != nil {
+		return models.OrderFilter{}, domain.MakeError(lib.StandardError(op, err), domain.ErrInvalidPayload)
+	}
+
+	for _, v := range q["status"] {
+		for _, status := range strings.Split(v, ",") {
+			if status = strings.ToUpper(strings.TrimSpace(status)); status != "" {
+				filter.Statuses = append(filter.Statuses, status)
+			}
+		}
+	}
+
+	if v := q.Get("from"); v != "" {
+		if filter.From, err = parseQueryTime(v, false); err != nil {
+			return models.OrderFilter{}, domain.MakeError(lib.StandardError(op, err), domain.ErrInvalidPayload)
+		}
+	}
+
+	if v := q.Get("to"); v != "" {
+		if filter.To, err = parseQueryTime(v, true); err != nil {
+			return models.OrderFilter{}, domain.MakeError(lib.StandardError(op, err), domain.ErrInvalidPayload)
+		}
+	}
+
+	return filter, nil
+}
+
+func bindLedgerFilterFromQuery(r *http.Request) (models.LedgerFilter, error) {
+	const op = "httpx.bindLedgerFilterFromQuery"
+
+	q := r.URL.Query()
+
+	var (
+		filter models.LedgerFilter
+		err    error
+	)
+
+	filter.Limit, filter.After, err = bindPageFromQuery(q)
+	if err != nil {
+		return models.LedgerFilter{}, domain.MakeError(lib.StandardError(op, err), domain.ErrInvalidPayload)
+	}
+
+	if v := q.Get("from"); v != "" {
+		if filter.From, err = parseQueryTime(v, false); err != nil {
+			return models.LedgerFilter{}, domain.MakeError(lib.StandardError(op, err), domain.ErrInvalidPayload)
+		}
+	}
+
+	if v := q.Get("to"); v != "" {
+		if filter.To, err = parseQueryTime(v, true); err != nil {
+			return models.LedgerFilter{}, domain.MakeError(lib.StandardError(op, err), domain.ErrInvalidPayload)
+		}
+	}
+
+	return filter, nil
+}
+
+func bindMonthFromQuery(r *http.Request) (time.Time, error) {
+	const op = "httpx.bindMonthFromQuery"
+
+	v := r.URL.Query().Get("month")
+	if v == "" {
+		return time.Time{}, domain.MakeError(lib.StandardError(op, errors.New("month is required")), domain.ErrInvalidPayload)
+	}
+
+	month, err := time.Parse("2006-01", v)
+	if err != nil {
+		return time.Time{}, domain.MakeError(lib.StandardError(op, err), domain.ErrInvalidPayload)
+	}
+
+	return month, nil
+}
@@ -0,0 +1,32 @@
+package httpx
+
+import (
+	"net/http"
+	"yandex-diplom/internal/gophermart"
+)
+
+// AccrualCallback принимает уведомление системы начислений. Окончательный статус
+// применяется сразу (200), промежуточный оставляет заказ на опросе (202).
+func AccrualCallback(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+
+		ext, err := bindAccrualCallbackFromJSON(r)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		done, err := svc.AccrualCallback(r.Context(), ext)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		if !done {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"yandex-diplom/internal/auth"
 	"yandex-diplom/internal/domain"
 	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/models"
+
+	"go.uber.org/zap"
 )
 
 func RegisterUser(svc gophermart.Service) http.HandlerFunc {
@@ -21,15 +24,15 @@ func RegisterUser(svc gophermart.Service) http.HandlerFunc {
 			svc.WriteError(w, domain.ErrInvalidPayload)
 		}
 
-		cookie, err := svc.Register(r.Context(), u)
+		tokens, err := svc.Register(r.Context(), u)
 		if err != nil {
 			svc.WriteError(w, err)
 			return
 		}
 
-		http.SetCookie(w, &cookie)
-
-		w.WriteHeader(http.StatusOK)
+		if err := writeSession(w, r, tokens); err != nil {
+			svc.GetLogger().Warn("failed to write token response", zap.Error(err))
+		}
 	}
 }
 
@@ -47,15 +50,15 @@ func LoginUser(svc gophermart.Service) http.HandlerFunc {
 			svc.WriteError(w, domain.ErrInvalidPayload)
 		}
 
-		cookie, err := svc.Login(r.Context(), u)
+		tokens, err := svc.Login(r.Context(), u)
 		if err != nil {
 			svc.WriteError(w, err)
 			return
 		}
 
-		http.SetCookie(w, &cookie)
-
-		w.WriteHeader(http.StatusOK)
+		if err := writeSession(w, r, tokens); err != nil {
+			svc.GetLogger().Warn("failed to write token response", zap.Error(err))
+		}
 	}
 }
 
@@ -69,6 +72,11 @@ func CreateOrder(svc gophermart.Service) http.HandlerFunc {
 			return
 		}
 
+		if !auth.HasScope(r.Context(), models.ScopeOrdersWrite) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
 		o, err := bindOrderFromPlain(r)
 		if err != nil {
 			svc.WriteError(w, err)
@@ -95,23 +103,48 @@ func GetOrders(svc gophermart.Service) http.HandlerFunc {
 			return
 		}
 
-		orders, err := svc.GetOrders(r.Context(), user.Login)
-		if err != nil {
-			svc.WriteError(w, err)
+		if !auth.HasScope(r.Context(), models.ScopeOrdersRead) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
 
-		if len(orders) == 0 {
-			w.WriteHeader(http.StatusNoContent)
+		// без параметров ответ остаётся таким, как в спецификации
+		if r.URL.RawQuery == "" {
+			orders, err := svc.GetOrders(r.Context(), user.Login)
+			if err != nil {
+				svc.WriteError(w, err)
+				return
+			}
+			writeOrders(w, svc, orders)
 			return
 		}
 
-		if err := responseJSONFromOrders(w, orders); err != nil {
+		filter, err := bindOrderFilterFromQuery(r)
+		if err != nil {
 			svc.WriteError(w, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		page, err := svc.GetOrdersPage(r.Context(), user.Login, filter)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		setNextLink(w, r, page.Next)
+		writeOrders(w, svc, page.Orders)
+	}
+}
+
+func writeOrders(w http.ResponseWriter, svc gophermart.Service, orders []models.Order) {
+	if len(orders) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := responseJSONFromOrders(w, orders); err != nil {
+		svc.WriteError(w, err)
+		return
 	}
 }
 
@@ -125,6 +158,11 @@ func GetBalance(svc gophermart.Service) http.HandlerFunc {
 			return
 		}
 
+		if !auth.HasScope(r.Context(), models.ScopeBalanceRead) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
 		balance, err := svc.GetBalance(r.Context(), *user)
 		if err != nil {
 			svc.WriteError(w, err)
@@ -140,6 +178,42 @@ func GetBalance(svc gophermart.Service) http.HandlerFunc {
 	}
 }
 
+func GetBalanceHistory(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := auth.GetUserFromContext(r.Context())
+		if user == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		filter, err := bindLedgerFilterFromQuery(r)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		page, err := svc.GetLedger(r.Context(), *user, filter)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		setNextLink(w, r, page.Next)
+
+		if len(page.Entries) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if err := responseJSONLedger(w, page.Entries); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+	}
+}
+
 func CreateWithdraw(svc gophermart.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain")
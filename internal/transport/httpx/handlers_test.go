@@ -0,0 +1,281 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"yandex-diplom/internal/accrual"
+	"yandex-diplom/internal/auth"
+	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+	"yandex-diplom/internal/storage/memory"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestService(t *testing.T) (gophermart.Service, *memory.MemoryStorage) {
+	t.Helper()
+
+	store := memory.NewMemoryStorage()
+	accURL, _ := url.Parse("http://localhost:8080")
+
+	return gophermart.New(store, zap.NewNop(), "test", accrual.New(accURL, accrual.Config{})), store
+}
+
+func newTestUser(t *testing.T, svc gophermart.Service, store *memory.MemoryStorage, login string) models.User {
+	t.Helper()
+
+	_, err := svc.Register(context.Background(), models.User{Login: login, Password: "password"})
+	require.NoError(t, err)
+
+	user, err := store.GetUserByLogin(context.Background(), login)
+	require.NoError(t, err)
+	return user
+}
+
+func serve(h http.HandlerFunc, user *models.User, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if user != nil {
+		r = r.WithContext(auth.WithUser(r.Context(), user))
+	}
+
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	svc, _ := newTestService(t)
+
+	w := serve(RegisterUser(svc), nil, http.MethodPost, "/api/user/register", `{"login":"alice","password":"secret"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotEmpty(t, w.Result().Cookies())
+
+	w = serve(RegisterUser(svc), nil, http.MethodPost, "/api/user/register", `{"login":"Alice","password":"other"}`)
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	w = serve(LoginUser(svc), nil, http.MethodPost, "/api/user/login", `{"login":"alice","password":"wrong"}`)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = serve(LoginUser(svc), nil, http.MethodPost, "/api/user/login", `{"login":"alice","password":"secret"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCreateOrder(t *testing.T) {
+	svc, store := newTestService(t)
+	alice := newTestUser(t, svc, store, "alice")
+	bob := newTestUser(t, svc, store, "bobby")
+
+	w := serve(CreateOrder(svc), &alice, http.MethodPost, "/api/user/orders", "12345678903")
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	w = serve(CreateOrder(svc), &alice, http.MethodPost, "/api/user/orders", "12345678903")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(CreateOrder(svc), &bob, http.MethodPost, "/api/user/orders", "12345678903")
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	w = serve(CreateOrder(svc), &alice, http.MethodPost, "/api/user/orders", "12345678900")
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = serve(CreateOrder(svc), nil, http.MethodPost, "/api/user/orders", "12345678903")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = serve(GetOrders(svc), &alice, http.MethodGet, "/api/user/orders", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var orders []models.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &orders))
+	require.Len(t, orders, 1)
+	require.Equal(t, models.OrderNew, orders[0].Status)
+
+	w = serve(GetOrders(svc), &bob, http.MethodGet, "/api/user/orders", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestWithdraw(t *testing.T) {
+	svc, store := newTestService(t)
+	ctx := context.Background()
+	alice := newTestUser(t, svc, store, "alice")
+
+	require.NoError(t, store.CreateOrder(ctx, alice.Login, models.Order{Number: "12345678903"}))
+	orders, err := store.FetchNewOrders(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	require.NoError(t, store.FinalizeOrder(ctx, orders[0], money.FromInt(100)))
+
+	w := serve(CreateWithdraw(svc), &alice, http.MethodPost, "/api/user/balance/withdraw", `{"order":"2377225624","sum":40.5}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(CreateWithdraw(svc), &alice, http.MethodPost, "/api/user/balance/withdraw", `{"order":"79927398713","sum":100}`)
+	require.Equal(t, http.StatusPaymentRequired, w.Code)
+
+	w = serve(GetBalance(svc), &alice, http.MethodGet, "/api/user/balance", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var balance models.Balance
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &balance))
+	require.Equal(t, money.FromCents(5950), balance.Current)
+	require.Equal(t, money.FromCents(4050), balance.Withdrawn)
+
+	w = serve(GetWithdraws(svc), &alice, http.MethodGet, "/api/user/withdrawals", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var withdrawals []models.Withdrawal
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &withdrawals))
+	require.Len(t, withdrawals, 1)
+	require.Equal(t, "2377225624", withdrawals[0].Order)
+}
+
+func TestAccrualCallback(t *testing.T) {
+	svc, store := newTestService(t)
+	alice := newTestUser(t, svc, store, "alice")
+
+	require.NoError(t, svc.PutOrder(context.Background(), alice.Login, models.Order{Number: "12345678903"}))
+	require.NoError(t, svc.PutOrder(context.Background(), alice.Login, models.Order{Number: "79927398713"}))
+
+	w := serve(AccrualCallback(svc), nil, http.MethodPost, "/api/internal/accrual/callback", `{"order":"12345678903","status":"PROCESSING"}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	// заказ ещё в NEW, уведомление завершает его без опроса
+	w = serve(AccrualCallback(svc), nil, http.MethodPost, "/api/internal/accrual/callback", `{"order":"12345678903","status":"PROCESSED","accrual":729.98}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = serve(AccrualCallback(svc), nil, http.MethodPost, "/api/internal/accrual/callback", `{"order":"12345678903","status":"PROCESSED","accrual":729.98}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(AccrualCallback(svc), nil, http.MethodPost, "/api/internal/accrual/callback", `{"order":"79927398713","status":"INVALID"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(AccrualCallback(svc), nil, http.MethodPost, "/api/internal/accrual/callback", `{"order":"4561261212345467","status":"PROCESSED","accrual":1}`)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(AccrualCallback(svc), nil, http.MethodPost, "/api/internal/accrual/callback", `{"order":"12345678903","status":"DONE"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	orders, err := svc.GetOrders(context.Background(), alice.Login)
+	require.NoError(t, err)
+	statuses := make(map[string]models.Order)
+	for _, o := range orders {
+		statuses[o.Number] = o
+	}
+	require.Equal(t, models.OrderProcessed, statuses["12345678903"].Status)
+	require.Equal(t, money.FromCents(72998), statuses["12345678903"].Accrual)
+	require.Equal(t, models.OrderInvalid, statuses["79927398713"].Status)
+
+	balance, err := svc.GetBalance(context.Background(), alice)
+	require.NoError(t, err)
+	require.Equal(t, money.FromCents(72998), balance.Current)
+}
+
+func cookieValue(w *httptest.ResponseRecorder, name string) string {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func TestRefreshAndLogout(t *testing.T) {
+	svc, _ := newTestService(t)
+
+	w := serve(RegisterUser(svc), nil, http.MethodPost, "/api/user/register", `{"login":"carol","password":"secret"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	refresh := cookieValue(w, auth.RefreshCookie)
+	require.NotEmpty(t, refresh)
+
+	doRefresh := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/user/token/refresh", nil)
+		r.AddCookie(&http.Cookie{Name: auth.RefreshCookie, Value: token})
+		w := httptest.NewRecorder()
+		RefreshToken(svc)(w, r)
+		return w
+	}
+
+	w = doRefresh(refresh)
+	require.Equal(t, http.StatusOK, w.Code)
+	access := cookieValue(w, auth.AccessCookie)
+	rotated := cookieValue(w, auth.RefreshCookie)
+	require.NotEmpty(t, access)
+	require.NotEqual(t, refresh, rotated)
+
+	protected := auth.Middleware(svc)(Logout(svc))
+	logout := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/user/logout", nil)
+		r.AddCookie(&http.Cookie{Name: auth.AccessCookie, Value: access})
+		w := httptest.NewRecorder()
+		protected.ServeHTTP(w, r)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, logout().Code)
+
+	// после выхода access-токен отклоняется, refresh-токен не обновляется
+	require.Equal(t, http.StatusUnauthorized, logout().Code)
+	require.Equal(t, http.StatusUnauthorized, doRefresh(rotated).Code)
+	require.Equal(t, http.StatusUnauthorized, doRefresh("").Code)
+}
+
+func TestLoginTokenInBody(t *testing.T) {
+	svc, store := newTestService(t)
+	newTestUser(t, svc, store, "dave")
+
+	r := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(`{"login":"dave","password":"password"}`))
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	LoginUser(svc)(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var body models.AuthToken
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, "Bearer", body.TokenType)
+	require.NotEmpty(t, body.AccessToken)
+	require.NotEmpty(t, body.RefreshToken)
+	require.Positive(t, body.ExpiresIn)
+	require.Equal(t, "Bearer "+body.AccessToken, w.Header().Get("Authorization"))
+
+	// без cookie refresh-токен принимается из тела
+	r = httptest.NewRequest(http.MethodPost, "/api/user/token/refresh", strings.NewReader(`{"refresh_token":"`+body.RefreshToken+`"}`))
+	w = httptest.NewRecorder()
+	RefreshToken(svc)(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Body.String())
+	require.NotEmpty(t, w.Header().Get("Authorization"))
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	svc, store := newTestService(t)
+	user := newTestUser(t, svc, store, "partner")
+
+	w := serve(CreateAPIKey(svc), &user, http.MethodPost, "/api/user/api-keys", `{"name":"shop","scopes":["orders:write"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var issued models.IssuedAPIKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+	require.NotEmpty(t, issued.Key)
+
+	withKey := func(h http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set(auth.APIKeyHeader, issued.Key)
+		w := httptest.NewRecorder()
+		auth.APIKeyMiddleware(svc)(auth.Middleware(svc)(h)).ServeHTTP(w, r)
+		return w
+	}
+
+	require.Equal(t, http.StatusAccepted, withKey(CreateOrder(svc), http.MethodPost, "/api/user/orders", "12345678903").Code)
+	require.Equal(t, http.StatusForbidden, withKey(GetOrders(svc), http.MethodGet, "/api/user/orders", "").Code)
+	require.Equal(t, http.StatusForbidden, withKey(GetBalance(svc), http.MethodGet, "/api/user/balance", "").Code)
+
+	w = serve(RevokeAPIKey(svc), &user, http.MethodDelete, "/api/user/api-keys/x", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	require.NoError(t, svc.RevokeAPIKey(context.Background(), user, issued.ID))
+	require.Equal(t, http.StatusUnauthorized, withKey(CreateOrder(svc), http.MethodPost, "/api/user/orders", "79927398713").Code)
+}
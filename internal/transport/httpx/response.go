@@ -3,6 +3,8 @@ package httpx
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"yandex-diplom/internal/auth"
 	"yandex-diplom/internal/domain"
 	"yandex-diplom/internal/lib"
 	"yandex-diplom/internal/models"
@@ -67,3 +69,261 @@ func responseJSONWithdrawals(w http.ResponseWriter, p []models.Withdrawal) error
 	}
 	return nil
 }
+
+func responseJSONBalanceEntry(w http.ResponseWriter, e models.BalanceEntry) error {
+	const op = "httpx.responseJSONBalanceEntry"
+
+	payload, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+	return nil
+}
+
+func responseJSONBalanceEntries(w http.ResponseWriter, e []models.BalanceEntry) error {
+	const op = "httpx.responseJSONBalanceEntries"
+
+	payload, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+	return nil
+}
+
+func responseJSONDeadLetterOrders(w http.ResponseWriter, orders []models.DeadLetterOrder) error {
+	const op = "httpx.responseJSONDeadLetterOrders"
+
+	payload, err := json.MarshalIndent(orders, "", "  ")
+	if err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+	return nil
+}
+
+func responseJSONUserInfo(w http.ResponseWriter, u models.UserInfo) error {
+	const op = "httpx.responseJSONUserInfo"
+
+	payload, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+	return nil
+}
+
+func responseJSONReconcileReport(w http.ResponseWriter, report models.ReconcileReport) error {
+	const op = "httpx.responseJSONReconcileReport"
+
+	payload, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+	return nil
+}
+
+func responseJSONWorkerPoolStats(w http.ResponseWriter, stats models.WorkerPoolStats) error {
+	const op = "httpx.responseJSONWorkerPoolStats"
+
+	payload, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+	return nil
+}
+
+func responseJSONLedger(w http.ResponseWriter, e []models.LedgerEntry) error {
+	const op = "httpx.responseJSONLedger"
+
+	payload, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+	return nil
+}
+
+// setNextLink отдаёт курсор следующей страницы в заголовке Link (RFC 8288).
+func setNextLink(w http.ResponseWriter, r *http.Request, next *models.Cursor) {
+	if next == nil {
+		return
+	}
+
+	q := r.URL.Query()
+	q.Set("cursor", next.Encode())
+
+	u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+	w.Header().Set("Link", "<"+u.String()+`>; rel="next"`)
+}
+
+func responseJSONAccrualStats(w http.ResponseWriter, stats models.AccrualStats) error {
+	const op = "httpx.responseJSONAccrualStats"
+
+	payload, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	return nil
+}
+
+func responseJSONAuthToken(w http.ResponseWriter, token models.AuthToken) error {
+	const op = "httpx.responseJSONAuthToken"
+
+	payload, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	return nil
+}
+
+func responseJSONKeys(w http.ResponseWriter, keys auth.JWKS) error {
+	const op = "httpx.responseJSONKeys"
+
+	payload, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	return nil
+}
+
+func responseJSONAPIKeys(w http.ResponseWriter, v []models.APIKey) error {
+	const op = "httpx.responseJSONAPIKeys"
+
+	payload, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	return nil
+}
+
+func responseJSONIssuedAPIKey(w http.ResponseWriter, v models.IssuedAPIKey) error {
+	const op = "httpx.responseJSONIssuedAPIKey"
+
+	payload, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return domain.MakeError(
+			lib.StandardError(op, err),
+			domain.ErrInternal,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package httpx
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"yandex-diplom/internal/auth"
+	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// setSessionCookies кладёт access-токен в cookie для всего API, а refresh-токен —
+// в cookie, которая уходит только на эндпоинты обновления.
+func setSessionCookies(w http.ResponseWriter, tokens models.Tokens) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.AccessCookie,
+		Value:    tokens.Access,
+		Path:     "/",
+		Expires:  tokens.AccessExpires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.RefreshCookie,
+		Value:    tokens.Refresh,
+		Path:     auth.RefreshPath,
+		Expires:  tokens.RefreshExpires,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// acceptsJSON клиент просит тело ответа в JSON.
+func acceptsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// writeSession отдаёт токены в cookie и в заголовке Authorization, а клиенту,
+// запросившему JSON, ещё и в теле ответа.
+func writeSession(w http.ResponseWriter, r *http.Request, tokens models.Tokens) error {
+	setSessionCookies(w, tokens)
+	w.Header().Set("Authorization", auth.BearerScheme+" "+tokens.Access)
+
+	if !acceptsJSON(r) {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return responseJSONAuthToken(w, models.AuthToken{
+		AccessToken:  tokens.Access,
+		TokenType:    auth.BearerScheme,
+		ExpiresIn:    int64(time.Until(tokens.AccessExpires).Seconds()),
+		RefreshToken: tokens.Refresh,
+	})
+}
+
+func clearSessionCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: auth.AccessCookie, Path: "/", MaxAge: -1, HttpOnly: true})
+	http.SetCookie(w, &http.Cookie{Name: auth.RefreshCookie, Path: auth.RefreshPath, MaxAge: -1, HttpOnly: true})
+}
+
+// RefreshToken выдаёт новую пару токенов по refresh-токену из cookie или тела.
+func RefreshToken(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+
+		refresh, err := bindRefreshToken(r)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		tokens, err := svc.RefreshTokens(r.Context(), refresh)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		if err := writeSession(w, r, tokens); err != nil {
+			svc.GetLogger().Warn("failed to write token response", zap.Error(err))
+		}
+	}
+}
+
+// Logout отзывает текущую сессию и стирает cookie.
+func Logout(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+
+		if err := svc.Logout(r.Context(), auth.GetSessionFromContext(r.Context())); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		clearSessionCookies(w)
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// GetJWKS отдаёт открытые ключи, которыми другие сервисы проверяют наши токены.
+func GetJWKS(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+
+		if err := responseJSONKeys(w, auth.PublicJWKS()); err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+	}
+}
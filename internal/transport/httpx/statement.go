@@ -0,0 +1,231 @@
+package httpx
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"yandex-diplom/internal/auth"
+	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// statementFlushEvery через сколько строк выписки отправлять накопленное клиенту.
+const statementFlushEvery = 100
+
+// statementStream общая часть потоковых писателей: отправляет заголовки при первой
+// записи и периодически сбрасывает буферы, включая gzip.
+type statementStream struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	started bool
+	rows    int
+}
+
+func newStatementStream(w http.ResponseWriter) statementStream {
+	return statementStream{w: w, rc: http.NewResponseController(w)}
+}
+
+func (s *statementStream) start() {
+	s.started = true
+	s.w.WriteHeader(http.StatusOK)
+}
+
+// row учитывает записанную строку и сообщает, пора ли сбросить буферы.
+func (s *statementStream) row() bool {
+	s.rows++
+	return s.rows%statementFlushEvery == 0
+}
+
+func (s *statementStream) flush() error {
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+type csvStatementWriter struct {
+	statementStream
+	cw *csv.Writer
+}
+
+func newCSVStatementWriter(w http.ResponseWriter) *csvStatementWriter {
+	return &csvStatementWriter{statementStream: newStatementStream(w), cw: csv.NewWriter(w)}
+}
+
+func (c *csvStatementWriter) WriteOpening(st models.Statement) error {
+	c.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	c.w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment",
+		map[string]string{"filename": "statement-" + st.Month + ".csv"}))
+	c.start()
+
+	_ = c.cw.Write([]string{"posted_at", "type", "amount", "order", "reference", "reason", "balance"})
+	return c.cw.Write([]string{st.From.Format(time.RFC3339), "opening", "", "", "", "", st.Opening.String()})
+}
+
+func (c *csvStatementWriter) WriteEntry(e models.LedgerEntry) error {
+	var postedAt string
+	if e.PostedAt != nil {
+		postedAt = e.PostedAt.UTC().Format(time.RFC3339)
+	}
+
+	err := c.cw.Write([]string{postedAt, e.Type, e.Amount.String(), e.Order, e.Reference, e.Reason, e.RunningBalance.String()})
+	if err != nil {
+		return err
+	}
+
+	if !c.row() {
+		return nil
+	}
+	c.cw.Flush()
+	if err := c.cw.Error(); err != nil {
+		return err
+	}
+	return c.flush()
+}
+
+func (c *csvStatementWriter) WriteClosing(st models.Statement) error {
+	err := c.cw.Write([]string{st.To.Format(time.RFC3339), "closing", "", "", "", "", st.Closing.String()})
+	if err != nil {
+		return err
+	}
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+// jsonStatementWriter пишет объект выписки по частям, массив entries не собирается в памяти.
+type jsonStatementWriter struct {
+	statementStream
+}
+
+func newJSONStatementWriter(w http.ResponseWriter) *jsonStatementWriter {
+	return &jsonStatementWriter{statementStream: newStatementStream(w)}
+}
+
+func (j *jsonStatementWriter) WriteOpening(st models.Statement) error {
+	j.w.Header().Set("Content-Type", "application/json")
+	j.start()
+
+	_, err := fmt.Fprintf(j.w, `{"month":%q,"from":%q,"to":%q,"opening_balance":%s,"entries":[`,
+		st.Month, st.From.Format(time.RFC3339), st.To.Format(time.RFC3339), st.Opening.String())
+	return err
+}
+
+func (j *jsonStatementWriter) WriteEntry(e models.LedgerEntry) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if j.rows > 0 {
+		if _, err := j.w.Write([]byte{','}); err != nil {
+			return err
+		}
+	}
+	if _, err := j.w.Write(payload); err != nil {
+		return err
+	}
+
+	if !j.row() {
+		return nil
+	}
+	return j.flush()
+}
+
+func (j *jsonStatementWriter) WriteClosing(st models.Statement) error {
+	_, err := fmt.Fprintf(j.w, `],"closing_balance":%s}`, st.Closing.String())
+	return err
+}
+
+// statementFormat выбирает формат выписки по заголовку Accept, по умолчанию JSON.
+func statementFormat(accept string) (string, bool) {
+	if accept == "" {
+		return "application/json", true
+	}
+
+	best, bestQ := "", -1.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case "text/csv", "application/json":
+		case "*/*", "application/*":
+			mediaType = "application/json"
+		case "text/*":
+			mediaType = "text/csv"
+		default:
+			continue
+		}
+
+		if q > 0 && q > bestQ {
+			best, bestQ = mediaType, q
+		}
+	}
+
+	return best, best != ""
+}
+
+func GetStatement(svc gophermart.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUserFromContext(r.Context())
+		if user == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		format, ok := statementFormat(r.Header.Get("Accept"))
+		if !ok {
+			http.Error(w, "Not Acceptable", http.StatusNotAcceptable)
+			return
+		}
+
+		month, err := bindMonthFromQuery(r)
+		if err != nil {
+			svc.WriteError(w, err)
+			return
+		}
+
+		var (
+			out    gophermart.StatementWriter
+			stream *statementStream
+		)
+		if format == "text/csv" {
+			cw := newCSVStatementWriter(w)
+			out, stream = cw, &cw.statementStream
+		} else {
+			jw := newJSONStatementWriter(w)
+			out, stream = jw, &jw.statementStream
+		}
+
+		err = svc.WriteStatement(r.Context(), *user, month, out)
+		if err == nil {
+			return
+		}
+
+		if !stream.started {
+			svc.WriteError(w, err)
+			return
+		}
+
+		// заголовки уже ушли, остаётся оборвать ответ
+		svc.GetLogger().Warn("statement stream aborted", zap.Error(err))
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,140 @@
+package worker
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+	"yandex-diplom/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// minTaskCost нижняя оценка времени одного задания, пока нет замеров задержки.
+const minTaskCost = 50 * time.Millisecond
+
+// PoolConfig границы пула воркеров. Пул растёт, когда очередь не успевает
+// разбираться за ScaleInterval, и сжимается по одному воркеру, когда есть простаивающие.
+type PoolConfig struct {
+	MinWorkers    int
+	MaxWorkers    int
+	ScaleInterval time.Duration
+}
+
+type pool struct {
+	cfg PoolConfig
+
+	mu     sync.Mutex
+	stops  []chan struct{}
+	nextID int
+	busy   atomic.Int32
+}
+
+func (cfg PoolConfig) withDefaults() PoolConfig {
+	if cfg.MinWorkers < 0 {
+		cfg.MinWorkers = 0
+	}
+
+	if cfg.MaxWorkers <= 0 {
+		cfg.MaxWorkers = max(cfg.MinWorkers, 4)
+	}
+
+	if cfg.MaxWorkers < cfg.MinWorkers {
+		cfg.MaxWorkers = cfg.MinWorkers
+	}
+
+	if cfg.ScaleInterval <= 0 {
+		cfg.ScaleInterval = 5 * time.Second
+	}
+	return cfg
+}
+
+// desiredWorkers сколько воркеров нужно, чтобы занятые продолжили работу, а pending
+// заданий по latency каждое разобрались за interval.
+func desiredWorkers(busy, pending int, latency, interval time.Duration, cfg PoolConfig) int {
+	latency = max(latency, minTaskCost)
+	need := busy + int((time.Duration(pending)*latency+interval-1)/interval)
+	return min(max(need, cfg.MinWorkers), cfg.MaxWorkers)
+}
+
+// resize запускает или останавливает воркеров до n. Остановленный воркер
+// дорабатывает текущее задание.
+func (w *Workers) resize(n int) {
+	w.pool.mu.Lock()
+	defer w.pool.mu.Unlock()
+
+	for len(w.pool.stops) < n {
+		stop := make(chan struct{})
+		id := w.pool.nextID
+		w.pool.nextID++
+		w.pool.stops = append(w.pool.stops, stop)
+		w.goTracked(func() { w.worker(id, stop) })
+	}
+
+	for len(w.pool.stops) > n {
+		last := len(w.pool.stops) - 1
+		close(w.pool.stops[last])
+		w.pool.stops = w.pool.stops[:last]
+	}
+}
+
+func (w *Workers) size() int {
+	w.pool.mu.Lock()
+	defer w.pool.mu.Unlock()
+	return len(w.pool.stops)
+}
+
+func (w *Workers) autoscale() {
+	ticker := time.NewTicker(w.pool.cfg.ScaleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			w.scale()
+		}
+	}
+}
+
+func (w *Workers) scale() {
+	cfg := w.pool.cfg
+
+	pending := 0
+	if kinds := w.kinds(); len(kinds) > 0 {
+		n, err := w.queue.CountTasks(w.ctx, kinds)
+		if err != nil {
+			if w.ctx.Err() == nil {
+				w.logger.Warn("Failed to count queued tasks", zap.Error(err))
+			}
+			return
+		}
+		pending = n
+	}
+	latency := w.svc.GetAccrualStats(w.ctx).AvgLatency
+
+	current, busy := w.size(), int(w.pool.busy.Load())
+	desired := desiredWorkers(busy, pending, latency, cfg.ScaleInterval, cfg)
+	switch {
+	case desired > current:
+		w.logger.Info("Scaling workers up", zap.Int("from", current), zap.Int("to", desired), zap.Int("pending", pending), zap.Duration("latency", latency))
+		w.resize(desired)
+	case desired < current && busy < current:
+		// сжимаемся плавно, чтобы не дёргать пул на коротких провалах очереди
+		w.logger.Debug("Scaling workers down", zap.Int("from", current), zap.Int("to", current-1))
+		w.resize(current - 1)
+	}
+
+	size := w.size()
+	busy = min(int(w.pool.busy.Load()), size)
+	w.svc.SaveWorkerPoolStats(models.WorkerPoolStats{
+		Workers:        size,
+		Busy:           busy,
+		Idle:           size - busy,
+		MinWorkers:     cfg.MinWorkers,
+		MaxWorkers:     cfg.MaxWorkers,
+		QueueLength:    pending,
+		AccrualLatency: latency,
+		UpdatedAt:      time.Now(),
+	})
+}
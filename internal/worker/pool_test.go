@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+	"yandex-diplom/internal/accrual"
+	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/job"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/storage/memory"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDesiredWorkers(t *testing.T) {
+	cfg := PoolConfig{MinWorkers: 2, MaxWorkers: 10}
+	tests := []struct {
+		name    string
+		busy    int
+		pending int
+		latency time.Duration
+		want    int
+	}{
+		{"idle", 0, 0, 0, 2},
+		{"busy keep running", 3, 0, 0, 3},
+		{"fast tasks", 0, 100, 0, 2},
+		{"slow accrual", 0, 100, 200 * time.Millisecond, 4},
+		{"capped", 4, 1000, time.Second, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, desiredWorkers(tt.busy, tt.pending, tt.latency, 5*time.Second, cfg))
+		})
+	}
+}
+
+type blockingJob struct {
+	release <-chan struct{}
+}
+
+func (j blockingJob) Process(ctx context.Context, svc job.Service, logger *zap.Logger) error {
+	select {
+	case <-j.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestWorkers_Autoscale(t *testing.T) {
+	store := memory.NewMemoryStorage()
+	base, _ := url.Parse("http://localhost:0")
+	svc := gophermart.New(store, zap.NewNop(), "test", accrual.New(base, accrual.Config{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	workers := InitWorkers(ctx, PoolConfig{MinWorkers: 1, MaxWorkers: 4, ScaleInterval: 20 * time.Millisecond}, svc, store)
+	release := make(chan struct{})
+	workers.handle("block", func(models.Task) (job.Job, error) {
+		return blockingJob{release: release}, nil
+	})
+
+	tasks := make([]models.Task, 20)
+	for i := range tasks {
+		tasks[i] = models.Task{Kind: "block"}
+	}
+	require.NoError(t, store.EnqueueTasks(ctx, tasks...))
+
+	require.Eventually(t, func() bool {
+		stats, ok := svc.GetWorkerPoolStats(ctx)
+		return ok && stats.Workers == 4 && stats.Busy == 4 && stats.Idle == 0 && stats.QueueLength == 16
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(release)
+
+	// очередь разобрана, пул сжимается до минимума
+	require.Eventually(t, func() bool {
+		stats, ok := svc.GetWorkerPoolStats(ctx)
+		return ok && stats.Workers == 1 && stats.QueueLength == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	require.NoError(t, workers.Shutdown(shutdownCtx))
+}
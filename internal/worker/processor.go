@@ -2,7 +2,10 @@ package worker
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"math/rand"
+	"sync"
 	"time"
 	"yandex-diplom/internal/job"
 	"yandex-diplom/internal/job/jobs"
@@ -11,34 +14,273 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	KindOrder     = "order"
+	KindBalance   = "balance"
+	KindReconcile = "reconcile"
+)
+
+const (
+	// pollInterval пауза воркера, когда в очереди нет заданий.
+	pollInterval = 500 * time.Millisecond
+	// taskVisibility на сколько задание скрывается от других воркеров при выдаче.
+	taskVisibility = 10 * time.Minute
+	// maxTaskAttempts после стольких неудач задание удаляется из очереди.
+	maxTaskAttempts = 10
+)
+
+// decoder собирает задание для воркера из записи очереди.
+type decoder func(task models.Task) (job.Job, error)
+
+// Workers разбирают очередь заданий. Воркер берёт только те виды заданий,
+// процессоры которых запущены в этом экземпляре.
 type Workers struct {
-	ctx    context.Context
+	// ctx останавливает выборку заданий, jobCtx прерывает уже начатые задания.
+	ctx        context.Context
+	stop       context.CancelFunc
+	jobCtx     context.Context
+	cancelJobs context.CancelFunc
+	wg         sync.WaitGroup
+
 	logger *zap.Logger
 	svc    job.Service
-	jobCh  chan job.Job
+	queue  job.Queue
+	pool   pool
+
+	mu       sync.RWMutex
+	decoders map[string]decoder
+
+	// claimed заказы, взятые из NEW этим экземпляром и ещё не начатые, по ключу задания.
+	claimedMu sync.Mutex
+	claimed   map[string]models.Order
 }
 
-func InitWorkers(ctx context.Context, workerCount int, svc job.Service, jobCh chan job.Job) Workers {
-	for i := range workerCount {
-		go worker(ctx, i, svc, jobCh)
+// InitWorkers запускает пул из cfg.MinWorkers воркеров. Отмена ctx останавливает выборку,
+// но не прерывает начатые задания: их дожидается Shutdown.
+func InitWorkers(ctx context.Context, cfg PoolConfig, svc job.Service, queue job.Queue) *Workers {
+	w := &Workers{
+		logger:   svc.GetLogger(),
+		svc:      svc,
+		queue:    queue,
+		pool:     pool{cfg: cfg.withDefaults()},
+		decoders: make(map[string]decoder),
+		claimed:  make(map[string]models.Order),
+	}
+	w.ctx, w.stop = context.WithCancel(ctx)
+	w.jobCtx, w.cancelJobs = context.WithCancel(context.WithoutCancel(ctx))
+
+	w.logger.Info("Worker pool config", zap.Int("MinWorkers", w.pool.cfg.MinWorkers), zap.Int("MaxWorkers", w.pool.cfg.MaxWorkers), zap.Duration("ScaleInterval", w.pool.cfg.ScaleInterval))
+
+	w.resize(w.pool.cfg.MinWorkers)
+	w.goTracked(w.autoscale)
+	return w
+}
+
+func (w *Workers) goTracked(fn func()) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		fn()
+	}()
+}
+
+// Shutdown останавливает выборку и ждёт завершения начатых заданий до дедлайна ctx,
+// после чего прерывает оставшиеся. Заказы, взятые из NEW, но не начатые,
+// снимаются с очереди и возвращаются в NEW.
+func (w *Workers) Shutdown(ctx context.Context) error {
+	w.stop()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		w.logger.Warn("Workers did not finish in time, cancelling running jobs")
+		w.cancelJobs()
+		<-done
+		err = ctx.Err()
 	}
-	return Workers{ctx: ctx, logger: svc.GetLogger(), svc: svc, jobCh: jobCh}
+	w.cancelJobs()
+
+	return errors.Join(err, w.releaseClaimed())
+}
+
+func (w *Workers) claim(key string, o models.Order) {
+	w.claimedMu.Lock()
+	defer w.claimedMu.Unlock()
+	w.claimed[key] = o
+}
+
+func (w *Workers) unclaim(key string) {
+	w.claimedMu.Lock()
+	defer w.claimedMu.Unlock()
+	delete(w.claimed, key)
+}
+
+func (w *Workers) releaseClaimed() error {
+	w.claimedMu.Lock()
+	claimed := w.claimed
+	w.claimed = make(map[string]models.Order)
+	w.claimedMu.Unlock()
+
+	if len(claimed) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	keys := make([]string, 0, len(claimed))
+	for key := range claimed {
+		keys = append(keys, key)
+	}
+
+	// задания, которые уже взял другой экземпляр, остаются за ним
+	cancelled, err := w.queue.CancelTasks(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	orders := make([]models.Order, 0, len(cancelled))
+	for _, key := range cancelled {
+		orders = append(orders, claimed[key])
+	}
+	if err := w.svc.ReleaseOrders(ctx, orders); err != nil {
+		return err
+	}
+
+	w.logger.Info("Released unstarted orders", zap.Int("count", len(orders)))
+	return nil
+}
+
+func (w *Workers) handle(kind string, d decoder) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.decoders[kind] = d
 }
 
-func worker(ctx context.Context, id int, svc job.Service, in <-chan job.Job) {
-	logger := svc.GetLogger()
-	logger.Debug("Starting worker", zap.Int("wid", id))
+func (w *Workers) kinds() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	kinds := make([]string, 0, len(w.decoders))
+	for kind := range w.decoders {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+func (w *Workers) worker(id int, stop <-chan struct{}) {
+	w.logger.Debug("Starting worker", zap.Int("wid", id))
+	defer w.logger.Debug("Stopping worker", zap.Int("wid", id))
+
 	for {
 		select {
-		case <-ctx.Done():
-			logger.Debug("Stopping worker", zap.Int("wid", id))
+		case <-stop:
 			return
-		case job := <-in:
-			err := job.Process(ctx, svc, logger)
-			if err != nil {
-				logger.Warn("Job processing failed", zap.Int("wid", id), zap.Error(err))
-			}
+		default:
+		}
+
+		task, ok := w.lease()
+		if ok {
+			w.pool.busy.Add(1)
+			w.process(id, task)
+			w.pool.busy.Add(-1)
+			continue
+		}
+
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-time.After(pollInterval/2 + time.Duration(rand.Int63n(int64(pollInterval)))):
+		}
+	}
+}
+
+func (w *Workers) lease() (models.Task, bool) {
+	kinds := w.kinds()
+	if len(kinds) == 0 || w.ctx.Err() != nil {
+		return models.Task{}, false
+	}
+
+	tasks, err := w.queue.LeaseTasks(w.ctx, kinds, 1, taskVisibility)
+	if err != nil {
+		if w.ctx.Err() == nil {
+			w.logger.Warn("Failed to lease task", zap.Error(err))
 		}
+		return models.Task{}, false
+	}
+	if len(tasks) == 0 {
+		return models.Task{}, false
+	}
+	return tasks[0], true
+}
+
+func (w *Workers) process(id int, task models.Task) {
+	logger := w.logger.With(zap.Int("wid", id), zap.String("kind", task.Kind), zap.Int64("task", task.ID), zap.Int("attempt", task.Attempts))
+
+	w.unclaim(task.Key)
+
+	w.mu.RLock()
+	decode := w.decoders[task.Kind]
+	w.mu.RUnlock()
+
+	j, err := decode(task)
+	if err != nil {
+		logger.Error("Dropping malformed task", zap.Error(err))
+		w.ack(logger, task)
+		return
+	}
+
+	err = j.Process(w.jobCtx, w.svc, logger)
+	if err == nil {
+		w.ack(logger, task)
+		return
+	}
+
+	// прерванное задание вернётся в очередь по истечении аренды
+	if w.jobCtx.Err() != nil {
+		return
+	}
+
+	logger.Warn("Job processing failed", zap.Error(err))
+	if task.Attempts >= maxTaskAttempts {
+		logger.Error("Dropping task after too many attempts")
+		w.ack(logger, task)
+		return
+	}
+
+	delay := time.Duration(1<<min(task.Attempts, 8)) * time.Second
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(w.ctx), 5*time.Second)
+	defer cancel()
+	if err := w.queue.NackTask(ctx, task, time.Now().Add(delay), err.Error()); err != nil {
+		logger.Warn("Failed to return task to queue", zap.Error(err))
+	}
+}
+
+func (w *Workers) ack(logger *zap.Logger, task models.Task) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(w.ctx), 5*time.Second)
+	defer cancel()
+
+	if err := w.queue.AckTask(ctx, task); err != nil {
+		logger.Warn("Failed to ack task", zap.Error(err))
+	}
+}
+
+// enqueue ставит задания в очередь, ошибки только логируются: продюсер повторит на следующем тике.
+func (w *Workers) enqueue(producer string, tasks ...models.Task) {
+	if len(tasks) == 0 {
+		return
+	}
+	if err := w.queue.EnqueueTasks(w.ctx, tasks...); err != nil && w.ctx.Err() == nil {
+		w.logger.Warn("["+producer+"] failed to enqueue tasks", zap.Int("count", len(tasks)), zap.Error(err))
 	}
 }
 
@@ -46,13 +288,43 @@ type OrderConfig struct {
 	BatchSize               int
 	FetchNewInterval        time.Duration
 	FetchProccesingInterval time.Duration
+	// RequestsPerMinute бюджет запросов к системе начислений, 0 без ограничения
+	// до первого 429.
+	RequestsPerMinute int
+	Retry             job.RetryPolicy
 }
 
 type BalanceConfig struct {
 	FetchInterval time.Duration
 }
 
-func (w Workers) StartOrderProcessor(cfg OrderConfig) {
+type ReconcileConfig struct {
+	Interval  time.Duration
+	BatchSize int
+	Repair    bool
+}
+
+// orderPayload заказ в задании очереди.
+type orderPayload struct {
+	UserID     uint64     `json:"user_id"`
+	Number     string     `json:"number"`
+	UploadedAt *time.Time `json:"uploaded_at,omitempty"`
+	Attempts   int        `json:"attempts"`
+}
+
+func orderTask(o models.Order) (models.Task, error) {
+	payload, err := json.Marshal(orderPayload{UserID: o.UserID, Number: o.Number, UploadedAt: o.UploadedAt, Attempts: o.Attempts})
+	if err != nil {
+		return models.Task{}, err
+	}
+	return models.Task{Kind: KindOrder, Key: orderKey(o.Number), Payload: payload}, nil
+}
+
+func orderKey(number string) string {
+	return KindOrder + ":" + number
+}
+
+func (w *Workers) StartOrderProcessor(cfg OrderConfig) {
 	if cfg.BatchSize <= 0 {
 		cfg.BatchSize = 10
 	}
@@ -65,50 +337,108 @@ func (w Workers) StartOrderProcessor(cfg OrderConfig) {
 		cfg.FetchProccesingInterval = 3 * time.Second
 	}
 
-	w.logger.Info("Order processor config", zap.Int("BatchSize", cfg.BatchSize), zap.Duration("FetchNewInterval", cfg.FetchNewInterval), zap.Duration("FetchProccesingInterval", cfg.FetchProccesingInterval))
+	if cfg.Retry.BaseDelay <= 0 {
+		cfg.Retry.BaseDelay = 10 * time.Second
+	}
+
+	if cfg.Retry.MaxDelay <= 0 {
+		cfg.Retry.MaxDelay = 30 * time.Minute
+	}
 
-	throttler := job.NewThrottler()
+	w.logger.Info("Order processor config", zap.Int("BatchSize", cfg.BatchSize), zap.Duration("FetchNewInterval", cfg.FetchNewInterval), zap.Duration("FetchProccesingInterval", cfg.FetchProccesingInterval), zap.Int("RequestsPerMinute", cfg.RequestsPerMinute),
+		zap.Duration("RetryBaseDelay", cfg.Retry.BaseDelay), zap.Duration("RetryMaxDelay", cfg.Retry.MaxDelay), zap.Int("MaxAttempts", cfg.Retry.MaxAttempts), zap.Duration("MaxAge", cfg.Retry.MaxAge))
 
-	go func() {
-		ticker := time.NewTicker(cfg.FetchNewInterval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-w.ctx.Done():
-				return
-			case <-ticker.C:
-				orders, err := w.svc.FetchNewOrders(w.ctx, cfg.BatchSize)
+	limiter := job.NewLimiter(cfg.RequestsPerMinute)
+
+	w.handle(KindOrder, func(task models.Task) (job.Job, error) {
+		var p orderPayload
+		if err := json.Unmarshal(task.Payload, &p); err != nil {
+			return nil, err
+		}
+		order := models.Order{UserID: p.UserID, Number: p.Number, Status: models.OrderProcessing, UploadedAt: p.UploadedAt, Attempts: p.Attempts}
+		return &jobs.OrderJob{Order: order, Limiter: limiter, Retry: cfg.Retry}, nil
+	})
+
+	w.goTracked(func() {
+		w.produceNewOrders(cfg.FetchNewInterval, cfg.BatchSize)
+	})
+	w.goTracked(func() {
+		w.produceOrders("order-processor:processing", cfg.FetchProccesingInterval, cfg.BatchSize, w.svc.FetchProccesingOrders)
+	})
+}
+
+// produceNewOrders забирает новые заказы и ставит задания одной транзакцией хранилища,
+// поэтому сбой очереди не оставляет заказ в PROCESSING без задания. Заказы отмечаются
+// взятыми до коммита, чтобы Shutdown вернул их в NEW, даже если остановка пришла сразу после.
+func (w *Workers) produceNewOrders(interval time.Duration, batch int) {
+	const name = "order-processor:new"
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			var keys []string
+			orders, err := w.queue.EnqueueNewOrders(w.ctx, batch, func(o models.Order) (models.Task, error) {
+				t, err := orderTask(o)
 				if err != nil {
-					w.logger.Warn("[order-processor] failed to get new orders", zap.Error(err))
-					jitterSleep(cfg.FetchNewInterval)
-					continue
+					return models.Task{}, err
+				}
+				w.claim(t.Key, o)
+				keys = append(keys, t.Key)
+				return t, nil
+			})
+
+			// снимаем отметки с заказов, которые в итоге не были взяты
+			taken := make(map[string]bool, len(orders))
+			for _, o := range orders {
+				taken[orderKey(o.Number)] = true
+			}
+			for _, key := range keys {
+				if !taken[key] {
+					w.unclaim(key)
 				}
-				putOrdersInChan(w, orders, throttler)
+			}
+
+			if err != nil && w.ctx.Err() == nil {
+				w.logger.Warn("["+name+"] failed to enqueue new orders", zap.Error(err))
+				jitterSleep(interval)
 			}
 		}
-	}()
+	}
+}
 
-	go func() {
-		ticker := time.NewTicker(cfg.FetchProccesingInterval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-w.ctx.Done():
-				return
-			case <-ticker.C:
-				orders, err := w.svc.FetchProccesingOrders(w.ctx, cfg.BatchSize)
+func (w *Workers) produceOrders(name string, interval time.Duration, batch int, fetch func(ctx context.Context, limit int) ([]models.Order, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			orders, err := fetch(w.ctx, batch)
+			if err != nil {
+				w.logger.Warn("["+name+"] failed to get orders", zap.Error(err))
+				jitterSleep(interval)
+				continue
+			}
+
+			tasks := make([]models.Task, 0, len(orders))
+			for _, o := range orders {
+				t, err := orderTask(o)
 				if err != nil {
-					w.logger.Warn("[order-processor] failed to get processing orders", zap.Error(err))
-					jitterSleep(cfg.FetchProccesingInterval)
+					w.logger.Warn("["+name+"] failed to encode order", zap.String("order", o.Number), zap.Error(err))
 					continue
 				}
-				putOrdersInChan(w, orders, throttler)
+				tasks = append(tasks, t)
 			}
+			w.enqueue(name, tasks...)
 		}
-	}()
-
+	}
 }
 
 func jitterSleep(base time.Duration) {
@@ -116,43 +446,54 @@ func jitterSleep(base time.Duration) {
 	time.Sleep(base/2 + j)
 }
 
-func putOrdersInChan(w Workers, orders []models.Order, throttler *job.Throttler) {
-	for _, o := range orders {
-		select {
-		case <-w.ctx.Done():
-			return
-		case w.jobCh <- &jobs.OrderJob{Order: o, Throttler: throttler}:
-		default:
-			w.logger.Warn("[order-processor] job channel full, skipping order", zap.String("order", o.Number))
-		}
-	}
-}
-
-func (w Workers) StartBalanceProcessor(cfg BalanceConfig) {
+func (w *Workers) StartBalanceProcessor(cfg BalanceConfig) {
 	if cfg.FetchInterval <= 0 {
 		cfg.FetchInterval = 30 * time.Second
 	}
 
 	w.logger.Info("Balance processor config", zap.Duration("FetchInterval", cfg.FetchInterval))
 
-	go func() {
-		ticker := time.NewTicker(cfg.FetchInterval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-w.ctx.Done():
-				return
-			case <-ticker.C:
-				select {
-				case <-w.ctx.Done():
-					return
-				case w.jobCh <- &jobs.BalanceJob{}:
-				default:
-					w.logger.Warn("[balance-processor] job channel full, skipping job")
-					continue
-				}
-			}
+	w.handle(KindBalance, func(models.Task) (job.Job, error) {
+		return &jobs.BalanceJob{}, nil
+	})
+
+	w.goTracked(func() {
+		w.producePeriodic("balance-processor", cfg.FetchInterval, models.Task{Kind: KindBalance, Key: KindBalance})
+	})
+}
+
+func (w *Workers) StartReconcileProcessor(cfg ReconcileConfig) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Minute
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+
+	w.logger.Info("Reconcile processor config", zap.Duration("Interval", cfg.Interval), zap.Int("BatchSize", cfg.BatchSize), zap.Bool("Repair", cfg.Repair))
+
+	w.handle(KindReconcile, func(models.Task) (job.Job, error) {
+		return &jobs.ReconcileJob{BatchSize: cfg.BatchSize, Repair: cfg.Repair}, nil
+	})
+
+	w.goTracked(func() {
+		w.producePeriodic("reconcile-processor", cfg.Interval, models.Task{Kind: KindReconcile, Key: KindReconcile})
+	})
+}
+
+// producePeriodic ставит задание раз в interval. Ключ не даёт копиться заданиям,
+// если предыдущее ещё не выполнено.
+func (w *Workers) producePeriodic(name string, interval time.Duration, task models.Task) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			w.enqueue(name, task)
 		}
-	}()
+	}
 }
@@ -0,0 +1,194 @@
+package worker
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+	"yandex-diplom/internal/accrual"
+	"yandex-diplom/internal/accrual/accrualtest"
+	"yandex-diplom/internal/accrualsim"
+	"yandex-diplom/internal/gophermart"
+	"yandex-diplom/internal/job"
+	"yandex-diplom/internal/models"
+	"yandex-diplom/internal/money"
+	"yandex-diplom/internal/storage/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestOrderProcessor_EndToEnd(t *testing.T) {
+	sim := accrualsim.New(accrualsim.Config{
+		RegisteredFor:  50 * time.Millisecond,
+		ProcessingFor:  50 * time.Millisecond,
+		DefaultAccrual: money.FromInt(500),
+		Rules:          []accrualsim.Rule{{Match: "7992", Invalid: true}},
+	})
+	ts := httptest.NewServer(sim.Handler())
+	defer ts.Close()
+	base, _ := url.Parse(ts.URL)
+
+	store := memory.NewMemoryStorage()
+	svc := gophermart.New(store, zap.NewNop(), "test", accrual.New(base, accrual.Config{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	user := models.User{Login: "e2e-user", Password: "password"}
+	_, err := svc.Register(ctx, user)
+	require.NoError(t, err)
+
+	processed, invalid, unknown := "12345678903", "79927398713", "4561261212345467"
+	for _, number := range []string{processed, invalid} {
+		require.True(t, sim.Register(number))
+	}
+	for _, number := range []string{processed, invalid, unknown} {
+		require.NoError(t, svc.PutOrder(ctx, user.Login, models.Order{Number: number}))
+	}
+
+	workers := InitWorkers(ctx, PoolConfig{MinWorkers: 2, MaxWorkers: 2}, svc, store)
+	workers.StartOrderProcessor(OrderConfig{
+		BatchSize:               10,
+		FetchNewInterval:        20 * time.Millisecond,
+		FetchProccesingInterval: 20 * time.Millisecond,
+		Retry:                   job.RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond, MaxAttempts: 5},
+	})
+
+	statuses := func() map[string]string {
+		orders, err := svc.GetOrders(ctx, user.Login)
+		require.NoError(t, err)
+		statuses := make(map[string]string, len(orders))
+		for _, o := range orders {
+			statuses[o.Number] = o.Status
+		}
+		return statuses
+	}
+	want := map[string]string{processed: models.OrderProcessed, invalid: models.OrderInvalid, unknown: models.OrderProcessing}
+
+	// незарегистрированный в системе начислений заказ уходит в dead letter после MaxAttempts
+	require.Eventually(t, func() bool {
+		dead, err := svc.GetDeadLetterOrders(ctx, 10)
+		require.NoError(t, err)
+		return len(dead) == 1 && dead[0].Number == unknown && dead[0].Attempts == 5
+	}, 5*time.Second, 20*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(want, statuses())
+	}, 5*time.Second, 20*time.Millisecond)
+
+	u, err := store.GetUserByLogin(ctx, user.Login)
+	require.NoError(t, err)
+	balance, err := svc.GetBalance(ctx, u)
+	require.NoError(t, err)
+	require.Equal(t, money.FromInt(500), balance.Current)
+}
+
+func newShutdownFixture(t *testing.T, acc *accrualtest.Server) (*memory.MemoryStorage, gophermart.Service, models.User, string) {
+	t.Helper()
+
+	store := memory.NewMemoryStorage()
+	svc := gophermart.New(store, zap.NewNop(), "test", accrual.New(acc.BaseURL(), accrual.Config{}))
+
+	user := models.User{Login: "shutdown-user", Password: "password"}
+	_, err := svc.Register(context.Background(), user)
+	require.NoError(t, err)
+
+	number := "12345678903"
+	require.NoError(t, svc.PutOrder(context.Background(), user.Login, models.Order{Number: number}))
+	return store, svc, user, number
+}
+
+func orderStatus(t *testing.T, svc gophermart.Service, login, number string) string {
+	t.Helper()
+
+	orders, err := svc.GetOrders(context.Background(), login)
+	require.NoError(t, err)
+	for _, o := range orders {
+		if o.Number == number {
+			return o.Status
+		}
+	}
+	return ""
+}
+
+func TestWorkers_ShutdownDrainsRunningJobs(t *testing.T) {
+	acc := accrualtest.NewServer()
+	defer acc.Close()
+	accrued := 10.0
+	acc.SetOrder("12345678903", accrualtest.Response{State: "PROCESSED", Accrual: &accrued})
+	hold := acc.Hold()
+
+	store, svc, user, number := newShutdownFixture(t, acc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	workers := InitWorkers(ctx, PoolConfig{MinWorkers: 1, MaxWorkers: 1}, svc, store)
+	workers.StartOrderProcessor(OrderConfig{FetchNewInterval: 10 * time.Millisecond, FetchProccesingInterval: time.Hour})
+
+	require.Eventually(t, func() bool { return acc.Requests() > 0 }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		done <- workers.Shutdown(shutdownCtx)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(hold)
+
+	require.NoError(t, <-done)
+	require.Equal(t, models.OrderProcessed, orderStatus(t, svc, user.Login, number))
+}
+
+func TestWorkers_ShutdownCancelsAfterDeadline(t *testing.T) {
+	acc := accrualtest.NewServer()
+	defer acc.Close()
+	hold := acc.Hold()
+	defer close(hold)
+
+	store, svc, user, number := newShutdownFixture(t, acc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	workers := InitWorkers(ctx, PoolConfig{MinWorkers: 1, MaxWorkers: 1}, svc, store)
+	workers.StartOrderProcessor(OrderConfig{FetchNewInterval: 10 * time.Millisecond, FetchProccesingInterval: time.Hour})
+
+	require.Eventually(t, func() bool { return acc.Requests() > 0 }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShutdown()
+	require.ErrorIs(t, workers.Shutdown(shutdownCtx), context.DeadlineExceeded)
+
+	// начатый заказ не возвращается в NEW, его подберёт опрос PROCESSING после истечения аренды
+	require.Equal(t, models.OrderProcessing, orderStatus(t, svc, user.Login, number))
+}
+
+func TestWorkers_ShutdownReleasesUnstartedOrders(t *testing.T) {
+	acc := accrualtest.NewServer()
+	defer acc.Close()
+
+	store, svc, user, number := newShutdownFixture(t, acc)
+
+	// без воркеров заказ выбирается из NEW и ставится в очередь, но не начинается
+	ctx, cancel := context.WithCancel(context.Background())
+	workers := InitWorkers(ctx, PoolConfig{MaxWorkers: 1, ScaleInterval: time.Hour}, svc, store)
+	workers.StartOrderProcessor(OrderConfig{FetchNewInterval: 10 * time.Millisecond, FetchProccesingInterval: time.Hour})
+
+	require.Eventually(t, func() bool {
+		return orderStatus(t, svc, user.Login, number) == models.OrderProcessing
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	require.NoError(t, workers.Shutdown(shutdownCtx))
+
+	require.Equal(t, models.OrderNew, orderStatus(t, svc, user.Login, number))
+	tasks, err := store.LeaseTasks(context.Background(), []string{KindOrder}, 10, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, tasks)
+	require.Zero(t, acc.Requests())
+}
@@ -0,0 +1,9 @@
+ALTER TABLE user_point_balances
+    ALTER COLUMN balance TYPE REAL USING balance::real,
+    ALTER COLUMN withdrawal TYPE REAL USING withdrawal::real;
+
+ALTER TABLE user_balance_entries
+    ALTER COLUMN amount_points TYPE REAL USING amount_points::real;
+
+ALTER TABLE user_orders
+    ALTER COLUMN points_awarded TYPE REAL USING points_awarded::real;
@@ -0,0 +1,12 @@
+ALTER TABLE user_orders
+    ALTER COLUMN points_awarded TYPE NUMERIC(14,2) USING round(points_awarded::numeric, 2),
+    ALTER COLUMN points_awarded SET DEFAULT 0;
+
+ALTER TABLE user_balance_entries
+    ALTER COLUMN amount_points TYPE NUMERIC(14,2) USING round(amount_points::numeric, 2);
+
+ALTER TABLE user_point_balances
+    ALTER COLUMN balance TYPE NUMERIC(14,2) USING round(balance::numeric, 2),
+    ALTER COLUMN balance SET DEFAULT 0,
+    ALTER COLUMN withdrawal TYPE NUMERIC(14,2) USING round(withdrawal::numeric, 2),
+    ALTER COLUMN withdrawal SET DEFAULT 0;
@@ -0,0 +1,9 @@
+DELETE FROM user_balance_entries WHERE entry_type = 'adjustment';
+
+ALTER TABLE user_balance_entries
+    DROP CONSTRAINT IF EXISTS user_balance_entries_adjustment_check,
+    DROP CONSTRAINT IF EXISTS user_balance_entries_amount_sign_check,
+    DROP COLUMN IF EXISTS reverses_entry_id,
+    DROP COLUMN IF EXISTS operator_id,
+    DROP COLUMN IF EXISTS reason,
+    ADD CONSTRAINT user_balance_entries_amount_points_check CHECK (amount_points >= 0);
@@ -0,0 +1,9 @@
+ALTER TABLE user_balance_entries
+    DROP CONSTRAINT IF EXISTS user_balance_entries_amount_points_check,
+    ADD COLUMN reason            TEXT,
+    ADD COLUMN operator_id       BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN reverses_entry_id BIGINT UNIQUE REFERENCES user_balance_entries(id),
+    ADD CONSTRAINT user_balance_entries_amount_sign_check
+        CHECK (entry_type = 'adjustment' OR amount_points >= 0),
+    ADD CONSTRAINT user_balance_entries_adjustment_check
+        CHECK (entry_type <> 'adjustment' OR (reason IS NOT NULL AND amount_points <> 0));
@@ -0,0 +1,5 @@
+CREATE TABLE user_roles (
+    user_id     BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    role        TEXT NOT NULL CHECK (role IN ('user', 'admin')),
+    granted_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
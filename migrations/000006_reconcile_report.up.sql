@@ -0,0 +1,5 @@
+CREATE TABLE reconcile_report (
+    id          BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    report      JSONB NOT NULL,
+    saved_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_user_orders_dead_letter;
+DROP INDEX IF EXISTS idx_user_orders_next_attempt;
+
+ALTER TABLE user_orders
+    DROP COLUMN IF EXISTS dead_lettered_at,
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS next_attempt_at,
+    DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,11 @@
+ALTER TABLE user_orders
+    ADD COLUMN attempts         INT NOT NULL DEFAULT 0,
+    ADD COLUMN next_attempt_at  TIMESTAMPTZ,
+    ADD COLUMN last_error       TEXT,
+    ADD COLUMN dead_lettered_at TIMESTAMPTZ;
+
+CREATE INDEX idx_user_orders_next_attempt ON user_orders(next_attempt_at)
+    WHERE status = 'PROCESSING' AND dead_lettered_at IS NULL;
+
+CREATE INDEX idx_user_orders_dead_letter ON user_orders(dead_lettered_at DESC)
+    WHERE dead_lettered_at IS NOT NULL;
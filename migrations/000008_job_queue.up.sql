@@ -0,0 +1,13 @@
+CREATE TABLE job_queue (
+    id            BIGSERIAL PRIMARY KEY,
+    kind          TEXT NOT NULL,
+    dedup_key     TEXT UNIQUE,
+    payload       JSONB NOT NULL DEFAULT '{}',
+    attempts      INT NOT NULL DEFAULT 0,
+    available_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    leased_until  TIMESTAMPTZ,
+    last_error    TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_job_queue_available ON job_queue(kind, available_at, id);